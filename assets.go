@@ -0,0 +1,22 @@
+// Package bookings embeds the templates, static files and database migrations, so the
+// binaries can run without the source tree.
+package bookings
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+//go:embed templates static migrations
+var files embed.FS
+
+// Assets returns the file system holding the templates, static and migrations
+// directories: the copy embedded in the binary, or dir on disk when fromDisk is set,
+// so changes show up without rebuilding during development.
+func Assets(fromDisk bool, dir string) fs.FS {
+	if fromDisk {
+		return os.DirFS(dir)
+	}
+	return files
+}
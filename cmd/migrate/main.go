@@ -0,0 +1,64 @@
+// Command migrate applies the database migrations that have not been applied yet. It
+// reads the same configuration as the web server, and uses the migrations embedded in
+// the binary unless -assets-from-disk is set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	bookings "github.com/prashant9154/Booking_System"
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/driver"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/migrations"
+)
+
+func main() {
+	err := run(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	var app config.AppConfig
+
+	err := config.LoadFromOS(&app, args)
+	if err != nil {
+		return err
+	}
+
+	logger := logging.New(os.Stdout, app.Log.Format, app.Log.SlogLevel())
+
+	fsys, err := fs.Sub(bookings.Assets(app.Assets.FromDisk, app.Assets.Dir), "migrations")
+	if err != nil {
+		return err
+	}
+
+	db, err := driver.ConnectSQL(app.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer db.SQL.Close()
+
+	applied, err := migrations.Up(context.Background(), db.SQL, fsys)
+	for _, version := range applied {
+		logger.Info("applied migration", "version", version)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("database is up to date", "applied", len(applied))
+	return nil
+}
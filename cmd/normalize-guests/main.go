@@ -0,0 +1,230 @@
+// Command normalize-guests cleans up the guest details of the reservations stored before
+// they were normalized on the way in: names are trimmed and normalized as forms.NormalizeText
+// does, emails are lowercased and phone numbers are rewritten in E.164. Encrypted details are
+// decrypted to be normalized and encrypted again, and the email hash is recomputed in the same
+// update. It reads the same configuration as the web server; with -dry-run it only logs what
+// would change.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/driver"
+	"github.com/prashant9154/Booking_System/internal/forms"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/pii"
+)
+
+// batchSize is the number of reservations read and updated in each transaction
+const batchSize = 500
+
+type guest struct {
+	id                                int
+	firstName, lastName, email, phone string
+	emailHash                         sql.NullString
+}
+
+func main() {
+	err := run(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "  -dry-run\n\tlog the changes without saving them")
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	var app config.AppConfig
+
+	args, dryRun := dryRunFlag(args)
+
+	err := config.LoadFromOS(&app, args)
+	if err != nil {
+		return err
+	}
+
+	logger := logging.New(os.Stdout, app.Log.Format, app.Log.SlogLevel())
+	forms.DefaultCallingCode = app.DefaultCallingCode
+
+	keys, err := app.PII.Keyring()
+	if err != nil {
+		return fmt.Errorf("cannot load encryption keys: %w", err)
+	}
+
+	db, err := driver.ConnectSQL(app.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer db.SQL.Close()
+
+	ctx := context.Background()
+	updated, after := 0, 0
+	for {
+		n, last, err := normalizeBatch(ctx, db.SQL, logger, keys, after, dryRun)
+		if err != nil {
+			return err
+		}
+		updated += n
+		if last == 0 {
+			break
+		}
+		after = last
+	}
+
+	logger.Info("normalized guest details", "reservations", updated, "dry_run", dryRun)
+	return nil
+}
+
+// normalizeBatch normalizes the batch of reservations after the id after, returning how
+// many changed and the last id read, 0 when there were none left
+func normalizeBatch(ctx context.Context, db *sql.DB, logger *slog.Logger, keys *pii.Keyring, after int, dryRun bool) (int, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		select id, first_name, last_name, email, phone, email_hash
+		from reservations
+		where id > $1
+		order by id
+		limit $2
+		for update`,
+		after, batchSize,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var guests []guest
+	for rows.Next() {
+		var g guest
+		err = rows.Scan(&g.id, &g.firstName, &g.lastName, &g.email, &g.phone, &g.emailHash)
+		if err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		guests = append(guests, g)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(guests) == 0 {
+		return 0, 0, nil
+	}
+
+	changed := 0
+	for _, g := range guests {
+		n, err := normalize(keys, g)
+		if err != nil {
+			return 0, 0, fmt.Errorf("reservation %d: %w", g.id, err)
+		}
+		if n == g {
+			continue
+		}
+		changed++
+
+		logger.Info("normalizing reservation", "id", g.id, "dry_run", dryRun,
+			"first_name", n.firstName != g.firstName,
+			"last_name", n.lastName != g.lastName,
+			"email", n.email != g.email,
+			"phone", n.phone != g.phone,
+			"email_hash", n.emailHash != g.emailHash,
+		)
+		if dryRun {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			update reservations
+			set first_name = $1, last_name = $2, email = $3, phone = $4, email_hash = $5, updated_at = $6
+			where id = $7`,
+			n.firstName, n.lastName, n.email, n.phone, n.emailHash, time.Now(), g.id,
+		)
+		if err != nil {
+			return 0, 0, fmt.Errorf("reservation %d: %w", g.id, err)
+		}
+	}
+
+	if !dryRun {
+		err = tx.Commit()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return changed, guests[len(guests)-1].id, nil
+}
+
+// normalize returns g as it would have been stored by the make-reservation form; phone
+// numbers that cannot be read as one are only cleaned up as text. Encrypted values are
+// decrypted to be normalized and only encrypted again when they change, and the email hash
+// is computed from the normalized address.
+func normalize(keys *pii.Keyring, g guest) (guest, error) {
+	g.firstName = forms.NormalizeText(g.firstName)
+	g.lastName = forms.NormalizeText(g.lastName)
+
+	email, err := keys.Open(g.email)
+	if err != nil {
+		return guest{}, err
+	}
+	normalized := forms.NormalizeEmail(email)
+	if normalized != email {
+		g.email, err = keys.Seal("reservations.email", normalized)
+		if err != nil {
+			return guest{}, err
+		}
+	}
+	h := keys.Hash(normalized)
+	g.emailHash = sql.NullString{String: h, Valid: h != ""}
+
+	phone, err := keys.Open(g.phone)
+	if err != nil {
+		return guest{}, err
+	}
+	normalized, ok := forms.NormalizePhone(phone)
+	if !ok {
+		normalized = forms.NormalizeText(phone)
+	}
+	if normalized != phone {
+		g.phone, err = keys.Seal("reservations.phone", normalized)
+		if err != nil {
+			return guest{}, err
+		}
+	}
+
+	return g, nil
+}
+
+// dryRunFlag removes -dry-run from args, which are otherwise the server's flags
+func dryRunFlag(args []string) ([]string, bool) {
+	rest := make([]string, 0, len(args))
+	dryRun := false
+
+	for _, arg := range args {
+		switch arg {
+		case "-dry-run", "--dry-run", "-dry-run=true", "--dry-run=true":
+			dryRun = true
+		case "-dry-run=false", "--dry-run=false":
+			dryRun = false
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return rest, dryRun
+}
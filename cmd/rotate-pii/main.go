@@ -0,0 +1,237 @@
+// Command rotate-pii brings the stored guest details in line with the pii settings: values
+// of encrypted columns stored in plain text are encrypted, the data keys of values
+// encrypted with a master key other than the primary one are rewrapped with it, values of
+// columns no longer encrypted are decrypted, and missing email hashes are filled in. Run it
+// after adding a key, before removing the old one. It reads the same configuration as the
+// web server; with -dry-run it only logs what would change.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/driver"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/pii"
+)
+
+// batchSize is the number of rows read and updated in each transaction
+const batchSize = 500
+
+// table is a table with encrypted columns and the email hash that is kept next to them
+type table struct {
+	name    string
+	columns []string
+}
+
+// tables lists the columns of every table that can be encrypted; the first is the email address
+var tables = []table{
+	{name: "reservations", columns: []string{"email", "phone"}},
+	{name: "messages", columns: []string{"email"}},
+}
+
+type row struct {
+	id     int
+	values []string
+	hash   sql.NullString
+}
+
+func main() {
+	err := run(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "  -dry-run\n\tlog the changes without saving them")
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string) error {
+	var app config.AppConfig
+
+	args, dryRun := dryRunFlag(args)
+
+	err := config.LoadFromOS(&app, args)
+	if err != nil {
+		return err
+	}
+
+	logger := logging.New(os.Stdout, app.Log.Format, app.Log.SlogLevel())
+
+	keys, err := app.PII.Keyring()
+	if err != nil {
+		return fmt.Errorf("cannot load encryption keys: %w", err)
+	}
+
+	db, err := driver.ConnectSQL(app.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer db.SQL.Close()
+
+	ctx := context.Background()
+	for _, t := range tables {
+		updated, after := 0, 0
+		for {
+			n, last, err := rotateBatch(ctx, db.SQL, logger, keys, t, after, dryRun)
+			if err != nil {
+				return fmt.Errorf("%s: %w", t.name, err)
+			}
+			updated += n
+			if last == 0 {
+				break
+			}
+			after = last
+		}
+
+		logger.Info("rotated guest details", "table", t.name, "rows", updated, "dry_run", dryRun)
+	}
+
+	return nil
+}
+
+// rotateBatch rotates the batch of rows of t after the id after, returning how many
+// changed and the last id read, 0 when there were none left
+func rotateBatch(ctx context.Context, db *sql.DB, logger *slog.Logger, keys *pii.Keyring, t table, after int, dryRun bool) (int, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	cols := ""
+	for _, c := range t.columns {
+		cols += c + ", "
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		select id, `+cols+`email_hash
+		from `+t.name+`
+		where id > $1
+		order by id
+		limit $2
+		for update`,
+		after, batchSize,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var batch []row
+	for rows.Next() {
+		r := row{values: make([]string, len(t.columns))}
+		dest := []interface{}{&r.id}
+		for i := range r.values {
+			dest = append(dest, &r.values[i])
+		}
+		dest = append(dest, &r.hash)
+
+		err = rows.Scan(dest...)
+		if err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		batch = append(batch, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	if len(batch) == 0 {
+		return 0, 0, nil
+	}
+
+	changed := 0
+	for _, r := range batch {
+		values, hash, ok, err := rotate(keys, t, r)
+		if err != nil {
+			return 0, 0, fmt.Errorf("row %d: %w", r.id, err)
+		}
+		if !ok {
+			continue
+		}
+		changed++
+
+		logger.Info("rotating guest details", "table", t.name, "id", r.id, "dry_run", dryRun)
+		if dryRun {
+			continue
+		}
+
+		set, args := "", []interface{}{}
+		for i, c := range t.columns {
+			args = append(args, values[i])
+			set += fmt.Sprintf("%s = $%d, ", c, len(args))
+		}
+		args = append(args, hash, time.Now(), r.id)
+
+		_, err = tx.ExecContext(ctx,
+			fmt.Sprintf("update %s set %semail_hash = $%d, updated_at = $%d where id = $%d", t.name, set, len(args)-2, len(args)-1, len(args)),
+			args...,
+		)
+		if err != nil {
+			return 0, 0, fmt.Errorf("row %d: %w", r.id, err)
+		}
+	}
+
+	if !dryRun {
+		err = tx.Commit()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return changed, batch[len(batch)-1].id, nil
+}
+
+// rotate returns the values and email hash r should be stored with, and whether they changed
+func rotate(keys *pii.Keyring, t table, r row) ([]string, sql.NullString, bool, error) {
+	values := make([]string, len(r.values))
+	changed := false
+
+	for i, c := range t.columns {
+		v, ok, err := keys.Rotate(t.name+"."+c, r.values[i])
+		if err != nil {
+			return nil, sql.NullString{}, false, err
+		}
+		values[i] = v
+		changed = changed || ok
+	}
+
+	email, err := keys.Open(r.values[0])
+	if err != nil {
+		return nil, sql.NullString{}, false, err
+	}
+	h := keys.Hash(email)
+	hash := sql.NullString{String: h, Valid: h != ""}
+
+	return values, hash, changed || hash != r.hash, nil
+}
+
+// dryRunFlag removes -dry-run from args, which are otherwise the server's flags
+func dryRunFlag(args []string) ([]string, bool) {
+	rest := make([]string, 0, len(args))
+	dryRun := false
+
+	for _, arg := range args {
+		switch arg {
+		case "-dry-run", "--dry-run", "-dry-run=true", "--dry-run=true":
+			dryRun = true
+		case "-dry-run=false", "--dry-run=false":
+			dryRun = false
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	return rest, dryRun
+}
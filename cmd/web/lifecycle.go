@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// hook is a subsystem with start and stop functions
+type hook struct {
+	name  string
+	start func() error
+	stop  func(ctx context.Context) error
+}
+
+// lifecycle starts subsystems in the order they were registered and stops them in reverse
+type lifecycle struct {
+	hooks   []hook
+	started int
+}
+
+// Register adds a subsystem; either function may be nil
+func (l *lifecycle) Register(name string, start func() error, stop func(ctx context.Context) error) {
+	l.hooks = append(l.hooks, hook{name: name, start: start, stop: stop})
+}
+
+// Start starts every subsystem in order. If one fails, the ones already started are stopped.
+func (l *lifecycle) Start() error {
+	for _, h := range l.hooks {
+		if h.start != nil {
+			logger.Info("starting", "subsystem", h.name)
+			if err := h.start(); err != nil {
+				_ = l.Stop(context.Background())
+				return fmt.Errorf("cannot start %s: %w", h.name, err)
+			}
+		}
+		l.started++
+	}
+	return nil
+}
+
+// Stop stops the started subsystems in reverse order. Every subsystem gets a
+// chance to stop even when an earlier one fails or the context expires.
+func (l *lifecycle) Stop(ctx context.Context) error {
+	var errs []error
+
+	for ; l.started > 0; l.started-- {
+		h := l.hooks[l.started-1]
+		if h.stop == nil {
+			continue
+		}
+
+		logger.Info("stopping", "subsystem", h.name)
+		if err := h.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("cannot stop %s: %w", h.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopWithContext runs a blocking stop function, giving up when the context is done
+func stopWithContext(stop func()) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			stop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	var calls []string
+
+	record := func(name string) (func() error, func(context.Context) error) {
+		return func() error {
+				calls = append(calls, "start "+name)
+				return nil
+			}, func(context.Context) error {
+				calls = append(calls, "stop "+name)
+				return nil
+			}
+	}
+
+	var lc lifecycle
+	start, stop := record("a")
+	lc.Register("a", start, stop)
+	start, stop = record("b")
+	lc.Register("b", start, stop)
+
+	if err := lc.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := lc.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "start a,start b,stop b,stop a"
+	if strings.Join(calls, ",") != expected {
+		t.Errorf("expected %s but got %s", expected, strings.Join(calls, ","))
+	}
+}
+
+func TestLifecycle_StartFailure(t *testing.T) {
+	var stopped []string
+
+	var lc lifecycle
+	lc.Register("a", nil, func(context.Context) error {
+		stopped = append(stopped, "a")
+		return nil
+	})
+	lc.Register("b", func() error {
+		return errors.New("boom")
+	}, func(context.Context) error {
+		stopped = append(stopped, "b")
+		return nil
+	})
+
+	err := lc.Start()
+	if err == nil {
+		t.Fatal("expected start to fail")
+	}
+
+	if strings.Join(stopped, ",") != "a" {
+		t.Errorf("expected only a to be stopped but got %v", stopped)
+	}
+}
+
+func TestStopWithContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	err := stopWithContext(func() { <-block })(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded but got %v", err)
+	}
+
+	err = stopWithContext(func() {})(context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -1,106 +1,290 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	bookings "github.com/prashant9154/Booking_System"
 	"github.com/prashant9154/Booking_System/internal/config"
 	"github.com/prashant9154/Booking_System/internal/driver"
+	"github.com/prashant9154/Booking_System/internal/events"
+	"github.com/prashant9154/Booking_System/internal/forms"
 	handler "github.com/prashant9154/Booking_System/internal/handlers"
 	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/mailer"
+	"github.com/prashant9154/Booking_System/internal/metrics"
 	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/ratelimit"
 	"github.com/prashant9154/Booking_System/internal/render"
+	"github.com/prashant9154/Booking_System/internal/sessions"
+	"github.com/prashant9154/Booking_System/internal/tracing"
 )
 
-const portNumber = ":8080"
+// errNoDatabase is returned by run when the database cannot be reached
+var errNoDatabase = errors.New("cannot connect to database")
 
 var app config.AppConfig
 var session *scs.SessionManager
-var infoLog *log.Logger
-var errorLog *log.Logger
+var outbox *events.Dispatcher
+var stopTracing func(context.Context) error
+var sessionCleaner *sessions.Cleaner
+var templateWatcher *render.Watcher
+var limiter ratelimit.Store
+var logger *slog.Logger
 
 func main() {
 
-	db, err := run()
+	db, err := run(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		return
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.SQL.Close()
 
 	// http.HandleFunc("/", handler.Repo.Home)
 	// http.HandleFunc("/about", handler.Repo.About)
 
-	fmt.Printf("Starting Application on port %s \n", portNumber)
-
-	// _ = http.ListenAndServe(portNumber, nil)
-
 	srv := &http.Server{
-		Addr:    portNumber,
+		Addr:    app.Addr(),
 		Handler: routes(&app),
 	}
 
-	err = srv.ListenAndServe()
+	// serveErr receives the error if a server stops other than by Shutdown
+	serveErr := make(chan error, 2)
+
+	// subsystems start in this order and stop in reverse, so the server stops
+	// taking requests first, then the database is closed and spans are flushed last
+	var lc lifecycle
+	lc.Register("tracing", nil, stopTracing)
+	lc.Register("database", nil, func(ctx context.Context) error {
+		return db.SQL.Close()
+	})
+	lc.Register("session cleaner", func() error {
+		sessionCleaner.Start()
+		return nil
+	}, stopWithContext(sessionCleaner.Stop))
+	if templateWatcher != nil {
+		lc.Register("template watcher", func() error {
+			templateWatcher.Start()
+			return nil
+		}, stopWithContext(templateWatcher.Stop))
+	}
+	lc.Register("mail listener", listenForMail, stopMail)
+	lc.Register("webhook dispatcher", func() error {
+		handler.Repo.Webhooks.Start()
+		return nil
+	}, stopWithContext(handler.Repo.Webhooks.Stop))
+	lc.Register("outbox dispatcher", func() error {
+		outbox.Start()
+		return nil
+	}, stopWithContext(outbox.Stop))
+	if app.Retention.Interval > 0 {
+		lc.Register("retention job", func() error {
+			handler.Repo.Retention.Start()
+			return nil
+		}, stopWithContext(handler.Repo.Retention.Stop))
+	}
+	lc.Register("http server", func() error {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			return err
+		}
+
+		logger.Info("starting application", "env", app.Env, "port", app.Port)
+
+		go func() {
+			if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- err
+			}
+		}()
+		return nil
+	}, srv.Shutdown)
+	if app.AdminAddr != "" {
+		adminSrv := &http.Server{
+			Addr:    app.AdminAddr,
+			Handler: adminRoutes(),
+		}
+
+		lc.Register("admin server", func() error {
+			ln, err := net.Listen("tcp", adminSrv.Addr)
+			if err != nil {
+				return err
+			}
+
+			logger.Info("starting admin server", "addr", adminSrv.Addr)
+
+			go func() {
+				if err := adminSrv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+					serveErr <- err
+				}
+			}()
+			return nil
+		}, adminSrv.Shutdown)
+	}
+	lc.Register("readiness", nil, func(ctx context.Context) error {
+		// fail the readiness probe and give load balancers time to notice
+		// before the server stops accepting connections
+		handler.Repo.Drain()
+
+		select {
+		case <-time.After(app.ShutdownDrainDelay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	err = lc.Start()
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("cannot start", "error", err)
+		os.Exit(1)
+	}
+
+	select {
+	case <-ctx.Done():
+		logger.Info("shutting down")
+	case err := <-serveErr:
+		logger.Error("server stopped", "error", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.ShutdownTimeout)
+	defer cancel()
+
+	err = lc.Stop(shutdownCtx)
+	if err != nil {
+		logger.Error("cannot stop cleanly", "error", err)
+		os.Exit(1)
 	}
+
+	logger.Info("stopped")
 }
 
-func run() (*driver.DB, error) {
+// run sets up the application, reading the config from args, the environment and the config file
+func run(args []string) (*driver.DB, error) {
 	// what I am going to put in the session
 	gob.Register(models.Reservation{})
 	gob.Register(models.Restriction{})
 	gob.Register(models.Room{})
 	gob.Register(models.User{})
 
-	// change this to true in production
-	app.InProduction = false
+	app.MailQueue = mailer.NewQueue(100)
+
+	err := config.LoadFromOS(&app, args)
+	if err != nil {
+		return nil, err
+	}
+
+	logger = logging.New(os.Stdout, app.Log.Format, app.Log.SlogLevel())
+	app.Logger = logger
+
+	err = i18n.SetDefault(app.DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	forms.DefaultCallingCode = app.DefaultCallingCode
 
-	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	app.InfoLog = infoLog
+	app.Keyring, err = app.PII.Keyring()
+	if err != nil {
+		return nil, fmt.Errorf("cannot load encryption keys: %w", err)
+	}
+	if app.InProduction && !app.Keyring.Enabled() {
+		logger.Warn("guest details are stored in plain text, set pii.keys to encrypt them")
+	}
 
-	errorLog = log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	app.ErrorLog = errorLog
+	stopTracing, err = tracing.Setup(&app)
+	if err != nil {
+		return nil, err
+	}
 
 	session = scs.New()
-	session.Lifetime = 24 * time.Hour
+	session.Lifetime = app.Sessions.Lifetime
+	session.IdleTimeout = app.Sessions.IdleTimeout
 	session.Cookie.Persist = true
-	session.Cookie.SameSite = http.SameSiteLaxMode
-	session.Cookie.Secure = app.InProduction
+	session.Cookie.SameSite = app.Cookie.SameSiteMode()
+	session.Cookie.Secure = app.Cookie.Secure
+	session.Cookie.Domain = app.Cookie.Domain
 
 	app.Session = session
 
 	// connect to database
-	log.Println("Connecting to database...")
-	db, err := driver.ConnectSQL("host=localhost port=5432 dbname=bookings user=prashant.kaushal password=")
+	logger.Info("connecting to database")
+	db, err := driver.ConnectSQL(app.Database.DSN())
 
 	if err != nil {
-		log.Fatal("Cannot connect to database! Dying...")
+		return nil, fmt.Errorf("%w: %w", errNoDatabase, err)
 	}
 
-	log.Println("Database Connected!")
+	logger.Info("database connected")
+
+	metrics.RegisterDB(db.SQL)
 	// defer db.SQL.Close()
 
-	tc, err := render.CreateTemplateCache()
+	store, err := sessions.NewStore(app.Sessions, db.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create session store: %w", err)
+	}
+	session.Store = tracing.NewSessionStore(store)
+	sessionCleaner = sessions.NewCleaner(store, app.Sessions.CleanupInterval, logger)
+
+	limiter, err = ratelimit.NewStore(app.RateLimit, db.SQL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create rate limit store: %w", err)
+	}
 
+	assets := bookings.Assets(app.Assets.FromDisk, app.Assets.Dir)
+	app.TemplateFS, err = fs.Sub(assets, "templates")
+	if err != nil {
+		return nil, err
+	}
+	app.StaticFS, err = fs.Sub(assets, "static")
 	if err != nil {
-		log.Fatal("cannot create template cache")
 		return nil, err
 	}
 
-	app.TemplateCache = tc
-	app.UseCache = false
+	// in production the cache is built once and a broken template stops startup;
+	// in development templates are reloaded when they change and errors are shown in the browser
+	if app.UseCache {
+		tc, err := render.CreateTemplateCache(app.TemplateFS)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create template cache: %w", err)
+		}
+		app.TemplateCache = tc
+	} else {
+		templateWatcher = render.NewWatcher(app.TemplateFS, time.Second, logger)
+		render.SetWatcher(templateWatcher)
+	}
 
 	repo := handler.NewRepo(&app, db)
 
 	handler.NewHandlers(repo)
 
+	// deliver domain events written to the outbox to the in-process subscribers
+	outbox = events.NewDispatcher(repo.DB, logger)
+	outbox.Subscribe(events.ReservationCreated, repo.Webhooks.HandleEvent)
+	outbox.Subscribe(events.ReservationCreated, events.Once(repo.DB, "reservation-confirmation", repo.SendReservationConfirmation))
+
 	render.NewRenderer(&app)
+	render.SetRoutes(routeNames)
 
 	helpers.NewHelpers(&app)
 
@@ -1,9 +1,15 @@
 package main
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestRun(t *testing.T) {
-	err := run()
+	_, err := run(nil)
+	if errors.Is(err, errNoDatabase) {
+		t.Skip("database is not available:", err)
+	}
 	if err != nil {
 		t.Error("failed run")
 	}
@@ -1,14 +1,212 @@
 package main
 
 import (
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 
 	"github.com/justinas/nosurf"
+	"github.com/prashant9154/Booking_System/internal/clientip"
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/forms"
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/ratelimit"
+	"github.com/prashant9154/Booking_System/internal/security"
+	"github.com/prashant9154/Booking_System/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func WriteToConsole(next http.Handler) http.Handler {
+// RequestID gives every request an id, carried in its context so everything it
+// logs can be correlated, and returned in the X-Request-ID response header. A valid
+// id sent by the client, e.g. by a load balancer, is used instead of a new one.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.HeaderRequestID)
+		if !logging.ValidRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(logging.HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// ClientIP resolves the address of the client of every request, believing the forwarding
+// headers of app.Security.TrustedProxies, and carries it in the request context for
+// logging, rate limits and audit records
+func ClientIP(next http.Handler) http.Handler {
+	resolver := clientip.New(app.Security.TrustedProxies)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(clientip.WithIP(r.Context(), resolver.IP(r))))
+	})
+}
+
+// SecureHeaders sets the security headers of every response: the Content Security
+// Policy, with a new nonce carried in the request context for the page's own inline
+// scripts and styles, HSTS in production, and headers that keep the site out of frames
+// and limit what it shares with other sites and which browser features it can use
+func SecureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := security.NewNonce()
+		h := w.Header()
+
+		csp := "Content-Security-Policy"
+		if app.Security.CSPReportOnly {
+			csp = "Content-Security-Policy-Report-Only"
+		}
+		h.Set(csp, security.WithReportURI(security.Policy(app.Security.CSP, nonce), "/csp-report"))
+
+		if app.InProduction && app.Security.HSTSMaxAge > 0 {
+			h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int(app.Security.HSTSMaxAge.Seconds())))
+		}
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		h.Set("Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=()")
+
+		next.ServeHTTP(w, r.WithContext(security.WithNonce(r.Context(), nonce)))
+	})
+}
+
+// Tracing starts a span for every request, continuing the caller's trace when a
+// traceparent header is sent, and names it after the matched route once it is known
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethod(r.Method),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request.id", logging.RequestID(r.Context())),
+			),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := responseStatus(ww)
+		route := routePattern(r)
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// AccessLog logs the method, route, status, bytes written and duration of every request
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := responseStatus(ww)
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		logger.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", routePattern(r)),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// Recoverer logs a panicking request with its stack trace and responds with a 500
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// the server aborts the response itself
+				panic(rvr)
+			}
+
+			logger.ErrorContext(r.Context(), "panic",
+				"error", fmt.Sprint(rvr),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"stack", string(debug.Stack()),
+			)
+			helpers.ErrorPage(w, r, http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Locale picks the language of the response and carries it in the request context. A
+// /<tag> prefix on the path, e.g. /fr/about, picks it and is remembered in a cookie so
+// links without the prefix stay in that language; otherwise the cookie or the
+// Accept-Language header decides.
+func Locale(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// fmt.Println("hit the page")
+		var locale *i18n.Locale
+
+		tag, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if l, ok := i18n.Get(tag); ok {
+			locale = l
+			r.URL.Path = "/" + rest
+			r.URL.RawPath = ""
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     i18n.CookieName,
+				Value:    l.Tag,
+				Path:     "/",
+				MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+				HttpOnly: true,
+				Secure:   app.Cookie.Secure,
+				SameSite: app.Cookie.SameSiteMode(),
+				Domain:   app.Cookie.Domain,
+			})
+		} else if c, err := r.Cookie(i18n.CookieName); err == nil {
+			locale, _ = i18n.Get(c.Value)
+		}
+		if locale == nil {
+			locale = i18n.Match(r.Header.Get("Accept-Language"))
+		}
+
+		w.Header().Set("Content-Language", locale.Tag)
+		w.Header().Add("Vary", "Accept-Language")
+
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
+
+// LimitBody caps request bodies at forms.MaxBodySize. It must come before NoSurf, which
+// parses the form of every post request to find the token before any handler runs.
+func LimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, forms.MaxBodySize)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -20,9 +218,15 @@ func NoSurf(next http.Handler) http.Handler {
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
 		Path:     "/",
-		Secure:   app.InProduction,
-		SameSite: http.SameSiteLaxMode,
+		Secure:   app.Cookie.Secure,
+		SameSite: app.Cookie.SameSiteMode(),
+		Domain:   app.Cookie.Domain,
 	})
+	// browsers send violation reports without a token
+	csrfHandler.ExemptPath("/csp-report")
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+	}))
 
 	return csrfHandler
 }
@@ -31,3 +235,76 @@ func NoSurf(next http.Handler) http.Handler {
 func SessionLoad(next http.Handler) http.Handler {
 	return session.LoadAndSave(next)
 }
+
+// Auth redirects to the login page unless a user is logged in
+func Auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !helpers.IsAuthenticated(r) {
+			session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.login_first"))
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RateLimit limits each client to limit requests to the routes of group, answering
+// the rest with a 429 and a Retry-After header until the client may try again. Clients
+// are told apart by app.RateLimit.Key; if the store fails the request is let through.
+// It must run after SessionLoad, so that only sessions found in the store are trusted.
+func RateLimit(group string, limit config.RateLimit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.Requests == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var token string
+			if app.RateLimit.Key == "session" {
+				token = session.Token(r.Context())
+			}
+			key := group + ":" + ratelimit.Key(r, app.RateLimit.Key, token)
+
+			wait, err := limiter.Take(r.Context(), key, limit)
+			if err != nil {
+				logger.WarnContext(r.Context(), "cannot check rate limit", "group", group, "error", err)
+			}
+			if err != nil || wait == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			metrics.RateLimited.WithLabelValues(group).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			helpers.ClientError(w, r, http.StatusTooManyRequests)
+		})
+	}
+}
+
+// Metrics records the count and latency of every request by its chi route pattern
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		metrics.ObserveRequest(r.Method, routePattern(r), responseStatus(ww), time.Since(start))
+	})
+}
+
+// responseStatus returns the status written to ww, which is 200 if the handler never set one
+func responseStatus(ww middleware.WrapResponseWriter) int {
+	if ww.Status() == 0 {
+		return http.StatusOK
+	}
+	return ww.Status()
+}
+
+// routePattern returns the chi route pattern that matched r, e.g. /rooms/{id}
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
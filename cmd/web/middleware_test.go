@@ -1,9 +1,31 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-chi/chi"
+	"github.com/prashant9154/Booking_System/internal/clientip"
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/ratelimit"
+	"github.com/prashant9154/Booking_System/internal/security"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNoSurf(t *testing.T) {
@@ -29,3 +51,323 @@ func TestSessionLoad(t *testing.T) {
 		t.Error(fmt.Sprintf("type is not http.Handler but is %T", v))
 	}
 }
+
+func TestMetrics(t *testing.T) {
+	mux := chi.NewRouter()
+	mux.Use(Metrics)
+	mux.Get("/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/rooms/42", nil))
+
+	rr := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	expected := `bookings_http_requests_total{method="GET",route="/rooms/{id}",status="418"} 1`
+	if !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("metrics output does not contain %s", expected)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var got string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = logging.RequestID(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if got == "" || rr.Header().Get(logging.HeaderRequestID) != got {
+		t.Errorf("expected the generated id %q in the response header but got %q", got, rr.Header().Get(logging.HeaderRequestID))
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(logging.HeaderRequestID, "from-the-proxy")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if got != "from-the-proxy" || rr.Header().Get(logging.HeaderRequestID) != "from-the-proxy" {
+		t.Errorf("expected the client's id to be kept but got %q", got)
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(logging.HeaderRequestID, "not valid\x00")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got == "not valid\x00" {
+		t.Error("expected an invalid client id to be replaced")
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l *slog.Logger) { logger = l }(logger)
+	logger = logging.New(&buf, "json", slog.LevelInfo)
+
+	mux := chi.NewRouter()
+	mux.Use(RequestID)
+	mux.Use(AccessLog)
+	mux.Get("/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/rooms/42", nil))
+
+	var entry map[string]any
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatalf("expected a single JSON log line but got %q", buf.String())
+	}
+
+	expected := map[string]any{
+		"msg":    "request",
+		"method": "GET",
+		"path":   "/rooms/42",
+		"route":  "/rooms/{id}",
+		"status": float64(http.StatusCreated),
+		"bytes":  float64(5),
+	}
+	for k, v := range expected {
+		if entry[k] != v {
+			t.Errorf("expected %s to be %v but got %v", k, v, entry[k])
+		}
+	}
+
+	if entry["request_id"] == nil || entry["duration"] == nil {
+		t.Errorf("expected request_id and duration in %v", entry)
+	}
+}
+
+func TestRecoverer(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l *slog.Logger) { logger = l }(logger)
+	logger = logging.New(&buf, "text", slog.LevelInfo)
+
+	h := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 but got %d", rr.Code)
+	}
+	if !strings.Contains(buf.String(), "error=boom") {
+		t.Errorf("panic not logged: %q", buf.String())
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	mux := chi.NewRouter()
+	mux.Use(Tracing)
+	mux.Get("/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest("GET", "/rooms/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 span but got %d", len(ended))
+	}
+
+	span := ended[0]
+	if span.Name() != "GET /rooms/{id}" {
+		t.Errorf("unexpected span name %q", span.Name())
+	}
+	if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Error("span does not continue the caller's trace")
+	}
+	if span.Status().Code != codes.Error {
+		t.Error("expected a 500 to mark the span as an error")
+	}
+}
+
+func TestLocale(t *testing.T) {
+	mux := chi.NewRouter()
+	mux.Use(Locale)
+	mux.Get("/about", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, i18n.FromContext(r.Context()).Tag)
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		cookie string
+		accept string
+		want   string
+	}{
+		{"default", "/about", "", "", "en"},
+		{"accept-language", "/about", "", "de-DE,de;q=0.9", "de"},
+		{"cookie over header", "/about", "es", "de", "es"},
+		{"unknown cookie", "/about", "xx", "de", "de"},
+		{"path prefix over cookie", "/fr/about", "es", "de", "fr"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: i18n.CookieName, Value: tt.cookie})
+		}
+		if tt.accept != "" {
+			req.Header.Set("Accept-Language", tt.accept)
+		}
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || rr.Body.String() != tt.want {
+			t.Errorf("%s: got %d %q, wanted %s", tt.name, rr.Code, rr.Body.String(), tt.want)
+		}
+		if got := rr.Header().Get("Content-Language"); got != tt.want {
+			t.Errorf("%s: Content-Language is %q", tt.name, got)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/fr/about", nil))
+	if c := rr.Result().Cookies(); len(c) != 1 || c[0].Value != "fr" {
+		t.Errorf("locale from the path not remembered: %v", c)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	defer func(s ratelimit.Store, sm *scs.SessionManager) { limiter, session = s, sm }(limiter, session)
+	limiter = ratelimit.NewMemoryStore()
+	session = scs.New()
+	app.RateLimit.Key = "ip"
+
+	h := RateLimit("test", config.RateLimit{Requests: 2, Per: time.Minute})(&myHandler{})
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.RemoteAddr = ip + ":1234"
+		r.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+
+	for i := 0; i < 2; i++ {
+		if rr := request("192.0.2.1"); rr.Code != http.StatusOK {
+			t.Fatalf("request %d within the limit got %d", i+1, rr.Code)
+		}
+	}
+
+	rr := request("192.0.2.1")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the limit but got %d", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") != "30" {
+		t.Errorf("expected Retry-After 30 but got %q", rr.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rr.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("expected a JSON error for a JSON client but got %q", rr.Header().Get("Content-Type"))
+	}
+
+	if rr := request("192.0.2.2"); rr.Code != http.StatusOK {
+		t.Errorf("another client got %d", rr.Code)
+	}
+
+	off := RateLimit("test", config.RateLimit{})(&myHandler{})
+	if _, ok := off.(*myHandler); !ok {
+		t.Error("routes without a limit are wrapped")
+	}
+}
+
+func TestRateLimit_Session(t *testing.T) {
+	defer func(s ratelimit.Store, sm *scs.SessionManager, key string) {
+		limiter, session, app.RateLimit.Key = s, sm, key
+	}(limiter, session, app.RateLimit.Key)
+	limiter = ratelimit.NewMemoryStore()
+	session = scs.New()
+	app.RateLimit.Key = "session"
+
+	h := session.LoadAndSave(RateLimit("test", config.RateLimit{Requests: 1, Per: time.Minute})(&myHandler{}))
+
+	// every request makes up a new session cookie, none of which the store knows
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		r.AddCookie(&http.Cookie{Name: session.Cookie.Name, Value: fmt.Sprintf("made-up-%d", i)})
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+
+		if rr.Code != want {
+			t.Errorf("request %d with an unknown session: expected %d but got %d", i+1, want, rr.Code)
+		}
+	}
+}
+
+func TestSecureHeaders(t *testing.T) {
+	defer func(s config.SecurityConfig, prod bool) { app.Security, app.InProduction = s, prod }(app.Security, app.InProduction)
+	app.Security = config.SecurityConfig{CSP: "script-src 'nonce-{nonce}'", HSTSMaxAge: time.Hour}
+
+	var nonce string
+	h := SecureHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = security.Nonce(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	want := "script-src 'nonce-" + nonce + "'; report-uri /csp-report"
+	if nonce == "" || rr.Header().Get("Content-Security-Policy") != want {
+		t.Errorf("expected the policy %q with the request's nonce but got %q", want, rr.Header().Get("Content-Security-Policy"))
+	}
+	if rr.Header().Get("X-Frame-Options") != "DENY" || rr.Header().Get("Referrer-Policy") == "" || rr.Header().Get("Permissions-Policy") == "" {
+		t.Errorf("missing security headers: %v", rr.Header())
+	}
+	if rr.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS sent outside production")
+	}
+
+	app.InProduction = true
+	app.Security.CSPReportOnly = true
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Header().Get("Strict-Transport-Security") != "max-age=3600; includeSubDomains" {
+		t.Errorf("got HSTS %q", rr.Header().Get("Strict-Transport-Security"))
+	}
+	if rr.Header().Get("Content-Security-Policy") != "" || rr.Header().Get("Content-Security-Policy-Report-Only") == "" {
+		t.Error("policy enforced in report-only mode")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer func(proxies []netip.Prefix) { app.Security.TrustedProxies = proxies }(app.Security.TrustedProxies)
+	app.Security.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	var got string
+	h := ClientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = clientip.FromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.0.0.2:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got != "198.51.100.7" {
+		t.Errorf("expected the address forwarded by the proxy but got %q", got)
+	}
+
+	r.RemoteAddr = "192.0.2.1:1234"
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got != "192.0.2.1" {
+		t.Errorf("expected the peer address when it is not a proxy but got %q", got)
+	}
+}
@@ -4,11 +4,31 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
 	"github.com/prashant9154/Booking_System/internal/config"
 	handler "github.com/prashant9154/Booking_System/internal/handlers"
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/metrics"
 )
 
+// routeNames are the routes templates link to with the url function, by name
+var routeNames = map[string]string{
+	"home":                "/",
+	"about":               "/about",
+	"contact":             "/contact",
+	"privacy":             "/privacy",
+	"generals-quarter":    "/generals-quarter",
+	"majors-suite":        "/majors-suite",
+	"search-availability": "/search-availability",
+	"choose-room":         "/choose-room/{id}",
+	"make-reservation":    "/make-reservation",
+	"reservation-summary": "/reservation-summary",
+	"login":               "/user/login",
+	"logout":              "/user/logout",
+	"admin-webhooks":      "/admin/webhooks",
+	"admin-privacy":       "/admin/privacy",
+	"admin-retention":     "/admin/retention",
+}
+
 func routes(app *config.AppConfig) http.Handler {
 	// mux := pat.New()
 
@@ -16,31 +36,95 @@ func routes(app *config.AppConfig) http.Handler {
 	// mux.Get("/about", http.HandlerFunc(handler.Repo.About))
 
 	mux := chi.NewRouter()
-	mux.Use(middleware.Recoverer)
-	mux.Use(WriteToConsole)
+	mux.Use(RequestID)
+	mux.Use(ClientIP)
+	mux.Use(SecureHeaders)
+	mux.Use(Tracing)
+	mux.Use(AccessLog)
+	mux.Use(Metrics)
+	mux.Use(Recoverer)
+	mux.Use(Locale)
+	mux.Use(LimitBody)
 	mux.Use(NoSurf)
 	mux.Use(SessionLoad)
 
 	mux.Get("/", handler.Repo.Home)
 	mux.Get("/about", handler.Repo.About)
 	mux.Get("/contact", handler.Repo.Contact)
+	mux.With(RateLimit("contact", app.RateLimit.Contact)).Post("/contact", handler.Repo.PostContact)
+
+	// guests prove they own an address with a link sent to it before seeing or erasing its data
+	privacy := RateLimit("privacy", app.RateLimit.Privacy)
+	mux.Get("/privacy", handler.Repo.Privacy)
+	mux.With(privacy).Post("/privacy", handler.Repo.PostPrivacy)
+	mux.With(privacy).Get("/privacy/verify", handler.Repo.VerifyPrivacy)
+	mux.Get("/privacy/export", handler.Repo.PrivacyExport)
+	mux.Post("/privacy/erase", handler.Repo.PostPrivacyErase)
 
 	mux.Get("/generals-quarter", handler.Repo.Generals)
 	mux.Get("/majors-suite", handler.Repo.Majors)
 
+	// searches and reservations query the database, so each client gets a limited share
+	search := RateLimit("search", app.RateLimit.Search)
 	mux.Get("/search-availability", handler.Repo.Availability)
-	mux.Post("/search-availability", handler.Repo.PostAvailability)
-	mux.Post("/search-availability-json", handler.Repo.AvailabilityJSON)
+	mux.With(search).Post("/search-availability", handler.Repo.PostAvailability)
+	mux.With(search).Post("/search-availability-json", handler.Repo.AvailabilityJSON)
 
 	mux.Get("/choose-room/{id}", handler.Repo.ChooseRoom)
 
-	mux.Get("/make-reservation", handler.Repo.Reservation)
-	mux.Post("/make-reservation", handler.Repo.PostReservation)
+	reservation := RateLimit("reservation", app.RateLimit.Reservation)
+	mux.With(reservation).Get("/make-reservation", handler.Repo.Reservation)
+	mux.With(reservation).Post("/make-reservation", handler.Repo.PostReservation)
 
 	mux.Get("/reservation-summary", handler.Repo.ReservationSummary)
 
-	fileServer := http.FileServer(http.Dir("./static/"))
+	mux.Get("/api/openapi.json", handler.Repo.OpenAPI)
+
+	mux.Get("/healthz", handler.Repo.Healthz)
+	mux.Get("/readyz", handler.Repo.Readyz)
+	mux.Get("/version", handler.Repo.Version)
+	mux.With(RateLimit("csp_report", app.RateLimit.CSPReport)).Post("/csp-report", handler.Repo.CSPReport)
+
+	mux.Get("/user/login", handler.Repo.ShowLogin)
+	mux.With(RateLimit("login", app.RateLimit.Login)).Post("/user/login", handler.Repo.PostShowLogin)
+	mux.Get("/user/logout", handler.Repo.Logout)
+
+	mux.Route("/admin", func(mux chi.Router) {
+		mux.Use(Auth)
+
+		mux.Get("/webhooks", handler.Repo.AdminWebhooks)
+		mux.Get("/webhooks/new", handler.Repo.AdminNewWebhook)
+		mux.Post("/webhooks/new", handler.Repo.AdminPostNewWebhook)
+		mux.Post("/webhooks/{id}/delete", handler.Repo.AdminDeleteWebhook)
+		mux.Post("/webhooks/deliveries/{id}/resend", handler.Repo.AdminResendWebhookDelivery)
+
+		mux.Get("/privacy", handler.Repo.AdminPrivacy)
+		mux.Post("/privacy/export", handler.Repo.AdminPrivacyExport)
+		mux.Post("/privacy/erase", handler.Repo.AdminPrivacyErase)
+
+		mux.Get("/retention", handler.Repo.AdminRetention)
+		mux.Post("/retention/dry-run", handler.Repo.AdminRetentionDryRun)
+	})
+
+	mux.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		helpers.ClientError(w, r, http.StatusNotFound)
+	})
+	mux.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		helpers.ClientError(w, r, http.StatusMethodNotAllowed)
+	})
+
+	fileServer := http.FileServer(http.FS(app.StaticFS))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
 	return mux
 }
+
+// adminRoutes serves the endpoints kept off the public port, on the admin address
+func adminRoutes() http.Handler {
+	mux := chi.NewRouter()
+	mux.Use(Recoverer)
+
+	mux.Method(http.MethodGet, "/metrics", metrics.Handler())
+
+	return mux
+}
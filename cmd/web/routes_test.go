@@ -2,10 +2,17 @@ package main
 
 import (
 	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/alexedwards/scs/v2"
 	"github.com/go-chi/chi"
+	bookings "github.com/prashant9154/Booking_System"
 	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/forms"
+	handler "github.com/prashant9154/Booking_System/internal/handlers"
 )
 
 func TestRoutes(t *testing.T) {
@@ -20,3 +27,147 @@ func TestRoutes(t *testing.T) {
 		t.Error(fmt.Sprintf("type is not *chi.Mux, but is %T", v))
 	}
 }
+
+func TestRoutes_StaticFromEmbeddedAssets(t *testing.T) {
+	static, err := fs.Sub(bookings.Assets(false, ""), "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := config.AppConfig{StaticFS: static}
+	mux := routes(&app)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/static/css/style.css", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected the embedded stylesheet to be served but got %d", rr.Code)
+	}
+}
+
+// htmlRoutes are the routes that serve pages rather than JSON and so are not part of the OpenAPI document;
+// a "*" method matches every method registered on the route
+var htmlRoutes = map[string]bool{
+	"GET /":                                       true,
+	"GET /about":                                  true,
+	"GET /contact":                                true,
+	"POST /contact":                               true,
+	"GET /privacy":                                true,
+	"POST /privacy":                               true,
+	"GET /privacy/verify":                         true,
+	"POST /privacy/erase":                         true,
+	"GET /generals-quarter":                       true,
+	"GET /majors-suite":                           true,
+	"GET /search-availability":                    true,
+	"POST /search-availability":                   true,
+	"GET /choose-room/{id}":                       true,
+	"GET /make-reservation":                       true,
+	"POST /make-reservation":                      true,
+	"GET /reservation-summary":                    true,
+	"GET /user/login":                             true,
+	"POST /user/login":                            true,
+	"GET /user/logout":                            true,
+	"GET /admin/webhooks":                         true,
+	"GET /admin/webhooks/new":                     true,
+	"POST /admin/webhooks/new":                    true,
+	"POST /admin/webhooks/{id}/delete":            true,
+	"POST /admin/webhooks/deliveries/{id}/resend": true,
+	"GET /admin/privacy":                          true,
+	"POST /admin/privacy/erase":                   true,
+	"GET /admin/retention":                        true,
+	"POST /admin/retention/dry-run":               true,
+	"* /static/*":                                 true,
+}
+
+func TestRoutesHaveOpenAPISpec(t *testing.T) {
+	var app config.AppConfig
+
+	mux := routes(&app)
+	spec := handler.OpenAPISpec()
+
+	walk := func(method, route string, h http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if htmlRoutes[method+" "+route] || htmlRoutes["* "+route] {
+			return nil
+		}
+		if !spec.Has(route, method) {
+			t.Errorf("route %s %s is registered but has no OpenAPI spec entry", method, route)
+		}
+		return nil
+	}
+
+	if err := chi.Walk(mux.(*chi.Mux), walk); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRouteNames(t *testing.T) {
+	var app config.AppConfig
+
+	registered := map[string]bool{}
+	walk := func(method, route string, h http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		registered[route] = true
+		return nil
+	}
+
+	if err := chi.Walk(routes(&app).(*chi.Mux), walk); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, pattern := range routeNames {
+		if !registered[pattern] {
+			t.Errorf("route name %q refers to %s, which is not registered", name, pattern)
+		}
+	}
+}
+
+func TestRoutes_MetricsOnlyOnAdminRoutes(t *testing.T) {
+	var app config.AppConfig
+
+	rr := httptest.NewRecorder()
+	routes(&app).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected /metrics to be missing from the public routes but got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	adminRoutes().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected /metrics on the admin routes but got %d", rr.Code)
+	}
+}
+
+// endlessBody is a request body that never ends, counting the bytes read from it
+type endlessBody struct {
+	read int64
+}
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	b.read += int64(len(p))
+	return len(p), nil
+}
+
+func TestRoutes_LimitBodyBeforeCSRFCheck(t *testing.T) {
+	defer func(sm *scs.SessionManager) { session = sm }(session)
+	session = scs.New()
+
+	var app config.AppConfig
+	mux := routes(&app)
+
+	body := &endlessBody{}
+	r := httptest.NewRequest("POST", "/contact", body)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+
+	if rr.Code == http.StatusOK {
+		t.Error("an oversized post was accepted")
+	}
+	// the csrf check parses the form, so the limit must already apply when it does
+	if body.read > forms.MaxBodySize+64*1024 {
+		t.Errorf("read %d bytes of the body, more than the %d allowed", body.read, forms.MaxBodySize)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// mailDone is closed once the mail listener has sent everything on the mail channel
+var mailDone chan struct{}
+
+// listenForMail sends every message put on the mail channel
+func listenForMail() error {
+	mailDone = make(chan struct{})
+
+	go func() {
+		defer close(mailDone)
+		for msg := range app.MailQueue.Messages() {
+			sendMsg(msg)
+		}
+	}()
+
+	return nil
+}
+
+// stopMail closes the mail queue and waits for the queued messages to be sent. Messages
+// sent after that, by a request or an event still being handled, fail instead of panicking.
+func stopMail(ctx context.Context) error {
+	app.MailQueue.Close()
+
+	select {
+	case <-mailDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d messages not sent: %w", app.MailQueue.Len(), ctx.Err())
+	}
+}
+
+func sendMsg(m models.MailData) {
+	if m.From == "" {
+		m.From = app.Mail.From
+	}
+
+	headers := []string{
+		"From: " + m.From,
+		"To: " + m.To,
+		"Subject: " + m.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/html; charset=UTF-8",
+	}
+
+	body := strings.Join(headers, "\r\n") + "\r\n\r\n" + m.Content
+
+	var auth smtp.Auth
+	if app.Mail.Username != "" {
+		auth = smtp.PlainAuth("", app.Mail.Username, app.Mail.Password, app.Mail.Host)
+	}
+
+	err := smtp.SendMail(app.Mail.Addr(), auth, m.From, []string{m.To}, []byte(body))
+	if err != nil {
+		logger.Error("cannot send mail", "to", m.To, "error", err)
+	}
+}
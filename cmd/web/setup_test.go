@@ -4,9 +4,19 @@ import (
 	"net/http"
 	"os"
 	"testing"
+
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/render"
 )
 
 func TestMain(m *testing.M) {
+	logger = logging.Discard()
+	app.Logger = logger
+	app.TemplateFS = os.DirFS("./../../templates")
+
+	render.NewRenderer(&app)
+	helpers.NewHelpers(&app)
 
 	os.Exit(m.Run())
 }
@@ -0,0 +1,62 @@
+// Package audit builds the entries of the audit log, which records the operations on
+// personal data with who made them, from which address and in which request.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/clientip"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/pii"
+)
+
+// Guest is the actor of operations made by guests on their own data
+const Guest = "guest"
+
+// System is the actor of operations made by scheduled jobs
+const System = "system"
+
+// User returns the actor of operations made by the logged in user with id
+func User(id int) string {
+	return "user:" + strconv.Itoa(id)
+}
+
+// Subject returns what the audit log knows a guest by: the keyed hash of their email
+// address, or a plain SHA-256 of it when keys has no hash key, so the log holds no address
+func Subject(keys *pii.Keyring, email string) string {
+	if h := keys.Hash(email); h != "" {
+		return h
+	}
+
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// New returns an entry for action by actor on the data of subject, made in the request
+// of ctx, whose client address and request id it records. Details are stored as JSON.
+func New(ctx context.Context, action, actor, subject string, details interface{}) models.AuditEntry {
+	e := models.AuditEntry{
+		Action:    action,
+		Actor:     actor,
+		Subject:   subject,
+		ClientIP:  clientip.FromContext(ctx),
+		RequestID: logging.RequestID(ctx),
+		CreatedAt: time.Now(),
+	}
+
+	if details != nil {
+		b, err := json.Marshal(details)
+		if err == nil {
+			e.Details = string(b)
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/prashant9154/Booking_System/internal/clientip"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/pii"
+)
+
+func TestNew(t *testing.T) {
+	ctx := logging.WithRequestID(context.Background(), "abc123")
+	ctx = clientip.WithIP(ctx, "192.0.2.1")
+
+	e := New(ctx, "privacy.export", User(7), "subject", map[string]string{"format": "zip"})
+
+	if e.Actor != "user:7" || e.ClientIP != "192.0.2.1" || e.RequestID != "abc123" || e.CreatedAt.IsZero() {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Details != `{"format":"zip"}` {
+		t.Errorf("got details %q", e.Details)
+	}
+}
+
+func TestSubject(t *testing.T) {
+	plain := Subject(nil, "Guest@Example.com ")
+	if plain == "" || plain != Subject(nil, "guest@example.com") {
+		t.Errorf("subject depends on case: %q", plain)
+	}
+
+	keys, err := pii.New(nil, "", bytes.Repeat([]byte{'h'}, 32), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyed := Subject(keys, "guest@example.com"); keyed == plain || keyed != keys.Hash("guest@example.com") {
+		t.Errorf("expected the keyed hash but got %q", keyed)
+	}
+}
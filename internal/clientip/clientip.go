@@ -0,0 +1,158 @@
+// Package clientip finds the address of the client a request comes from, reading the
+// X-Forwarded-For and Forwarded headers only when they were set by a trusted proxy, and
+// carries it through the request context.
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Resolver resolves the client address of requests sent through the trusted proxies
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// New returns a Resolver trusting the proxies in the given networks. Without any,
+// forwarding headers are ignored and the client is the peer of the connection.
+func New(trusted []netip.Prefix) *Resolver {
+	return &Resolver{trusted: trusted}
+}
+
+// ParsePrefix reads a network such as 10.0.0.0/8, or a single address, which is a
+// network of its own
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("%q is not an address or a network", s)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%q is not an address or a network", s)
+	}
+	if p.Addr().Is4In6() {
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked(), nil
+}
+
+// IP returns the address of the client r comes from. When the peer is a trusted proxy
+// the addresses it forwarded, from the Forwarded header or else X-Forwarded-For, are
+// walked from the nearest hop back, and the first one that is not a trusted proxy is the
+// client. Clients can put anything at the start of those headers, so nothing before the
+// first untrusted hop is believed, and a hop that cannot be read stops the walk at the
+// proxy that sent it.
+func (res *Resolver) IP(r *http.Request) string {
+	client, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !res.isTrusted(client) {
+		return client.String()
+	}
+
+	hops := forwardedFor(r.Header)
+	if hops == nil {
+		hops = forwardedHops(r.Header.Values("X-Forwarded-For"))
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseAddr(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !res.isTrusted(addr) {
+			break
+		}
+	}
+
+	return client.String()
+}
+
+// isTrusted reports whether addr is one of the trusted proxies
+func (res *Resolver) isTrusted(addr netip.Addr) bool {
+	for _, p := range res.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the for= addresses of the Forwarded headers, oldest hop first,
+// or nil if there are none
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	for _, elem := range forwardedHops(h.Values("Forwarded")) {
+		hop := ""
+		for _, pair := range strings.Split(elem, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if strings.EqualFold(k, "for") {
+				hop = strings.Trim(v, `"`)
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// forwardedHops splits header values, which may be repeated, into their comma separated hops
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// parseAddr reads an address with or without a port, and IPv6 addresses with or without brackets
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// WithIP returns a copy of ctx carrying the client address of its request
+func WithIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ip)
+}
+
+// FromContext returns the client address carried by ctx, or "" if there is none
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(contextKey{}).(string)
+	return ip
+}
+
+// FromRequest returns the client address of r, as resolved by the middleware, or the
+// peer of the connection for a request that did not go through it
+func FromRequest(r *http.Request) string {
+	if ip := FromContext(r.Context()); ip != "" {
+		return ip
+	}
+	if addr, ok := parseAddr(r.RemoteAddr); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
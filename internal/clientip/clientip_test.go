@@ -0,0 +1,98 @@
+package clientip
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestResolver_IP(t *testing.T) {
+	res := New([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	})
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{"no proxy", "192.0.2.1:1234", nil, "192.0.2.1"},
+		{"untrusted peer", "192.0.2.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, "192.0.2.1"},
+		{"trusted peer", "10.0.0.2:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, "198.51.100.7"},
+		{"spoofed start", "10.0.0.2:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7"}}, "198.51.100.7"},
+		{"proxy chain", "10.0.0.2:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7, 10.1.1.1"}}, "198.51.100.7"},
+		{"repeated header", "10.0.0.2:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7", "10.1.1.1"}}, "198.51.100.7"},
+		{"only proxies", "10.0.0.2:1234", map[string][]string{"X-Forwarded-For": {"10.3.3.3, 10.1.1.1"}}, "10.3.3.3"},
+		{"garbage hop", "10.0.0.2:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7, nonsense"}}, "10.0.0.2"},
+		{"no header", "10.0.0.2:1234", nil, "10.0.0.2"},
+		{"forwarded", "10.0.0.2:1234", map[string][]string{"Forwarded": {`for=198.51.100.7;proto=https, For="[fd00::1]:4711"`}}, "198.51.100.7"},
+		{"forwarded ipv6", "[fd00::2]:443", map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711"`}}, "2001:db8::1"},
+		{"forwarded before x-forwarded-for", "10.0.0.2:1234", map[string][]string{
+			"Forwarded":       {"for=198.51.100.7"},
+			"X-Forwarded-For": {"203.0.113.9"},
+		}, "198.51.100.7"},
+		{"obfuscated", "10.0.0.2:1234", map[string][]string{"Forwarded": {"for=_hidden"}}, "10.0.0.2"},
+		{"mapped peer", "[::ffff:10.0.0.2]:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.7"}}, "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for k, values := range tt.headers {
+			for _, v := range values {
+				r.Header.Add(k, v)
+			}
+		}
+
+		if got := res.IP(r); got != tt.want {
+			t.Errorf("%s: got %s, wanted %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{" 192.0.2.1", "192.0.2.1/32"},
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"::1", "::1/128"},
+		{"::ffff:10.0.0.0/104", "10.0.0.0/8"},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePrefix(tt.in)
+		if err != nil || p.String() != tt.want {
+			t.Errorf("ParsePrefix(%q): got %s, %v, wanted %s", tt.in, p, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "proxy", "10.0.0.0/33"} {
+		if _, err := ParsePrefix(bad); err == nil {
+			t.Errorf("ParsePrefix(%q) did not fail", bad)
+		}
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	if got := FromRequest(r); got != "192.0.2.1" {
+		t.Errorf("without the middleware got %s", got)
+	}
+
+	r = r.WithContext(WithIP(r.Context(), "198.51.100.7"))
+	if got := FromRequest(r); got != "198.51.100.7" {
+		t.Errorf("got %s, wanted the address in the context", got)
+	}
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("empty context got %q", got)
+	}
+}
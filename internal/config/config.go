@@ -1,18 +1,295 @@
 package config
 
 import (
+	"fmt"
 	"html/template"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/prashant9154/Booking_System/internal/mailer"
+	"github.com/prashant9154/Booking_System/internal/pii"
+	"github.com/prashant9154/Booking_System/internal/retention"
 )
 
 // Appconfig holds the application config (global variables)
 type AppConfig struct {
 	UseCache      bool
 	TemplateCache map[string]*template.Template
-	InfoLog       *log.Logger
-	ErrorLog      *log.Logger
+	TemplateFS    fs.FS
+	StaticFS      fs.FS
+	Logger        *slog.Logger
 	InProduction  bool
 	Session       *scs.SessionManager
+	MailQueue     *mailer.Queue
+	Keyring       *pii.Keyring
+
+	Env                string
+	Port               int
+	AdminAddr          string
+	BaseURL            string
+	ShutdownTimeout    time.Duration
+	ShutdownDrainDelay time.Duration
+	DefaultLocale      string
+	DefaultCallingCode string
+	Database           DatabaseConfig
+	Sessions           SessionConfig
+	Cookie             CookieConfig
+	Mail               MailConfig
+	Log                LogConfig
+	Tracing            TracingConfig
+	Assets             AssetsConfig
+	Bot                BotConfig
+	RateLimit          RateLimitConfig
+	Security           SecurityConfig
+	PII                PIIConfig
+	Privacy            PrivacyConfig
+	Retention          RetentionConfig
+}
+
+// DatabaseConfig holds the database connection settings
+type DatabaseConfig struct {
+	URL      string
+	Host     string
+	Port     int
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+}
+
+// SessionConfig holds the session settings
+type SessionConfig struct {
+	Lifetime        time.Duration
+	IdleTimeout     time.Duration
+	Store           string
+	Dir             string
+	CleanupInterval time.Duration
+}
+
+// CookieConfig holds the security settings shared by the session and csrf cookies
+type CookieConfig struct {
+	Secure   bool
+	SameSite string
+	Domain   string
+}
+
+// MailConfig holds the outgoing mail settings
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// LogConfig holds the logging settings
+type LogConfig struct {
+	Level  string
+	Format string
+}
+
+// TracingConfig holds the tracing settings
+type TracingConfig struct {
+	Exporter   string
+	File       string
+	SampleRate float64
+}
+
+// AssetsConfig holds where templates, static files and migrations are read from
+type AssetsConfig struct {
+	FromDisk bool
+	Dir      string
+}
+
+// BotConfig holds the settings of the bot checks on public forms
+type BotConfig struct {
+	Secret      string
+	MinFillTime time.Duration
+	MaxAge      time.Duration
+}
+
+// SecurityConfig holds the security headers sent with every response, and the proxies
+// trusted to tell who a request comes from
+type SecurityConfig struct {
+	CSP            string
+	CSPReportOnly  bool
+	HSTSMaxAge     time.Duration
+	TrustedProxies []netip.Prefix
+}
+
+// PIIConfig holds the master keys guest details are encrypted with and which columns are.
+// Keys are written as id=base64 and can be given directly, or in a file one per line.
+type PIIConfig struct {
+	Keys       string
+	KeysFile   string
+	PrimaryKey string
+	HashKey    string
+	Columns    []string
+}
+
+// PrivacyConfig holds the settings of the links guests are sent to see or erase their data
+type PrivacyConfig struct {
+	Secret  string
+	LinkTTL time.Duration
+}
+
+// RetentionConfig holds the settings of the job that anonymizes and deletes old data, with
+// the policy of each table it looks after
+type RetentionConfig struct {
+	Interval          time.Duration
+	DryRun            bool
+	Reservations      Retention
+	Messages          Retention
+	Sessions          Retention
+	Outbox            Retention
+	WebhookDeliveries Retention
+	AuditLog          Retention
+}
+
+// Retention anonymizes or deletes rows once they are older than After; no action keeps them
+type Retention struct {
+	Action string
+	After  time.Duration
+}
+
+// String returns the policy as it is configured, e.g. delete after 30d
+func (r Retention) String() string {
+	if r.Action == "" {
+		return "off"
+	}
+	return r.Action + " after " + retention.FormatAge(r.After)
+}
+
+// tables returns the policy of each table, by name
+func (c RetentionConfig) tables() map[string]Retention {
+	return map[string]Retention{
+		"reservations":       c.Reservations,
+		"messages":           c.Messages,
+		"sessions":           c.Sessions,
+		"outbox":             c.Outbox,
+		"webhook_deliveries": c.WebhookDeliveries,
+		"audit_log":          c.AuditLog,
+	}
+}
+
+// Policies returns the policies that are on, in the order the job applies them
+func (c RetentionConfig) Policies() []retention.Policy {
+	tables := c.tables()
+
+	var policies []retention.Policy
+	for _, t := range retention.Tables {
+		if r := tables[t.Name]; r.Action != "" {
+			policies = append(policies, retention.Policy{Table: t.Name, Action: r.Action, After: r.After})
+		}
+	}
+	return policies
+}
+
+// RateLimitConfig holds how requests are rate limited, with a limit for each group of routes
+type RateLimitConfig struct {
+	Store       string
+	Key         string
+	Search      RateLimit
+	Reservation RateLimit
+	Contact     RateLimit
+	Login       RateLimit
+	Privacy     RateLimit
+	CSPReport   RateLimit
+}
+
+// RateLimit allows Requests requests every Per, in bursts of up to Requests; no requests
+// means no limit
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// String returns the limit as it is configured, e.g. 30/1m0s
+func (l RateLimit) String() string {
+	if l.Requests == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// Addr returns the address the http server listens on
+func (a *AppConfig) Addr() string {
+	return fmt.Sprintf(":%d", a.Port)
+}
+
+// DSN returns the connection string for the database, preferring the URL when one is set
+func (d DatabaseConfig) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+
+	return fmt.Sprintf("host=%s port=%d dbname=%s user=%s password=%s sslmode=%s",
+		quoteDSN(d.Host), d.Port, quoteDSN(d.Name), quoteDSN(d.User), quoteDSN(d.Password), quoteDSN(d.SSLMode))
+}
+
+// SameSiteMode returns the http.SameSite value for the configured mode
+func (c CookieConfig) SameSiteMode() http.SameSite {
+	switch c.SameSite {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
+// Keyring returns the keyring of the configured keys, read from Keys and KeysFile
+func (p PIIConfig) Keyring() (*pii.Keyring, error) {
+	text := p.Keys
+	if p.KeysFile != "" {
+		b, err := os.ReadFile(p.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+		text += "\n" + string(b)
+	}
+
+	keys, err := pii.ParseKeys(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashKey []byte
+	if p.HashKey != "" {
+		hashKey, err = pii.DecodeKey(p.HashKey)
+		if err != nil {
+			return nil, fmt.Errorf("pii: hash key: %w", err)
+		}
+	}
+
+	return pii.New(keys, p.PrimaryKey, hashKey, p.Columns)
+}
+
+// Addr returns the host:port of the mail server
+func (m MailConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", m.Host, m.Port)
+}
+
+// quoteDSN quotes a value for a key=value connection string
+func quoteDSN(s string) string {
+	if s != "" && !strings.ContainsAny(s, ` '\`) {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// SlogLevel returns the minimum level that is logged, info if Level is not valid
+func (l LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(l.Level))
+	return level
 }
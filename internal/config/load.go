@@ -0,0 +1,670 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/clientip"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/pii"
+	"github.com/prashant9154/Booking_System/internal/retention"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of every environment variable read by Load
+const EnvPrefix = "BOOKINGS_"
+
+// callingCode matches a country calling code such as 1 or 44
+var callingCode = regexp.MustCompile(`^[1-9][0-9]{0,2}$`)
+
+// setting is a single configuration value that can come from the file, the environment or a flag
+type setting struct {
+	// key is the dotted path of the setting in the config file, e.g. "database.host"
+	key   string
+	usage string
+	// aliases are extra environment variables read for the setting
+	aliases []string
+	set     func(string) error
+}
+
+// flagName returns the command-line flag for the setting, e.g. -database-host
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+// envName returns the environment variable for the setting, e.g. BOOKINGS_DATABASE_HOST
+func (s setting) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(s.key))
+}
+
+// Load fills the config from, in increasing order of precedence: the defaults,
+// the section for the environment in the optional YAML config file, environment
+// variables and command-line flags. The environment (development, test or
+// production) is chosen with -env or BOOKINGS_ENV, and the file with -config or
+// BOOKINGS_CONFIG. The loaded config is validated before returning.
+func Load(a *AppConfig, args []string, getenv func(string) string) error {
+	fs := flag.NewFlagSet("bookings", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	configFile := fs.String("config", getenv(EnvPrefix+"CONFIG"), "path to the YAML config file")
+	env := fs.String("env", getenv(EnvPrefix+"ENV"), "environment: development, test or production")
+
+	settings := a.settings()
+	flags := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flags[s.key] = fs.String(s.flagName(), "", s.usage)
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	a.Env = *env
+	if a.Env == "" {
+		a.Env = "development"
+	}
+	a.defaults()
+
+	if *configFile != "" {
+		values, err := readFile(*configFile, a.Env)
+		if err != nil {
+			return err
+		}
+		for _, s := range settings {
+			if v, ok := values[s.key]; ok {
+				if err := s.set(v); err != nil {
+					return fmt.Errorf("%s in %s: %w", s.key, *configFile, err)
+				}
+			}
+		}
+	}
+
+	for _, s := range settings {
+		for _, name := range append([]string{s.envName()}, s.aliases...) {
+			if v := getenv(name); v != "" {
+				if err := s.set(v); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+				break
+			}
+		}
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range settings {
+		if set[s.flagName()] {
+			if err := s.set(*flags[s.key]); err != nil {
+				return fmt.Errorf("-%s: %w", s.flagName(), err)
+			}
+		}
+	}
+
+	return a.Validate()
+}
+
+// LoadFromOS loads the config from the given arguments and the process environment
+func LoadFromOS(a *AppConfig, args []string) error {
+	return Load(a, args, os.Getenv)
+}
+
+// Usage writes the list of flags and environment variables understood by Load
+func Usage(w io.Writer) {
+	var a AppConfig
+	fmt.Fprintln(w, "  -config, BOOKINGS_CONFIG\n\tpath to the YAML config file")
+	fmt.Fprintln(w, "  -env, BOOKINGS_ENV\n\tenvironment: development, test or production")
+	for _, s := range a.settings() {
+		fmt.Fprintf(w, "  -%s, %s\n\t%s\n", s.flagName(), s.envName(), s.usage)
+	}
+}
+
+// defaults sets the default values for the environment
+func (a *AppConfig) defaults() {
+	production := a.Env == "production"
+
+	a.Port = 8080
+	a.AdminAddr = "127.0.0.1:9090"
+	a.BaseURL = "http://localhost:8080"
+	a.ShutdownTimeout = 30 * time.Second
+	a.ShutdownDrainDelay = 0
+	if production {
+		a.ShutdownDrainDelay = 5 * time.Second
+	}
+	a.InProduction = production
+	a.UseCache = production
+
+	a.Database = DatabaseConfig{
+		Host:    "localhost",
+		Port:    5432,
+		Name:    "bookings",
+		SSLMode: "disable",
+	}
+
+	a.Sessions = SessionConfig{
+		Lifetime:        24 * time.Hour,
+		Store:           "memory",
+		Dir:             "sessions",
+		CleanupInterval: 5 * time.Minute,
+	}
+	if production {
+		a.Sessions.Store = "postgres"
+	}
+
+	a.Cookie = CookieConfig{
+		Secure:   production,
+		SameSite: "lax",
+	}
+
+	a.DefaultLocale = "en"
+
+	a.Mail = MailConfig{
+		Host: "localhost",
+		Port: 1025,
+		From: "me@here.com",
+	}
+
+	a.Log = LogConfig{
+		Level:  "info",
+		Format: "text",
+	}
+	if production {
+		a.Log.Format = "json"
+	}
+
+	// in development templates and static files are read from the working tree, so
+	// changes show up without rebuilding
+	a.Assets = AssetsConfig{
+		FromDisk: a.Env == "development",
+		Dir:      ".",
+	}
+
+	a.Bot = BotConfig{
+		MinFillTime: 3 * time.Second,
+		MaxAge:      2 * time.Hour,
+	}
+
+	a.RateLimit = RateLimitConfig{
+		Store:       "memory",
+		Key:         "ip",
+		Search:      RateLimit{Requests: 30, Per: time.Minute},
+		Reservation: RateLimit{Requests: 10, Per: time.Minute},
+		Contact:     RateLimit{Requests: 5, Per: time.Minute},
+		Login:       RateLimit{Requests: 10, Per: time.Minute},
+		Privacy:     RateLimit{Requests: 5, Per: time.Minute},
+		CSPReport:   RateLimit{Requests: 60, Per: time.Minute},
+	}
+
+	a.Security = SecurityConfig{
+		CSP: "default-src 'self'; " +
+			"script-src 'self' 'nonce-{nonce}' https://cdn.jsdelivr.net https://code.jquery.com https://unpkg.com; " +
+			"style-src 'self' 'nonce-{nonce}' https://cdn.jsdelivr.net https://unpkg.com; " +
+			"img-src 'self' data:; font-src 'self' https://cdn.jsdelivr.net; connect-src 'self'; " +
+			"object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+		HSTSMaxAge: 365 * 24 * time.Hour,
+	}
+
+	a.PII = PIIConfig{
+		Columns: append([]string(nil), pii.Columns...),
+	}
+
+	a.Privacy = PrivacyConfig{
+		LinkTTL: time.Hour,
+	}
+
+	// the job only records what it would change until dry_run is turned off, so no data
+	// is lost before the policies were agreed on
+	a.Retention = RetentionConfig{
+		Interval:          retention.Day,
+		DryRun:            true,
+		Reservations:      Retention{Action: retention.Anonymize, After: 3 * retention.Year},
+		Messages:          Retention{Action: retention.Anonymize, After: 3 * retention.Year},
+		Sessions:          Retention{Action: retention.Delete, After: 30 * retention.Day},
+		Outbox:            Retention{Action: retention.Delete, After: 30 * retention.Day},
+		WebhookDeliveries: Retention{Action: retention.Delete, After: 30 * retention.Day},
+	}
+
+	a.Tracing = TracingConfig{
+		Exporter:   "none",
+		File:       "traces.json",
+		SampleRate: 1,
+	}
+	if production {
+		a.Tracing.SampleRate = 0.1
+	}
+}
+
+// settings lists every configurable value
+func (a *AppConfig) settings() []setting {
+	return []setting{
+		{key: "port", usage: "port the http server listens on", set: intVar(&a.Port)},
+		{key: "admin_addr", usage: "address the admin server serving /metrics listens on, kept off the public port; empty turns it off", set: stringVar(&a.AdminAddr)},
+		{key: "base_url", usage: "address the site is reached at, used in the links sent by email", set: stringVar(&a.BaseURL)},
+		{key: "shutdown_timeout", usage: "how long to wait for requests and background work to finish on shutdown", set: durationVar(&a.ShutdownTimeout)},
+		{key: "shutdown_drain_delay", usage: "how long readiness fails before the server stops accepting connections", set: durationVar(&a.ShutdownDrainDelay)},
+		{key: "in_production", usage: "run in production mode", set: boolVar(&a.InProduction)},
+		{key: "use_cache", usage: "build the template cache once instead of reloading templates when they change", set: boolVar(&a.UseCache)},
+		{key: "default_locale", usage: "language of visitors who do not ask for an available one: " + strings.Join(i18n.Tags(), ", "), set: stringVar(&a.DefaultLocale)},
+		{key: "default_calling_code", usage: "country calling code, e.g. 1, of phone numbers entered without one; unset, such numbers are rejected", set: stringVar(&a.DefaultCallingCode)},
+
+		{key: "assets.from_disk", usage: "read templates, static files and migrations from assets.dir instead of the binary, for development", set: boolVar(&a.Assets.FromDisk)},
+		{key: "assets.dir", usage: "directory holding the templates, static and migrations directories", set: stringVar(&a.Assets.Dir)},
+
+		{key: "database.url", usage: "database connection url, overrides the other database settings", aliases: []string{"DATABASE_URL"}, set: stringVar(&a.Database.URL)},
+		{key: "database.host", usage: "database host", set: stringVar(&a.Database.Host)},
+		{key: "database.port", usage: "database port", set: intVar(&a.Database.Port)},
+		{key: "database.name", usage: "database name", set: stringVar(&a.Database.Name)},
+		{key: "database.user", usage: "database user", set: stringVar(&a.Database.User)},
+		{key: "database.password", usage: "database password", set: stringVar(&a.Database.Password)},
+		{key: "database.sslmode", usage: "database ssl mode", set: stringVar(&a.Database.SSLMode)},
+
+		{key: "session.lifetime", usage: "maximum lifetime of a session, e.g. 24h", set: durationVar(&a.Sessions.Lifetime)},
+		{key: "session.idle_timeout", usage: "expire sessions inactive for this long, 0 to disable", set: durationVar(&a.Sessions.IdleTimeout)},
+		{key: "session.store", usage: "where sessions are kept: memory, postgres or file", set: stringVar(&a.Sessions.Store)},
+		{key: "session.dir", usage: "directory the file session store writes to", set: stringVar(&a.Sessions.Dir)},
+		{key: "session.cleanup_interval", usage: "how often expired sessions are deleted", set: durationVar(&a.Sessions.CleanupInterval)},
+
+		{key: "cookie.secure", usage: "only send cookies over https", set: boolVar(&a.Cookie.Secure)},
+		{key: "cookie.same_site", usage: "SameSite mode of cookies: lax, strict or none", set: stringVar(&a.Cookie.SameSite)},
+		{key: "cookie.domain", usage: "domain of cookies, empty for the request host", set: stringVar(&a.Cookie.Domain)},
+
+		{key: "mail.host", usage: "smtp host", set: stringVar(&a.Mail.Host)},
+		{key: "mail.port", usage: "smtp port", set: intVar(&a.Mail.Port)},
+		{key: "mail.username", usage: "smtp username, empty for no authentication", set: stringVar(&a.Mail.Username)},
+		{key: "mail.password", usage: "smtp password", set: stringVar(&a.Mail.Password)},
+		{key: "mail.from", usage: "from address of outgoing mail", set: stringVar(&a.Mail.From)},
+
+		{key: "log.level", usage: "minimum level logged: debug, info, warn or error", set: stringVar(&a.Log.Level)},
+		{key: "log.format", usage: "log format: json or text", set: stringVar(&a.Log.Format)},
+
+		{key: "bot.secret", usage: "key signing the form tokens of the bot checks, shared by every instance; random when empty, and required in production", set: stringVar(&a.Bot.Secret)},
+		{key: "bot.min_fill_time", usage: "forms submitted sooner than this after they were shown are taken for bots", set: durationVar(&a.Bot.MinFillTime)},
+		{key: "bot.max_age", usage: "how long a form can be left open before it must be shown again", set: durationVar(&a.Bot.MaxAge)},
+
+		{key: "rate_limit.store", usage: "where rate limit counts are kept: memory, or postgres to share them between instances", set: stringVar(&a.RateLimit.Store)},
+		{key: "rate_limit.key", usage: "who a rate limit applies to: ip, or session, which falls back to the ip for requests without a stored session", set: stringVar(&a.RateLimit.Key)},
+		{key: "rate_limit.search", usage: "limit of availability searches, e.g. 30/1m, or off", set: rateLimitVar(&a.RateLimit.Search)},
+		{key: "rate_limit.reservation", usage: "limit of make-reservation requests", set: rateLimitVar(&a.RateLimit.Reservation)},
+		{key: "rate_limit.contact", usage: "limit of contact form submissions", set: rateLimitVar(&a.RateLimit.Contact)},
+		{key: "rate_limit.login", usage: "limit of login attempts", set: rateLimitVar(&a.RateLimit.Login)},
+		{key: "rate_limit.privacy", usage: "limit of requests for a link to a guest's data", set: rateLimitVar(&a.RateLimit.Privacy)},
+		{key: "rate_limit.csp_report", usage: "limit of Content Security Policy violation reports", set: rateLimitVar(&a.RateLimit.CSPReport)},
+
+		{key: "security.csp", usage: "Content Security Policy of the pages; {nonce} is replaced by the nonce of each response", set: stringVar(&a.Security.CSP)},
+		{key: "security.csp_report_only", usage: "only report Content Security Policy violations to /csp-report instead of blocking them", set: boolVar(&a.Security.CSPReportOnly)},
+		{key: "security.hsts_max_age", usage: "how long browsers only use https for the site once told to in production, 0 to not tell them", set: durationVar(&a.Security.HSTSMaxAge)},
+		{key: "security.trusted_proxies", usage: "comma separated addresses or networks, e.g. 10.0.0.0/8, of the proxies whose X-Forwarded-For and Forwarded headers are believed", set: prefixesVar(&a.Security.TrustedProxies)},
+
+		{key: "pii.keys", usage: "comma separated master keys guest details are encrypted with, as id=base64 of 32 bytes; none leaves them in plain text", set: stringVar(&a.PII.Keys)},
+		{key: "pii.keys_file", usage: "file of more master keys, one id=base64 per line", set: stringVar(&a.PII.KeysFile)},
+		{key: "pii.primary_key", usage: "id of the key new values are encrypted with, the last one listed by default", set: stringVar(&a.PII.PrimaryKey)},
+		{key: "pii.hash_key", usage: "base64 key of at least 32 bytes of the hashes encrypted emails are looked up by", set: stringVar(&a.PII.HashKey)},
+		{key: "pii.columns", usage: "comma separated columns to encrypt, of " + strings.Join(pii.Columns, ", "), set: listVar(&a.PII.Columns)},
+
+		{key: "privacy.secret", usage: "secret the links guests are sent to see or erase their data are signed with; random, so lost on restart, when empty, and required in production", set: stringVar(&a.Privacy.Secret)},
+		{key: "privacy.link_ttl", usage: "how long the links guests are sent to see or erase their data work", set: durationVar(&a.Privacy.LinkTTL)},
+
+		{key: "retention.interval", usage: "how often the retention job runs, 0 to not run it", set: durationVar(&a.Retention.Interval)},
+		{key: "retention.dry_run", usage: "only record what the retention job would change; on until set to false", set: boolVar(&a.Retention.DryRun)},
+		{key: "retention.reservations", usage: "what happens to reservations after check out, e.g. anonymize after 3y, or off", set: retentionVar(&a.Retention.Reservations)},
+		{key: "retention.messages", usage: "what happens to contact messages once sent, e.g. anonymize after 3y or delete after 1y", set: retentionVar(&a.Retention.Messages)},
+		{key: "retention.sessions", usage: "when sessions are deleted after expiring, e.g. delete after 30d", set: retentionVar(&a.Retention.Sessions)},
+		{key: "retention.outbox", usage: "when events are deleted from the outbox once dispatched", set: retentionVar(&a.Retention.Outbox)},
+		{key: "retention.webhook_deliveries", usage: "when delivered and failed webhook deliveries are deleted", set: retentionVar(&a.Retention.WebhookDeliveries)},
+		{key: "retention.audit_log", usage: "when audit log entries are deleted, off to keep them", set: retentionVar(&a.Retention.AuditLog)},
+
+		{key: "tracing.exporter", usage: "where spans are exported: none, stdout or file", set: stringVar(&a.Tracing.Exporter)},
+		{key: "tracing.file", usage: "file spans are appended to by the file exporter", set: stringVar(&a.Tracing.File)},
+		{key: "tracing.sample_rate", usage: "fraction of new traces that are sampled, from 0 to 1", set: floatVar(&a.Tracing.SampleRate)},
+	}
+}
+
+// Validate checks the config for missing or inconsistent values
+func (a *AppConfig) Validate() error {
+	var errs []error
+
+	switch a.Env {
+	case "development", "test", "production":
+	default:
+		errs = append(errs, fmt.Errorf("env must be development, test or production, not %q", a.Env))
+	}
+
+	if a.Port < 1 || a.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", a.Port))
+	}
+
+	if a.AdminAddr != "" {
+		if _, port, err := net.SplitHostPort(a.AdminAddr); err != nil || port == "" || port == strconv.Itoa(a.Port) {
+			errs = append(errs, fmt.Errorf("admin_addr %q must be a host:port other than the http server's port", a.AdminAddr))
+		}
+	}
+
+	if u, err := url.Parse(a.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base_url %q must be an http or https address", a.BaseURL))
+	}
+
+	if a.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown_timeout must be positive"))
+	}
+
+	if a.ShutdownDrainDelay < 0 || a.ShutdownDrainDelay >= a.ShutdownTimeout {
+		errs = append(errs, errors.New("shutdown_drain_delay must be between 0 and shutdown_timeout"))
+	}
+
+	if a.Database.URL == "" {
+		if a.Database.Host == "" {
+			errs = append(errs, errors.New("database.host is required"))
+		}
+		if a.Database.Name == "" {
+			errs = append(errs, errors.New("database.name is required"))
+		}
+		if a.Database.Port < 1 || a.Database.Port > 65535 {
+			errs = append(errs, fmt.Errorf("database.port %d is out of range", a.Database.Port))
+		}
+	}
+
+	if a.Sessions.Lifetime <= 0 {
+		errs = append(errs, errors.New("session.lifetime must be positive"))
+	}
+	if a.Sessions.IdleTimeout < 0 {
+		errs = append(errs, errors.New("session.idle_timeout cannot be negative"))
+	}
+	switch a.Sessions.Store {
+	case "memory", "postgres":
+	case "file":
+		if a.Sessions.Dir == "" {
+			errs = append(errs, errors.New("session.dir is required by the file session store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("session.store must be memory, postgres or file, not %q", a.Sessions.Store))
+	}
+	if a.Sessions.CleanupInterval <= 0 {
+		errs = append(errs, errors.New("session.cleanup_interval must be positive"))
+	}
+
+	switch a.Cookie.SameSite {
+	case "lax", "strict":
+	case "none":
+		if !a.Cookie.Secure {
+			errs = append(errs, errors.New("cookie.same_site none requires cookie.secure"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cookie.same_site must be lax, strict or none, not %q", a.Cookie.SameSite))
+	}
+
+	if a.InProduction && !a.Cookie.Secure {
+		errs = append(errs, errors.New("cookie.secure must be enabled in production"))
+	}
+	if a.InProduction && !a.UseCache {
+		errs = append(errs, errors.New("use_cache must be enabled in production"))
+	}
+
+	if a.Mail.Host == "" {
+		errs = append(errs, errors.New("mail.host is required"))
+	}
+	if a.Mail.Port < 1 || a.Mail.Port > 65535 {
+		errs = append(errs, fmt.Errorf("mail.port %d is out of range", a.Mail.Port))
+	}
+	if !strings.Contains(a.Mail.From, "@") {
+		errs = append(errs, fmt.Errorf("mail.from %q is not an email address", a.Mail.From))
+	}
+
+	if _, ok := i18n.Get(a.DefaultLocale); !ok {
+		errs = append(errs, fmt.Errorf("default_locale must be one of %s, not %q", strings.Join(i18n.Tags(), ", "), a.DefaultLocale))
+	}
+	if a.DefaultCallingCode != "" && !callingCode.MatchString(a.DefaultCallingCode) {
+		errs = append(errs, fmt.Errorf("default_calling_code must be 1 to 3 digits without the +, not %q", a.DefaultCallingCode))
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(a.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, not %q", a.Log.Level))
+	}
+	switch a.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format must be json or text, not %q", a.Log.Format))
+	}
+
+	if a.Bot.MinFillTime < 0 || a.Bot.MinFillTime >= a.Bot.MaxAge {
+		errs = append(errs, errors.New("bot.min_fill_time must be between 0 and bot.max_age"))
+	}
+	if a.InProduction && a.Bot.Secret == "" {
+		errs = append(errs, errors.New("bot.secret is required in production"))
+	}
+
+	switch a.RateLimit.Store {
+	case "memory", "postgres":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.store must be memory or postgres, not %q", a.RateLimit.Store))
+	}
+	switch a.RateLimit.Key {
+	case "ip", "session":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.key must be ip or session, not %q", a.RateLimit.Key))
+	}
+
+	if strings.TrimSpace(a.Security.CSP) == "" {
+		errs = append(errs, errors.New("security.csp is required"))
+	}
+	if a.Security.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("security.hsts_max_age cannot be negative"))
+	}
+
+	for _, c := range a.PII.Columns {
+		if !pii.Supported(c) {
+			errs = append(errs, fmt.Errorf("pii.columns must be some of %s, not %q", strings.Join(pii.Columns, ", "), c))
+		}
+	}
+	if (a.PII.Keys != "" || a.PII.KeysFile != "") && a.PII.HashKey == "" {
+		errs = append(errs, errors.New("pii.hash_key is required to encrypt guest details"))
+	}
+
+	if a.Privacy.LinkTTL <= 0 {
+		errs = append(errs, errors.New("privacy.link_ttl must be positive"))
+	}
+	if a.InProduction && a.Privacy.Secret == "" {
+		errs = append(errs, errors.New("privacy.secret is required in production"))
+	}
+
+	if a.Retention.Interval < 0 {
+		errs = append(errs, errors.New("retention.interval cannot be negative"))
+	}
+	tables := a.Retention.tables()
+	for _, t := range retention.Tables {
+		if r := tables[t.Name]; r.Action != "" && !retention.Supports(t.Name, r.Action) {
+			errs = append(errs, fmt.Errorf("retention.%s can only %s rows, not %s them", t.Name, strings.Join(t.Actions, " or "), r.Action))
+		}
+	}
+
+	if a.Tracing.SampleRate < 0 || a.Tracing.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_rate %g must be between 0 and 1", a.Tracing.SampleRate))
+	}
+	if a.Tracing.Exporter == "file" && a.Tracing.File == "" {
+		errs = append(errs, errors.New("tracing.file is required by the file exporter"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// readFile reads the section for env from a YAML config file, flattened to dotted keys
+func readFile(path, env string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	err = yaml.Unmarshal(b, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	section, ok := doc[env]
+	if !ok {
+		return nil, fmt.Errorf("%s has no %s section", path, env)
+	}
+
+	values := map[string]string{}
+	flatten("", section, values)
+	return values, nil
+}
+
+// flatten turns nested YAML maps into dotted keys
+func flatten(prefix string, v interface{}, out map[string]string) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for k, child := range v {
+			key := fmt.Sprint(k)
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, child, out)
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(items, ",")
+	case nil:
+		out[prefix] = ""
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
+
+func stringVar(p *string) func(string) error {
+	return func(v string) error {
+		*p = v
+		return nil
+	}
+}
+
+func intVar(p *int) func(string) error {
+	return func(v string) error {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*p = i
+		return nil
+	}
+}
+
+func floatVar(p *float64) func(string) error {
+	return func(v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*p = f
+		return nil
+	}
+}
+
+func boolVar(p *bool) func(string) error {
+	return func(v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", v)
+		}
+		*p = b
+		return nil
+	}
+}
+
+// rateLimitVar reads a limit written as requests/period, e.g. 30/1m, or off, which YAML reads as false
+func rateLimitVar(p *RateLimit) func(string) error {
+	return func(v string) error {
+		if v == "off" || v == "false" || v == "0" {
+			*p = RateLimit{}
+			return nil
+		}
+
+		n, per, ok := strings.Cut(v, "/")
+		requests, err1 := strconv.Atoi(n)
+		period, err2 := time.ParseDuration(per)
+		if !ok || err1 != nil || err2 != nil || requests < 0 || period <= 0 {
+			return fmt.Errorf("%q is not a rate limit such as 30/1m", v)
+		}
+
+		*p = RateLimit{Requests: requests, Per: period}
+		return nil
+	}
+}
+
+// retentionVar reads a policy written as action after age, e.g. delete after 30d, or off,
+// which YAML reads as false
+func retentionVar(p *Retention) func(string) error {
+	return func(v string) error {
+		if v == "off" || v == "false" || v == "0" {
+			*p = Retention{}
+			return nil
+		}
+
+		action, age, ok := strings.Cut(strings.TrimSpace(v), " after ")
+		after, err := retention.ParseAge(age)
+		if !ok || err != nil || (action != retention.Anonymize && action != retention.Delete) {
+			return fmt.Errorf("%q is not a retention policy such as delete after 30d", v)
+		}
+
+		*p = Retention{Action: action, After: after}
+		return nil
+	}
+}
+
+// listVar reads a comma separated list
+func listVar(p *[]string) func(string) error {
+	return func(v string) error {
+		var items []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		*p = items
+		return nil
+	}
+}
+
+// prefixesVar reads a comma separated list of networks, or single addresses
+func prefixesVar(p *[]netip.Prefix) func(string) error {
+	return func(v string) error {
+		var prefixes []netip.Prefix
+		for _, s := range strings.Split(v, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			prefix, err := clientip.ParsePrefix(s)
+			if err != nil {
+				return err
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		*p = prefixes
+		return nil
+	}
+}
+
+func durationVar(p *time.Duration) func(string) error {
+	return func(v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", v)
+		}
+		*p = d
+		return nil
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string {
+		return vars[k]
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	var a AppConfig
+
+	err := Load(&a, nil, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Env != "development" || a.Port != 8080 || a.InProduction || a.UseCache || !a.Assets.FromDisk {
+		t.Errorf("unexpected development defaults: %+v", a)
+	}
+
+	if a.RateLimit.Search != (RateLimit{Requests: 30, Per: time.Minute}) {
+		t.Errorf("expected a default search limit of 30/1m but got %s", a.RateLimit.Search)
+	}
+
+	if !a.Retention.DryRun {
+		t.Error("expected the retention job to be a dry run by default")
+	}
+
+	if a.Sessions.Lifetime != 24*time.Hour {
+		t.Errorf("expected default session lifetime of 24h but got %s", a.Sessions.Lifetime)
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yml")
+	err := os.WriteFile(file, []byte(`
+development:
+  port: 9000
+  database:
+    host: filehost
+    name: filedb
+    user: fileuser
+  mail:
+    from: file@here.com
+  security:
+    trusted_proxies: [10.0.0.0/8, 192.0.2.1]
+  retention:
+    messages: delete after 1y
+    audit_log: delete after 2190d
+    sessions: off
+production:
+  port: 1
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var a AppConfig
+	err = Load(&a,
+		[]string{"-config", file, "-database-user", "flaguser"},
+		env(map[string]string{
+			"BOOKINGS_DATABASE_HOST": "envhost",
+			"BOOKINGS_DATABASE_USER": "envuser",
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Port != 9000 {
+		t.Errorf("expected port from file but got %d", a.Port)
+	}
+	if a.Database.Name != "filedb" {
+		t.Errorf("expected database name from file but got %s", a.Database.Name)
+	}
+	if a.Database.Host != "envhost" {
+		t.Errorf("expected env to override file but got %s", a.Database.Host)
+	}
+	if a.Database.User != "flaguser" {
+		t.Errorf("expected flag to override env but got %s", a.Database.User)
+	}
+	if a.Mail.From != "file@here.com" {
+		t.Errorf("expected mail from address from file but got %s", a.Mail.From)
+	}
+	if len(a.Security.TrustedProxies) != 2 || a.Security.TrustedProxies[1].String() != "192.0.2.1/32" {
+		t.Errorf("expected the list of trusted proxies from file but got %v", a.Security.TrustedProxies)
+	}
+	policies := []string{}
+	for _, p := range a.Retention.Policies() {
+		policies = append(policies, p.String())
+	}
+	expected := "anonymize reservations after 1095d, delete messages after 365d, " +
+		"delete outbox after 30d, delete webhook_deliveries after 30d, delete audit_log after 2190d"
+	if strings.Join(policies, ", ") != expected {
+		t.Errorf("expected the retention policies from file but got %v", policies)
+	}
+	if !strings.Contains(a.Database.DSN(), "host=envhost") {
+		t.Errorf("unexpected dsn %s", a.Database.DSN())
+	}
+}
+
+func TestLoad_Production(t *testing.T) {
+	var a AppConfig
+
+	secrets := map[string]string{"BOOKINGS_BOT_SECRET": "bot secret", "BOOKINGS_PRIVACY_SECRET": "privacy secret"}
+
+	err := Load(&a, []string{"-env", "production"}, env(secrets))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !a.InProduction || !a.UseCache || a.Assets.FromDisk || !a.Cookie.Secure || a.Log.Format != "json" || a.Sessions.Store != "postgres" {
+		t.Errorf("unexpected production defaults: %+v", a)
+	}
+
+	err = Load(&a, []string{"-env", "production", "-cookie-secure=false"}, env(secrets))
+	if err == nil {
+		t.Error("insecure cookies allowed in production")
+	}
+
+	err = Load(&a, []string{"-env", "production", "-use-cache=false"}, env(secrets))
+	if err == nil {
+		t.Error("template reloading allowed in production")
+	}
+
+	for name := range secrets {
+		without := map[string]string{}
+		for k, v := range secrets {
+			if k != name {
+				without[k] = v
+			}
+		}
+
+		err = Load(&a, []string{"-env", "production"}, env(without))
+		if err == nil {
+			t.Errorf("production allowed without %s", name)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{"bad env", []string{"-env", "staging"}, nil},
+		{"bad port", []string{"-port", "70000"}, nil},
+		{"admin addr without a port", []string{"-admin-addr", "localhost"}, nil},
+		{"admin addr on the http port", []string{"-admin-addr", ":8080"}, nil},
+		{"relative base url", []string{"-base-url", "/bookings"}, nil},
+		{"not a number", nil, map[string]string{"BOOKINGS_PORT": "eighty"}},
+		{"bad same site", []string{"-cookie-same-site", "sometimes"}, nil},
+		{"same site none without secure", []string{"-cookie-same-site", "none"}, nil},
+		{"bad mail from", []string{"-mail-from", "nobody"}, nil},
+		{"bad session store", []string{"-session-store", "redis"}, nil},
+		{"bad log level", []string{"-log-level", "loud"}, nil},
+		{"unknown default locale", []string{"-default-locale", "xx"}, nil},
+		{"calling code with a plus", []string{"-default-calling-code", "+1"}, nil},
+		{"bad log format", nil, map[string]string{"BOOKINGS_LOG_FORMAT": "xml"}},
+		{"bot min fill time above max age", []string{"-bot-min-fill-time", "3h"}, nil},
+		{"bad rate limit", []string{"-rate-limit-search", "lots"}, nil},
+		{"rate limit without a period", nil, map[string]string{"BOOKINGS_RATE_LIMIT_LOGIN": "10"}},
+		{"bad rate limit store", []string{"-rate-limit-store", "redis"}, nil},
+		{"bad rate limit key", []string{"-rate-limit-key", "email"}, nil},
+		{"rate limit by token", []string{"-rate-limit-key", "token"}, nil},
+		{"bad trusted proxy", []string{"-security-trusted-proxies", "10.0.0.0/8,proxy"}, nil},
+		{"no csp", nil, map[string]string{"BOOKINGS_SECURITY_CSP": " "}},
+		{"unknown pii column", []string{"-pii-columns", "reservations.email,users.password"}, nil},
+		{"pii keys without a hash key", nil, map[string]string{"BOOKINGS_PII_KEYS": "one=AAAA"}},
+		{"no privacy link lifetime", []string{"-privacy-link-ttl", "0s"}, nil},
+		{"negative retention interval", []string{"-retention-interval", "-1h"}, nil},
+		{"retention policy without an age", []string{"-retention-sessions", "delete"}, nil},
+		{"unknown retention action", []string{"-retention-sessions", "archive after 30d"}, nil},
+		{"deleting reservations", nil, map[string]string{"BOOKINGS_RETENTION_RESERVATIONS": "delete after 3y"}},
+		{"anonymizing sessions", []string{"-retention-sessions", "anonymize after 30d"}, nil},
+		{"sample rate above 1", []string{"-tracing-sample-rate", "1.5"}, nil},
+		{"sample rate not a number", nil, map[string]string{"BOOKINGS_TRACING_SAMPLE_RATE": "most"}},
+		{"missing config file", []string{"-config", "does-not-exist.yml"}, nil},
+		{"unknown flag", []string{"-colour", "blue"}, nil},
+	}
+
+	for _, e := range tests {
+		var a AppConfig
+		err := Load(&a, e.args, env(e.env))
+		if err == nil {
+			t.Errorf("%s: expected an error", e.name)
+		}
+	}
+}
+
+func TestDatabaseConfig_DSN(t *testing.T) {
+	d := DatabaseConfig{URL: "postgres://x"}
+	if d.DSN() != "postgres://x" {
+		t.Error("url not preferred")
+	}
+
+	d = DatabaseConfig{Host: "h", Port: 5432, Name: "n", User: "u", Password: "it's secret", SSLMode: "disable"}
+	expected := `host=h port=5432 dbname=n user=u password='it\'s secret' sslmode=disable`
+	if d.DSN() != expected {
+		t.Errorf("expected %s but got %s", expected, d.DSN())
+	}
+}
+
+func TestPIIConfig_Keyring(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+
+	file := filepath.Join(t.TempDir(), "keys")
+	err := os.WriteFile(file, []byte("# retired next year\nold="+key+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := PIIConfig{Keys: "new=" + key, KeysFile: file, PrimaryKey: "new", HashKey: key, Columns: []string{"reservations.email"}}
+	k, err := p.Keyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.Encrypts("reservations.email") || k.Encrypts("reservations.phone") {
+		t.Error("unexpected columns encrypted")
+	}
+
+	sealed, _ := k.Seal("reservations.email", "guest@example.com")
+	if !strings.HasPrefix(sealed, "enc:v1:new:") {
+		t.Errorf("not encrypted with the primary key: %q", sealed)
+	}
+
+	p.KeysFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := p.Keyring(); err == nil {
+		t.Error("missing keys file was ignored")
+	}
+
+	k, err = PIIConfig{Columns: []string{"reservations.email"}}.Keyring()
+	if err != nil || k.Enabled() {
+		t.Errorf("expected no encryption without keys but got %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+package driver
+
+import "testing"
+
+func TestConnectSQL_Unreachable(t *testing.T) {
+	_, err := ConnectSQL("host=127.0.0.1 port=1 dbname=bookings user=nobody connect_timeout=1")
+	if err == nil {
+		t.Error("expected an error connecting to an unreachable database")
+	}
+}
@@ -0,0 +1,206 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// Handler handles a dispatched event. Events are delivered at least once, so
+// handlers must tolerate seeing the same event id more than once.
+type Handler func(e models.Event) error
+
+// Store is the outbox persistence the dispatcher needs; it is satisfied by repository.DatabaseRepo
+type Store interface {
+	ClaimOutboxEvents(ctx context.Context, now, until time.Time, maxAttempts, limit int) ([]models.Event, error)
+	MarkOutboxEventDispatched(ctx context.Context, id string) error
+	MarkOutboxEventFailed(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error
+}
+
+// HandledStore records which handlers have handled which events; it is satisfied by repository.DatabaseRepo
+type HandledStore interface {
+	OutboxEventHandled(ctx context.Context, id, handler string) (bool, error)
+	MarkOutboxEventHandled(ctx context.Context, id, handler string) error
+}
+
+// Dispatcher reads events from the outbox and delivers them to the subscribed handlers
+type Dispatcher struct {
+	Store  Store
+	Logger *slog.Logger
+
+	// PollInterval is how often the outbox is checked for new events
+	PollInterval time.Duration
+	// BaseBackoff is the delay before the first retry of a failed event; it doubles on every attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of failed dispatches after which an event is left alone
+	MaxAttempts int
+	// BatchSize is the maximum number of events dispatched per poll
+	BatchSize int
+	// Lease is how long the events of a batch are claimed by this dispatcher; once it
+	// expires, events that were not marked dispatched or failed are claimed again
+	Lease time.Duration
+
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher with default settings
+func NewDispatcher(store Store, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		Store:        store,
+		Logger:       logger,
+		PollInterval: time.Second,
+		BaseBackoff:  5 * time.Second,
+		MaxBackoff:   time.Hour,
+		MaxAttempts:  25,
+		BatchSize:    50,
+		Lease:        time.Minute,
+		now:          time.Now,
+		handlers:     map[string][]Handler{},
+	}
+}
+
+// Subscribe registers a handler for an event type
+func (d *Dispatcher) Subscribe(eventType string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[eventType] = append(d.handlers[eventType], h)
+}
+
+// Start runs the dispatcher in the background until Stop is called
+func (d *Dispatcher) Start() {
+	d.quit = make(chan struct{})
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.quit:
+				return
+			case <-ticker.C:
+				d.ProcessPending()
+			}
+		}
+	}()
+}
+
+// Stop stops the dispatcher and waits for the current batch to finish
+func (d *Dispatcher) Stop() {
+	if d.quit == nil {
+		return
+	}
+	close(d.quit)
+	d.wg.Wait()
+	d.quit = nil
+}
+
+// ProcessPending claims and dispatches every pending event, returning how many were
+// dispatched successfully. Events claimed by another instance are left alone until its
+// lease expires.
+func (d *Dispatcher) ProcessPending() int {
+	ctx := context.Background()
+
+	now := d.now()
+	pending, err := d.Store.ClaimOutboxEvents(ctx, now, now.Add(d.Lease), d.MaxAttempts, d.BatchSize)
+	if err != nil {
+		d.Logger.Error("cannot load outbox events", "error", err)
+		return 0
+	}
+
+	dispatched := 0
+	for _, e := range pending {
+		err := d.dispatch(e)
+		if err != nil {
+			attempts := e.Attempts + 1
+			d.Logger.Error("event dispatch failed", "event_id", e.ID, "event", e.Type, "attempt", attempts, "error", err)
+			if attempts >= d.MaxAttempts {
+				d.Logger.Error("outbox event abandoned after its last attempt", "event_id", e.ID, "event", e.Type, "attempts", attempts)
+				metrics.OutboxEventsAbandoned.WithLabelValues(e.Type).Inc()
+			}
+
+			err = d.Store.MarkOutboxEventFailed(ctx, e.ID, err.Error(), now.Add(d.Backoff(attempts)))
+			if err != nil {
+				d.Logger.Error("cannot mark outbox event as failed", "event_id", e.ID, "error", err)
+			}
+			continue
+		}
+
+		err = d.Store.MarkOutboxEventDispatched(ctx, e.ID)
+		if err != nil {
+			d.Logger.Error("cannot mark outbox event as dispatched", "event_id", e.ID, "error", err)
+			continue
+		}
+		dispatched++
+	}
+
+	return dispatched
+}
+
+// Backoff returns the delay before the next attempt of an event that failed attempts times
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// dispatch calls every handler subscribed to the event's type, stopping at the first error
+func (d *Dispatcher) dispatch(e models.Event) error {
+	d.mu.RLock()
+	handlers := d.handlers[e.Type]
+	d.mu.RUnlock()
+
+	for _, h := range handlers {
+		err := h(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Once wraps the handler named name so that it is not called again for an event it has
+// already handled successfully. What was handled is recorded in store, so it holds across
+// restarts and instances; a handler that fails is called again when the event is retried.
+func Once(store HandledStore, name string, h Handler) Handler {
+	return func(e models.Event) error {
+		ctx := context.Background()
+
+		handled, err := store.OutboxEventHandled(ctx, e.ID, name)
+		if err != nil {
+			return err
+		}
+		if handled {
+			return nil
+		}
+
+		err = h(e)
+		if err != nil {
+			return err
+		}
+
+		return store.MarkOutboxEventHandled(ctx, e.ID, name)
+	}
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// Domain event types. Reservations are only created so far: nothing in the app modifies or
+// cancels one yet, so ReservationModified and ReservationCancelled are not written to the
+// outbox until those changes exist, and subscribers cannot choose them.
+const (
+	ReservationCreated   = "reservation.created"
+	ReservationModified  = "reservation.modified"
+	ReservationCancelled = "reservation.cancelled"
+)
+
+// New creates an event of the given type with a fresh id, ready to be written to the outbox
+func New(eventType string, data interface{}) (models.Event, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return models.Event{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return models.Event{}, err
+	}
+
+	return models.Event{
+		ID:         id,
+		Type:       eventType,
+		Payload:    string(payload),
+		OccurredAt: time.Now(),
+	}, nil
+}
+
+// Reservation is the payload of reservation events. It leaves out the guest's details,
+// which are only stored, encrypted, with the reservation: subscribers needing them load the
+// reservation by its id.
+type Reservation struct {
+	ID        int    `json:"id"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	RoomID    int    `json:"room_id"`
+	RoomName  string `json:"room_name"`
+	Locale    string `json:"locale,omitempty"`
+}
+
+// NewReservation builds the event payload for a reservation
+func NewReservation(res models.Reservation) Reservation {
+	return Reservation{
+		ID:        res.ID,
+		StartDate: res.StartDate.Format("2006-01-02"),
+		EndDate:   res.EndDate.Format("2006-01-02"),
+		RoomID:    res.RoomID,
+		RoomName:  res.Room.RoomName,
+		Locale:    res.Locale,
+	}
+}
+
+// newID returns a random (version 4) UUID
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
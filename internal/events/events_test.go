@@ -0,0 +1,255 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// memStore is an in-memory outbox for testing the dispatcher
+type memStore struct {
+	events     []models.Event
+	dispatched map[string]bool
+	claimed    map[string]time.Time
+	handled    map[string]bool
+}
+
+func newMemStore(events ...models.Event) *memStore {
+	return &memStore{
+		events:     events,
+		dispatched: map[string]bool{},
+		claimed:    map[string]time.Time{},
+		handled:    map[string]bool{},
+	}
+}
+
+func (s *memStore) ClaimOutboxEvents(ctx context.Context, now, until time.Time, maxAttempts, limit int) ([]models.Event, error) {
+	var pending []models.Event
+	for _, e := range s.events {
+		if !s.dispatched[e.ID] && e.Attempts < maxAttempts && !e.NextAttemptAt.After(now) && !s.claimed[e.ID].After(now) {
+			pending = append(pending, e)
+			s.claimed[e.ID] = until
+		}
+	}
+	return pending, nil
+}
+
+func (s *memStore) MarkOutboxEventDispatched(ctx context.Context, id string) error {
+	s.dispatched[id] = true
+	return nil
+}
+
+func (s *memStore) MarkOutboxEventFailed(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	for i := range s.events {
+		if s.events[i].ID == id {
+			s.events[i].Attempts++
+			s.events[i].LastError = lastError
+			s.events[i].NextAttemptAt = nextAttemptAt
+		}
+	}
+	delete(s.claimed, id)
+	return nil
+}
+
+func (s *memStore) OutboxEventHandled(ctx context.Context, id, handler string) (bool, error) {
+	return s.handled[id+"/"+handler], nil
+}
+
+func (s *memStore) MarkOutboxEventHandled(ctx context.Context, id, handler string) error {
+	s.handled[id+"/"+handler] = true
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	a, err := New(ReservationCreated, NewReservation(models.Reservation{ID: 7}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := New(ReservationCreated, nil)
+
+	if len(a.ID) != 36 || a.ID == b.ID {
+		t.Errorf("event ids are not unique uuids: %q %q", a.ID, b.ID)
+	}
+
+	if a.Payload == "" || a.Type != ReservationCreated {
+		t.Error("event not populated")
+	}
+}
+
+func TestDispatcher_AtLeastOnce(t *testing.T) {
+	created, _ := New(ReservationCreated, nil)
+	cancelled, _ := New(ReservationCancelled, nil)
+
+	store := newMemStore(created, cancelled)
+
+	now := time.Now()
+	d := NewDispatcher(store, logging.Discard())
+	d.now = func() time.Time { return now }
+
+	var calls []string
+	fail := true
+
+	d.Subscribe(ReservationCreated, func(e models.Event) error {
+		calls = append(calls, "first:"+e.ID)
+		return nil
+	})
+	d.Subscribe(ReservationCreated, func(e models.Event) error {
+		if fail {
+			return errors.New("subscriber down")
+		}
+		calls = append(calls, "second:"+e.ID)
+		return nil
+	})
+
+	// the cancelled event has no subscribers and is simply marked dispatched
+	if n := d.ProcessPending(); n != 1 {
+		t.Errorf("expected 1 event dispatched but got %d", n)
+	}
+
+	if store.dispatched[created.ID] || store.events[0].Attempts != 1 {
+		t.Fatal("failed event was not left in the outbox to retry")
+	}
+
+	fail = false
+	if n := d.ProcessPending(); n != 0 {
+		t.Errorf("expected no retry before the backoff elapsed but got %d", n)
+	}
+
+	now = now.Add(d.BaseBackoff)
+	if n := d.ProcessPending(); n != 1 {
+		t.Errorf("expected 1 event dispatched on retry but got %d", n)
+	}
+
+	if !store.dispatched[created.ID] {
+		t.Error("event not marked dispatched after retry")
+	}
+
+	// the first subscriber saw the event twice, which is why subscribers are wrapped in Once
+	if len(calls) != 3 {
+		t.Errorf("expected 3 handler calls but got %v", calls)
+	}
+}
+
+func TestDispatcher_ClaimsEvents(t *testing.T) {
+	created, _ := New(ReservationCreated, nil)
+	store := newMemStore(created)
+
+	now := time.Now()
+	d := NewDispatcher(store, logging.Discard())
+	d.now = func() time.Time { return now }
+
+	calls := 0
+	d.Subscribe(ReservationCreated, func(e models.Event) error {
+		calls++
+		return nil
+	})
+
+	// another instance claims the event first, then stops before dispatching it
+	claimed, _ := store.ClaimOutboxEvents(context.Background(), now, now.Add(d.Lease), d.MaxAttempts, d.BatchSize)
+	if len(claimed) != 1 {
+		t.Fatalf("expected 1 claimed event but got %d", len(claimed))
+	}
+
+	if n := d.ProcessPending(); n != 0 || calls != 0 {
+		t.Errorf("expected a claimed event to be left alone but %d were dispatched", n)
+	}
+
+	now = now.Add(d.Lease)
+	if n := d.ProcessPending(); n != 1 || calls != 1 {
+		t.Errorf("expected the event to be dispatched once its lease expired but %d were", n)
+	}
+}
+
+func TestOnce(t *testing.T) {
+	store := newMemStore()
+
+	count := 0
+	fail := true
+	h := Once(store, "confirmation", func(e models.Event) error {
+		count++
+		if fail {
+			return errors.New("mail server down")
+		}
+		return nil
+	})
+
+	a, _ := New(ReservationCreated, nil)
+	b, _ := New(ReservationCreated, nil)
+
+	// a failed call is not recorded, so the event is handled again on retry
+	if err := h(a); err == nil {
+		t.Error("expected the handler's error")
+	}
+
+	fail = false
+	_ = h(a)
+	_ = h(a)
+	_ = h(b)
+	if count != 3 {
+		t.Errorf("expected 3 calls but got %d", count)
+	}
+
+	// what was handled is kept in the store, so a new instance does not handle it again
+	h = Once(store, "confirmation", func(e models.Event) error {
+		count++
+		return nil
+	})
+	_ = h(a)
+	if count != 3 {
+		t.Errorf("expected an event handled by another instance to be skipped but got %d calls", count)
+	}
+
+	// another handler keeps its own record
+	h = Once(store, "other", func(e models.Event) error {
+		count++
+		return nil
+	})
+	_ = h(a)
+	if count != 4 {
+		t.Errorf("expected 4 calls but got %d", count)
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	created, _ := New(ReservationCreated, nil)
+	store := newMemStore(created)
+
+	now := time.Now()
+	d := NewDispatcher(store, logging.Discard())
+	d.now = func() time.Time { return now }
+	d.MaxAttempts = 3
+
+	d.Subscribe(ReservationCreated, func(e models.Event) error {
+		return errors.New("mail server down")
+	})
+
+	var waited []time.Duration
+	for i := 0; i < 5; i++ {
+		d.ProcessPending()
+		e := store.events[0]
+		if e.Attempts == d.MaxAttempts {
+			break
+		}
+		waited = append(waited, e.NextAttemptAt.Sub(now))
+		now = e.NextAttemptAt
+	}
+
+	if len(waited) != 2 || waited[0] != d.BaseBackoff || waited[1] != 2*d.BaseBackoff {
+		t.Errorf("expected retries after %s and %s but got %v", d.BaseBackoff, 2*d.BaseBackoff, waited)
+	}
+
+	// once out of attempts the event is no longer claimed
+	now = now.Add(d.MaxBackoff)
+	if claimed, _ := store.ClaimOutboxEvents(context.Background(), now, now, d.MaxAttempts, d.BatchSize); len(claimed) != 0 {
+		t.Errorf("expected an abandoned event to be left alone but %d were claimed", len(claimed))
+	}
+
+	if d.Backoff(30) != d.MaxBackoff {
+		t.Errorf("expected the backoff to be capped at %s but got %s", d.MaxBackoff, d.Backoff(30))
+	}
+}
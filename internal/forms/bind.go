@@ -0,0 +1,287 @@
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDateLayout is the layout of time.Time fields without a layout tag
+const DefaultDateLayout = "2006-01-02"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// MaxBodySize is the size in bytes of the largest request body ParseRequest reads, and the
+// limit the server puts on every request body before the csrf check parses the form
+var MaxBodySize int64 = 1 << 20
+
+// ParseRequest returns the submitted values of r, read from a JSON object body when
+// the request is JSON and from the form otherwise. Bodies larger than MaxBodySize are
+// an error, so one request cannot exhaust the memory.
+func ParseRequest(r *http.Request) (url.Values, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, MaxBodySize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		return FromJSON(r.Body)
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return nil, err
+	}
+	return r.PostForm, nil
+}
+
+// FromJSON reads a JSON object into url.Values, so it can be validated and bound like a
+// form. Strings, numbers and booleans become values, arrays become repeated values and
+// nulls are left out. r is read whole, so it should be limited in size, as by ParseRequest.
+func FromJSON(r io.Reader) (url.Values, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var doc map[string]interface{}
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	for key, v := range doc {
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+
+		for _, item := range items {
+			switch x := item.(type) {
+			case nil:
+			case string:
+				values.Add(key, x)
+			case json.Number:
+				values.Add(key, x.String())
+			case bool:
+				values.Add(key, strconv.FormatBool(x))
+			default:
+				return nil, fmt.Errorf("forms: cannot read %q, nested objects are not supported", key)
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// Bind validates the form and copies its values into the struct dst points to. Fields
+// are bound from the value named by their form tag, and checked by the comma separated
+// rules in their validate tag:
+//
+//	required        a value must be given
+//	min=N, max=N    length of strings
+//	range=MIN:MAX   value of numbers
+//	email, phone    an email address, or a phone number, which is normalized to E.164
+//	after=FIELD     a time.Time later than FIELD
+//	match=FIELD     the same value as FIELD
+//	oneof=A|B|C     one of the listed values
+//	pattern=RE      matches the regular expression RE, which cannot contain commas
+//
+// A normalize tag of text, email or phone cleans up the value before it is checked, with
+// NormalizeText, NormalizeEmail or NormalizePhone. A message tag replaces the messages of
+// all the rules of a field, and a layout tag sets how time.Time fields are written,
+// DefaultDateLayout by default. Rule failures and
+// values that cannot be converted are added to f.Errors and leave the field unchanged;
+// the returned error is only for a dst or tag that cannot be used.
+func (f *Form) Bind(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("forms: Bind needs a pointer to a struct, not %T", dst)
+	}
+	v = v.Elem()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		name := field.Tag.Get("form")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+
+		errs := len(f.Errors[name])
+
+		err := f.normalize(name, field.Tag.Get("normalize"))
+		if err != nil {
+			return err
+		}
+
+		err = f.validate(name, field)
+		if err != nil {
+			return err
+		}
+		if len(f.Errors[name]) > errs {
+			continue
+		}
+
+		err = f.set(v.Field(i), name, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate runs the rules in the validate tag of field against the value called name
+func (f *Form) validate(name string, field reflect.StructField) error {
+	tag := field.Tag.Get("validate")
+	if tag == "" {
+		return nil
+	}
+
+	var msg []string
+	if m, ok := field.Tag.Lookup("message"); ok {
+		msg = []string{m}
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		switch rule {
+		case "required":
+			if strings.TrimSpace(f.Get(name)) == "" {
+				f.Errors.Add(name, f.custom(msg, "form.required"))
+			}
+		case "email":
+			if f.Has(name) {
+				f.ValidEmail(name, msg...)
+			}
+		case "phone":
+			f.ValidPhone(name, msg...)
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("forms: %s of %s must be a number, not %q", rule, name, arg)
+			}
+			if rule == "max" {
+				f.MaxLength(name, n, msg...)
+			} else if f.Has(name) {
+				f.MinLength(name, n, msg...)
+			}
+		case "range":
+			err := f.validateRange(name, field.Type.Kind(), arg, msg)
+			if err != nil {
+				return err
+			}
+		case "after":
+			f.DateRange(arg, name, layout(field), msg...)
+		case "match":
+			f.Matches(name, arg, msg...)
+		case "oneof":
+			f.In(name, strings.Split(arg, "|"), msg...)
+		case "pattern":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return fmt.Errorf("forms: pattern of %s: %w", name, err)
+			}
+			f.Pattern(name, re, msg...)
+		default:
+			return fmt.Errorf("forms: unknown rule %q on %s", rule, name)
+		}
+	}
+
+	return nil
+}
+
+// validateRange checks the value called name against a MIN:MAX range, as a whole number for integer fields
+func (f *Form) validateRange(name string, kind reflect.Kind, arg string, msg []string) error {
+	lo, hi, ok := strings.Cut(arg, ":")
+	if !ok {
+		return fmt.Errorf("forms: range of %s must be MIN:MAX, not %q", name, arg)
+	}
+
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min, err1 := strconv.Atoi(lo)
+		max, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("forms: range of %s must be whole numbers, not %q", name, arg)
+		}
+		f.IntRange(name, min, max, msg...)
+	default:
+		min, err1 := strconv.ParseFloat(lo, 64)
+		max, err2 := strconv.ParseFloat(hi, 64)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("forms: range of %s must be numbers, not %q", name, arg)
+		}
+		f.DecimalRange(name, min, max, msg...)
+	}
+
+	return nil
+}
+
+// set converts the value called name to the type of v and stores it; an empty value stores the zero value
+func (f *Form) set(v reflect.Value, name string, field reflect.StructField) error {
+	x := strings.TrimSpace(f.Get(name))
+	if x == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Type() == timeType {
+		t, err := time.Parse(layout(field), x)
+		if err != nil {
+			f.Errors.Add(name, f.Message("form.date"))
+			return nil
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(f.Get(name))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(x, 10, v.Type().Bits())
+		if err != nil {
+			f.Errors.Add(name, f.Message("form.whole_number"))
+			return nil
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(x, 10, v.Type().Bits())
+		if err != nil {
+			f.Errors.Add(name, f.Message("form.whole_number"))
+			return nil
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(x, v.Type().Bits())
+		if err != nil {
+			f.Errors.Add(name, f.Message("form.number"))
+			return nil
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(x)
+		v.SetBool(x == "on" || (err == nil && b))
+	default:
+		return fmt.Errorf("forms: cannot bind %s to a %s", name, v.Type())
+	}
+
+	return nil
+}
+
+// layout returns the date layout of a time.Time field
+func layout(field reflect.StructField) string {
+	if l := field.Tag.Get("layout"); l != "" {
+		return l
+	}
+	return DefaultDateLayout
+}
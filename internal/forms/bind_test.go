@@ -0,0 +1,153 @@
+package forms
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type booking struct {
+	Name       string    `form:"name" validate:"required,min=3,max=20"`
+	Email      string    `form:"email" validate:"email"`
+	Phone      string    `form:"phone" validate:"phone"`
+	Guests     int       `form:"guests" validate:"range=1:4"`
+	Rate       float64   `form:"rate"`
+	Start      time.Time `form:"start"`
+	End        time.Time `form:"end" validate:"after=start"`
+	Room       string    `form:"room" validate:"oneof=suite|quarters" message:"Choose one of our rooms"`
+	Newsletter bool      `form:"newsletter"`
+	Ignored    string
+}
+
+func TestForm_Bind(t *testing.T) {
+	form := New(url.Values{
+		"name":       {"John"},
+		"email":      {"john@here.com"},
+		"phone":      {"+1 555 555 5555"},
+		"guests":     {"2"},
+		"rate":       {"89.5"},
+		"start":      {"2026-01-01"},
+		"end":        {"2026-01-03"},
+		"room":       {"suite"},
+		"newsletter": {"on"},
+		"Ignored":    {"x"},
+	})
+
+	var b booking
+	if err := form.Bind(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !form.Valid() {
+		t.Fatalf("valid form has errors: %v", form.Errors)
+	}
+
+	if b.Name != "John" || b.Phone != "+15555555555" || b.Guests != 2 || b.Rate != 89.5 || !b.Newsletter {
+		t.Errorf("values not bound: %+v", b)
+	}
+	if !b.End.Equal(time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date not bound: %v", b.End)
+	}
+	if b.Ignored != "" {
+		t.Error("bound a field without a form tag")
+	}
+}
+
+func TestForm_Bind_Errors(t *testing.T) {
+	form := New(url.Values{
+		"name":   {"Jo"},
+		"email":  {"john"},
+		"guests": {"9"},
+		"rate":   {"cheap"},
+		"start":  {"2026-01-03"},
+		"end":    {"2026-01-01"},
+		"room":   {"attic"},
+	})
+
+	b := booking{Name: "unchanged"}
+	if err := form.Bind(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"name", "email", "guests", "rate", "end", "room"} {
+		if form.Errors.Get(field) == "" {
+			t.Errorf("no error for %s", field)
+		}
+	}
+	if b.Name != "unchanged" {
+		t.Errorf("invalid value bound: %q", b.Name)
+	}
+	if got := form.Errors.Get("room"); got != "Choose one of our rooms" {
+		t.Errorf("message tag not used: got %q", got)
+	}
+	if got := form.Errors.Get("rate"); got != "Enter a number" {
+		t.Errorf("got %q", got)
+	}
+
+	form = New(url.Values{})
+	form.Bind(&b)
+	if form.Errors.Get("name") == "" {
+		t.Error("missing required value accepted")
+	}
+	if len(form.Errors) != 1 {
+		t.Errorf("optional fields rejected: %v", form.Errors)
+	}
+}
+
+func TestForm_Bind_BadDestination(t *testing.T) {
+	var b booking
+	if err := New(url.Values{}).Bind(b); err == nil {
+		t.Error("bound into a struct that is not a pointer")
+	}
+
+	var bad struct {
+		Name string `form:"name" validate:"shouty"`
+	}
+	if err := New(url.Values{}).Bind(&bad); err == nil {
+		t.Error("unknown rule accepted")
+	}
+}
+
+func TestParseRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "John", "guests": 2, "tags": ["a", "b"], "note": null}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	values, err := ParseRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values.Get("name") != "John" || values.Get("guests") != "2" || len(values["tags"]) != 2 {
+		t.Errorf("got %v", values)
+	}
+	if _, ok := values["note"]; ok {
+		t.Error("null read as a value")
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader("name=John"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	values, err = ParseRequest(r)
+	if err != nil || values.Get("name") != "John" {
+		t.Errorf("form not read: %v, %v", values, err)
+	}
+
+	if _, err = FromJSON(strings.NewReader(`{"guest": {"name": "John"}}`)); err == nil {
+		t.Error("nested object accepted")
+	}
+
+	for _, contentType := range []string{"application/json", "application/x-www-form-urlencoded"} {
+		padding := strings.Repeat("a", int(MaxBodySize))
+		body := `{"name": "` + padding + `"}`
+		if contentType != "application/json" {
+			body = "name=" + padding
+		}
+
+		r = httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+
+		if _, err = ParseRequest(r); err == nil {
+			t.Errorf("%s body larger than %d bytes accepted", contentType, MaxBodySize)
+		}
+	}
+}
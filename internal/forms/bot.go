@@ -0,0 +1,251 @@
+package forms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The fields a Guard adds to a form: a honeypot people never see or fill in, the signed
+// token of when the form was shown and the answer to the challenge
+const (
+	HoneypotField  = "website"
+	TokenField     = "form_token"
+	ChallengeField = "challenge"
+)
+
+// The reasons a submission is flagged as coming from a bot
+const (
+	BotHoneypot  = "honeypot"
+	BotTooFast   = "too_fast"
+	BotToken     = "bad_token"
+	BotChallenge = "wrong_answer"
+)
+
+// Guard tells people from bots on public forms without a third-party service: bots fill
+// in the honeypot, submit faster than anyone can type or cannot answer the challenge, a
+// small sum drawn in a picture. The answer is kept in the visitor's session with a nonce
+// the token carries, so each challenge can be answered once, from the session it was shown in.
+type Guard struct {
+	secret      []byte
+	sessions    Sessions
+	minFillTime time.Duration
+	maxAge      time.Duration
+	now         func() time.Time
+}
+
+// Sessions keeps the challenge shown to each visitor; *scs.SessionManager is one
+type Sessions interface {
+	Put(ctx context.Context, key string, val interface{})
+	PopString(ctx context.Context, key string) string
+}
+
+// Challenge is what a form needs to show to be checked by a Guard. Pages show the sum
+// of A and B as Image only, so it is not in the page as text.
+type Challenge struct {
+	Token string
+	Image template.URL
+	A, B  int
+}
+
+// NewGuard returns a Guard signing with secret and keeping challenges in sessions, which
+// rejects forms submitted sooner than minFillTime or later than maxAge after they were shown.
+// Without a secret a random one is used, so forms shown before a restart, or by another
+// instance, are rejected.
+func NewGuard(secret string, sessions Sessions, minFillTime, maxAge time.Duration) *Guard {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	return &Guard{
+		secret:      key,
+		sessions:    sessions,
+		minFillTime: minFillTime,
+		maxAge:      maxAge,
+		now:         time.Now,
+	}
+}
+
+// Challenge returns a new challenge for the form called form, replacing the one the
+// session in ctx was given for it before
+func (g *Guard) Challenge(ctx context.Context, form string) Challenge {
+	c := Challenge{A: digit(), B: digit()}
+	c.Image = challengeImage(c.A, c.B)
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	n := base64.RawURLEncoding.EncodeToString(nonce)
+	issued := strconv.FormatInt(g.now().Unix(), 10)
+
+	c.Token = issued + "." + n + "." + g.sign(form, issued, n)
+	g.sessions.Put(ctx, challengeKey(form), n+"."+strconv.Itoa(c.A+c.B))
+	return c
+}
+
+// check returns why the submitted values of form look like they come from a bot, or "" if they do not
+func (g *Guard) check(ctx context.Context, f *Form, form string) string {
+	if f.Get(HoneypotField) != "" {
+		return BotHoneypot
+	}
+
+	parts := strings.Split(f.Get(TokenField), ".")
+	if len(parts) != 3 || !hmac.Equal([]byte(parts[2]), []byte(g.sign(form, parts[0], parts[1]))) {
+		return BotToken
+	}
+
+	// the challenge is taken out of the session whatever the outcome, so it is answered once
+	nonce, answer, _ := strings.Cut(g.sessions.PopString(ctx, challengeKey(form)), ".")
+	if nonce == "" || !hmac.Equal([]byte(nonce), []byte(parts[1])) {
+		return BotToken
+	}
+
+	issued, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return BotToken
+	}
+	age := g.now().Sub(time.Unix(issued, 0))
+	if age > g.maxAge {
+		return BotToken
+	}
+	if age < g.minFillTime {
+		return BotTooFast
+	}
+
+	if strings.TrimSpace(f.Get(ChallengeField)) != answer {
+		return BotChallenge
+	}
+
+	return ""
+}
+
+// sign returns the HMAC of the form name and values, so a token is only valid for its form
+func (g *Guard) sign(form string, values ...string) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write([]byte(form))
+	for _, v := range values {
+		mac.Write([]byte{0})
+		mac.Write([]byte(v))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// challengeKey is the session key the challenge of form is kept under
+func challengeKey(form string) string {
+	return "bot_challenge:" + form
+}
+
+// digit returns a random number from 1 to 9
+func digit() int {
+	return random(9, 4) + 1
+}
+
+// random returns a random number from 0 to n-1, or fallback if there is no randomness
+func random(n, fallback int) int {
+	x, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return fallback
+	}
+	return int(x.Int64())
+}
+
+// glyphs are the characters of the challenge image, 3 by 5 cells each
+var glyphs = map[byte][5]string{
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"##.", "..#", ".#.", "#..", "###"},
+	'3': {"##.", "..#", ".#.", "..#", "##."},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "##.", "..#", "##."},
+	'6': {".##", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", ".#.", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "##."},
+	'+': {"...", ".#.", "###", ".#.", "..."},
+}
+
+// The size of the challenge image and of the cells its glyphs are drawn with
+const (
+	imageWidth  = 120
+	imageHeight = 50
+	cellSize    = 5
+)
+
+// challengeImage returns a PNG picture of the sum a + b as a data URL, drawn with a
+// little jitter and noise so it takes more than reading the page to answer
+func challengeImage(a, b int) template.URL {
+	palette := color.Palette{
+		color.RGBA{0xf8, 0xf9, 0xfa, 0xff},
+		color.RGBA{0x34, 0x3a, 0x40, 0xff},
+		color.RGBA{0x86, 0x8e, 0x96, 0xff},
+	}
+	img := image.NewPaletted(image.Rect(0, 0, imageWidth, imageHeight), palette)
+
+	for i := 0; i < imageWidth*imageHeight/12; i++ {
+		img.SetColorIndex(random(imageWidth, 0), random(imageHeight, 0), 2)
+	}
+
+	text := strconv.Itoa(a) + "+" + strconv.Itoa(b)
+	for i := 0; i < len(text); i++ {
+		x0 := 12 + i*(4*cellSize+12) + random(6, 0)
+		y0 := 6 + random(imageHeight-5*cellSize-12, 0)
+		for row, line := range glyphs[text[i]] {
+			for col := range line {
+				if line[col] != '#' {
+					continue
+				}
+				for y := 0; y < cellSize; y++ {
+					for x := 0; x < cellSize; x++ {
+						img.SetColorIndex(x0+col*cellSize+x, y0+row*cellSize+y, 1)
+					}
+				}
+			}
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		y, dy := random(imageHeight, 0), random(3, 1)-1
+		for x := 0; x < imageWidth; x++ {
+			img.SetColorIndex(x, y, 2)
+			if x%8 == 0 {
+				y = (y + dy + imageHeight) % imageHeight
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return ""
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
+
+// NotBot checks the honeypot, fill time and challenge of the form called form with g,
+// against the challenge kept in the session in ctx. A flagged submission gets an error on
+// the challenge, so it is shown again with a new one, and the reason is kept in f.Flagged
+// for logging.
+func (f *Form) NotBot(ctx context.Context, g *Guard, form string) bool {
+	f.Flagged = g.check(ctx, f, form)
+
+	switch f.Flagged {
+	case "":
+		return true
+	case BotChallenge:
+		f.Errors.Add(ChallengeField, f.Message("form.challenge"))
+	default:
+		f.Errors.Add(ChallengeField, f.Message("form.bot"))
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package forms
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memSessions is a Sessions keeping the values of a single session
+type memSessions map[string]string
+
+func (s memSessions) Put(ctx context.Context, key string, val interface{}) {
+	s[key] = val.(string)
+}
+
+func (s memSessions) PopString(ctx context.Context, key string) string {
+	v := s[key]
+	delete(s, key)
+	return v
+}
+
+func TestForm_NotBot(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	g := NewGuard("secret", memSessions{}, 3*time.Second, time.Hour)
+
+	person := func(c Challenge) url.Values {
+		return url.Values{TokenField: {c.Token}, ChallengeField: {" " + strconv.Itoa(c.A+c.B)}}
+	}
+
+	tests := []struct {
+		name    string
+		form    string
+		values  func(c Challenge) url.Values
+		elapsed time.Duration
+		want    string
+	}{
+		{"person", "contact", person, time.Minute, ""},
+		{"honeypot", "contact", func(c Challenge) url.Values {
+			v := person(c)
+			v.Set(HoneypotField, "http://spam")
+			return v
+		}, time.Minute, BotHoneypot},
+		{"too fast", "contact", person, time.Second, BotTooFast},
+		{"expired", "contact", person, 2 * time.Hour, BotToken},
+		{"no token", "contact", func(c Challenge) url.Values {
+			return url.Values{ChallengeField: {strconv.Itoa(c.A + c.B)}}
+		}, time.Minute, BotToken},
+		{"other form", "make-reservation", person, time.Minute, BotToken},
+		{"wrong answer", "contact", func(c Challenge) url.Values {
+			return url.Values{TokenField: {c.Token}, ChallengeField: {strconv.Itoa(c.A + c.B + 1)}}
+		}, time.Minute, BotChallenge},
+	}
+
+	for _, tt := range tests {
+		g.now = func() time.Time { return now }
+		c := g.Challenge(ctx, "contact")
+		g.now = func() time.Time { return now.Add(tt.elapsed) }
+
+		form := New(tt.values(c))
+		ok := form.NotBot(ctx, g, tt.form)
+		if form.Flagged != tt.want || ok != (tt.want == "") {
+			t.Errorf("%s: got %q, %v, wanted %q", tt.name, form.Flagged, ok, tt.want)
+		}
+		if !ok && form.Errors.Get(ChallengeField) == "" {
+			t.Errorf("%s: no error on the challenge", tt.name)
+		}
+	}
+}
+
+func TestForm_NotBotOnce(t *testing.T) {
+	ctx := context.Background()
+	g := NewGuard("secret", memSessions{}, 0, time.Hour)
+
+	c := g.Challenge(ctx, "contact")
+	values := url.Values{TokenField: {c.Token}, ChallengeField: {strconv.Itoa(c.A + c.B)}}
+
+	if !New(values).NotBot(ctx, g, "contact") {
+		t.Fatal("first answer rejected")
+	}
+	if form := New(values); form.NotBot(ctx, g, "contact") || form.Flagged != BotToken {
+		t.Errorf("replayed answer: got %q, wanted %q", form.Flagged, BotToken)
+	}
+
+	c = g.Challenge(ctx, "contact")
+	if form := New(values); form.NotBot(ctx, g, "contact") || form.Flagged != BotToken {
+		t.Errorf("answer to a replaced challenge: got %q, wanted %q", form.Flagged, BotToken)
+	}
+
+	other := NewGuard("secret", memSessions{}, 0, time.Hour)
+	values = url.Values{TokenField: {c.Token}, ChallengeField: {strconv.Itoa(c.A + c.B)}}
+	if form := New(values); form.NotBot(ctx, other, "contact") || form.Flagged != BotToken {
+		t.Errorf("answer from another session: got %q, wanted %q", form.Flagged, BotToken)
+	}
+}
+
+func TestGuard_Challenge(t *testing.T) {
+	c := NewGuard("secret", memSessions{}, 0, time.Hour).Challenge(context.Background(), "contact")
+
+	if !strings.HasPrefix(string(c.Image), "data:image/png;base64,") {
+		t.Errorf("expected a PNG data URL but got %.40q", c.Image)
+	}
+}
+
+func TestGuard_Secret(t *testing.T) {
+	ctx := context.Background()
+	sessions := memSessions{}
+	c := NewGuard("one", sessions, 0, time.Hour).Challenge(ctx, "contact")
+
+	form := New(url.Values{TokenField: {c.Token}, ChallengeField: {strconv.Itoa(c.A + c.B)}})
+	if form.NotBot(ctx, NewGuard("two", sessions, 0, time.Hour), "contact") {
+		t.Error("token signed with another secret accepted")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/asaskevich/govalidator"
 )
@@ -12,6 +13,37 @@ import (
 type Form struct {
 	url.Values
 	Errors errors
+
+	// Translator localizes the error messages added by the validators; without one they are in English
+	Translator Translator
+
+	// Flagged is why NotBot took the submission for a bot's, empty if it did not
+	Flagged string
+}
+
+// Translator returns the message for key, in the visitor's language, formatted with args
+type Translator interface {
+	T(key string, args ...interface{}) string
+}
+
+// messages are the English error messages of the validators, by message key
+var messages = map[string]string{
+	"form.required":      "This field cannot be blank",
+	"form.min_length":    "This field should be minimum %d characters long",
+	"form.email":         "Invalid email address",
+	"form.max_length":    "This field should be at most %d characters long",
+	"form.phone":         "Invalid phone number",
+	"form.date":          "Invalid date",
+	"form.date_range":    "The end date must be after the start date",
+	"form.int_range":     "Enter a whole number from %d to %d",
+	"form.decimal_range": "Enter a number from %g to %g",
+	"form.match":         "The values do not match",
+	"form.pattern":       "This field is not in the expected format",
+	"form.in":            "Choose one of the options",
+	"form.number":        "Enter a number",
+	"form.whole_number":  "Enter a whole number",
+	"form.challenge":     "That is not the right answer, please try again",
+	"form.bot":           "We could not check this form, please answer the question and send it again",
 }
 
 // Valid returns true if there are no errors
@@ -22,9 +54,31 @@ func (f *Form) Valid() bool {
 // New initializes a form struct
 func New(data url.Values) *Form {
 	return &Form{
-		data,
-		errors(map[string][]string{}),
+		Values: data,
+		Errors: errors(map[string][]string{}),
+	}
+}
+
+// Localize makes the validators add their error messages translated by t, returning the form
+func (f *Form) Localize(t Translator) *Form {
+	f.Translator = t
+	return f
+}
+
+// Message returns the error message for key formatted with args, translated if the form has a Translator
+func (f *Form) Message(key string, args ...interface{}) string {
+	if f.Translator != nil {
+		return f.Translator.T(key, args...)
+	}
+
+	msg, ok := messages[key]
+	if !ok {
+		msg = key
+	}
+	if len(args) == 0 {
+		return msg
 	}
+	return fmt.Sprintf(msg, args...)
 }
 
 func (f *Form) Required(fields ...string) {
@@ -32,7 +86,7 @@ func (f *Form) Required(fields ...string) {
 		value := f.Get(field)
 
 		if strings.TrimSpace(value) == "" {
-			f.Errors.Add(field, "This field cannot be blank")
+			f.Errors.Add(field, f.Message("form.required"))
 		}
 	}
 }
@@ -46,21 +100,21 @@ func (f *Form) Has(field string) bool {
 	return true
 }
 
-// MinLength checks minimum length required for a field
-func (f *Form) MinLength(field string, length int) bool {
+// MinLength checks that field is at least length characters long
+func (f *Form) MinLength(field string, length int, msg ...string) bool {
 	x := f.Get(field)
-	if len(x) < length {
-		f.Errors.Add(field, fmt.Sprintf("This field should be minimum %d characters long", length))
+	if utf8.RuneCountInString(x) < length {
+		f.Errors.Add(field, f.custom(msg, "form.min_length", length))
 		return false
 	}
 	return true
 }
 
 // ValidEmail checks validity of email field
-func (f *Form) ValidEmail(field string) bool {
+func (f *Form) ValidEmail(field string, msg ...string) bool {
 	x := f.Get(field)
 	if !govalidator.IsEmail(x) {
-		f.Errors.Add(field, "Invalid email address")
+		f.Errors.Add(field, f.custom(msg, "form.email"))
 		return false
 	}
 	return true
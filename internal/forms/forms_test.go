@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 )
 
@@ -99,6 +100,13 @@ func TestForm_MinLength(t *testing.T) {
 		t.Error("should not have error but got one")
 	}
 
+	form = New(url.Values{"name": {"Zoë"}})
+	if form.MinLength("name", 4) {
+		t.Error("counted bytes rather than characters")
+	}
+	if !form.MinLength("name", 3) {
+		t.Error("rejected a value of the minimum length")
+	}
 }
 
 func TestForm_IsEmail(t *testing.T) {
@@ -128,3 +136,23 @@ func TestForm_IsEmail(t *testing.T) {
 		t.Error("got valid for invalid email address")
 	}
 }
+
+type upperTranslator struct{}
+
+func (upperTranslator) T(key string, args ...interface{}) string {
+	return strings.ToUpper(key)
+}
+
+func TestForm_Localize(t *testing.T) {
+	form := New(url.Values{})
+	form.Required("a")
+	if got := form.Errors.Get("a"); got != "This field cannot be blank" {
+		t.Errorf("got %q without a translator", got)
+	}
+
+	form = New(url.Values{}).Localize(upperTranslator{})
+	form.Required("a")
+	if got := form.Errors.Get("a"); got != "FORM.REQUIRED" {
+		t.Errorf("message not translated: got %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package forms
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeText returns s in Unicode NFC form without control or invisible characters,
+// with runs of whitespace collapsed to a single space and trimmed from both ends, so
+// " John  Smith\t" and "John Smith" are stored the same
+func NormalizeText(s string) string {
+	var b strings.Builder
+	space := false
+
+	for _, r := range norm.NFC.String(s) {
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		case unicode.IsControl(r), invisible(r), r == utf8.RuneError:
+			continue
+		}
+
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// NormalizeEmail returns email as NormalizeText does, without spaces and lowercased,
+// the same form its hash is computed from, so an address is stored as it is looked up
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.ReplaceAll(NormalizeText(email), " ", ""))
+}
+
+// invisible reports whether r is a zero width character that is never meant to be
+// part of a name or address; joiners are kept, as some scripts and emoji need them
+func invisible(r rune) bool {
+	switch r {
+	case '\u200b', '\u2060', '\ufeff', '\u00ad':
+		return true
+	}
+	return r >= '\u202a' && r <= '\u202e' || r >= '\u2066' && r <= '\u2069'
+}
+
+// normalize rewrites the value called name as the normalize tag asks: text, email or
+// phone, which is only rewritten when it can be read as a phone number
+func (f *Form) normalize(name, how string) error {
+	x, ok := f.Values[name]
+	if !ok || len(x) == 0 {
+		return nil
+	}
+
+	switch how {
+	case "":
+		return nil
+	case "text":
+		f.Set(name, NormalizeText(x[0]))
+	case "email":
+		f.Set(name, NormalizeEmail(x[0]))
+	case "phone":
+		if phone, ok := NormalizePhone(x[0]); ok {
+			f.Set(name, phone)
+		} else {
+			f.Set(name, NormalizeText(x[0]))
+		}
+	default:
+		return fmt.Errorf("forms: unknown normalization %q on %s", how, name)
+	}
+
+	return nil
+}
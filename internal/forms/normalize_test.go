@@ -0,0 +1,74 @@
+package forms
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{" John ", "John"},
+		{"John \t\n Smith", "John Smith"},
+		{"Jose\u0301", "Jos\u00e9"},
+		{"Ann\u00a0Marie", "Ann Marie"},
+		{"Jo\u200bhn\x00\x1b", "John"},
+		{"\u202eevil", "evil"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeText(tt.in); got != tt.want {
+			t.Errorf("NormalizeText(%q): got %q, wanted %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{" JOHN@EXAMPLE.COM ", "john@example.com"},
+		{"john @ Example.com", "john@example.com"},
+		{"not an email", "notanemail"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeEmail(tt.in); got != tt.want {
+			t.Errorf("NormalizeEmail(%q): got %q, wanted %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestForm_Bind_Normalize(t *testing.T) {
+	var guest struct {
+		Name  string `form:"name" normalize:"text" validate:"min=3"`
+		Email string `form:"email" normalize:"email" validate:"email"`
+		Phone string `form:"phone" normalize:"phone"`
+	}
+
+	form := New(url.Values{"name": {"  Jo  "}, "email": {"John@Example.COM "}, "phone": {"+1 (555) 555-5555"}})
+	if err := form.Bind(&guest); err != nil {
+		t.Fatal(err)
+	}
+
+	if form.Errors.Get("name") == "" {
+		t.Error("length checked before the value was normalized")
+	}
+	if guest.Email != "john@example.com" || guest.Phone != "+15555555555" {
+		t.Errorf("got %+v", guest)
+	}
+	if form.Get("email") != "john@example.com" {
+		t.Error("normalized value not kept in the form")
+	}
+
+	var bad struct {
+		Name string `form:"name" normalize:"shout"`
+	}
+	if err := form.Bind(&bad); err == nil {
+		t.Error("unknown normalization accepted")
+	}
+}
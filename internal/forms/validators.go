@@ -0,0 +1,204 @@
+package forms
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultCallingCode is the country calling code assumed for phone numbers entered without
+// one, e.g. "1". It is unset by default, so such numbers are rejected rather than guessed.
+var DefaultCallingCode = ""
+
+// e164 matches a phone number in E.164 format: a + and up to 15 digits, not starting with 0
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// The validators below leave empty fields alone, so optional fields can be checked
+// and Required decides whether a value must be given. Each takes an optional message,
+// a catalog key or text, used instead of its own.
+
+// custom returns the custom message if one was given, and the message for key otherwise
+func (f *Form) custom(msg []string, key string, args ...interface{}) string {
+	if len(msg) > 0 && msg[0] != "" {
+		return f.Message(msg[0])
+	}
+	return f.Message(key, args...)
+}
+
+// MaxLength checks that field is at most length characters long
+func (f *Form) MaxLength(field string, length int, msg ...string) bool {
+	if utf8.RuneCountInString(f.Get(field)) > length {
+		f.Errors.Add(field, f.custom(msg, "form.max_length", length))
+		return false
+	}
+	return true
+}
+
+// ValidPhone checks that field is a phone number and replaces it with its E.164 form,
+// e.g. "+1 (555) 555-5555" becomes "+15555555555"
+func (f *Form) ValidPhone(field string, msg ...string) bool {
+	x := f.Get(field)
+	if x == "" {
+		return true
+	}
+
+	phone, ok := NormalizePhone(x)
+	if !ok {
+		f.Errors.Add(field, f.custom(msg, "form.phone"))
+		return false
+	}
+
+	f.Set(field, phone)
+	return true
+}
+
+// NormalizePhone returns a phone number in E.164 format, ignoring spaces, dots, dashes and
+// brackets and reading a leading 00 as +. Numbers without a country code get DefaultCallingCode,
+// or are rejected if it is not set.
+func NormalizePhone(phone string) (string, bool) {
+	phone = strings.TrimSpace(phone)
+
+	var digits strings.Builder
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case r == ' ' || r == '.' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case DefaultCallingCode == "":
+		return "", false
+	default:
+		number = DefaultCallingCode + strings.TrimPrefix(number, "0")
+	}
+
+	number = "+" + number
+	if !e164.MatchString(number) {
+		return "", false
+	}
+	return number, true
+}
+
+// Date checks that field is a date in layout, returning it
+func (f *Form) Date(field, layout string, msg ...string) (time.Time, bool) {
+	x := f.Get(field)
+	if x == "" {
+		return time.Time{}, true
+	}
+
+	t, err := time.Parse(layout, x)
+	if err != nil {
+		f.Errors.Add(field, f.custom(msg, "form.date"))
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// DateRange checks that start and end are dates in layout and that end is after start;
+// the error for an end that is not after the start is added to end
+func (f *Form) DateRange(start, end, layout string, msg ...string) bool {
+	from, ok := f.Date(start, layout)
+	if !ok {
+		return false
+	}
+	to, ok := f.Date(end, layout)
+	if !ok {
+		return false
+	}
+
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		f.Errors.Add(end, f.custom(msg, "form.date_range"))
+		return false
+	}
+	return true
+}
+
+// IntRange checks that field is a whole number from min to max
+func (f *Form) IntRange(field string, min, max int, msg ...string) bool {
+	x := f.Get(field)
+	if x == "" {
+		return true
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(x))
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, f.custom(msg, "form.int_range", min, max))
+		return false
+	}
+	return true
+}
+
+// DecimalRange checks that field is a number from min to max
+func (f *Form) DecimalRange(field string, min, max float64, msg ...string) bool {
+	x := f.Get(field)
+	if x == "" {
+		return true
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+	if err != nil || math.IsNaN(n) || n < min || n > max {
+		f.Errors.Add(field, f.custom(msg, "form.decimal_range", min, max))
+		return false
+	}
+	return true
+}
+
+// Matches checks that field has the same value as other, e.g. a password and its confirmation
+func (f *Form) Matches(field, other string, msg ...string) bool {
+	if f.Get(field) != f.Get(other) {
+		f.Errors.Add(field, f.custom(msg, "form.match"))
+		return false
+	}
+	return true
+}
+
+// Pattern checks that field matches re
+func (f *Form) Pattern(field string, re *regexp.Regexp, msg ...string) bool {
+	x := f.Get(field)
+	if x == "" {
+		return true
+	}
+
+	if !re.MatchString(x) {
+		f.Errors.Add(field, f.custom(msg, "form.pattern"))
+		return false
+	}
+	return true
+}
+
+// In checks that field is one of allowed
+func (f *Form) In(field string, allowed []string, msg ...string) bool {
+	x := f.Get(field)
+	if x == "" {
+		return true
+	}
+
+	for _, a := range allowed {
+		if x == a {
+			return true
+		}
+	}
+
+	f.Errors.Add(field, f.custom(msg, "form.in"))
+	return false
+}
+
+// When applies rules only if condition holds, e.g. requiring a phone number only
+// when the guest asked to be called
+func (f *Form) When(condition bool, rules func(f *Form)) {
+	if condition {
+		rules(f)
+	}
+}
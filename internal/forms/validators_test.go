@@ -0,0 +1,150 @@
+package forms
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestForm_MaxLength(t *testing.T) {
+	form := New(url.Values{"a": {"héllo"}})
+
+	if !form.MaxLength("a", 5) {
+		t.Error("counted bytes rather than characters")
+	}
+	if form.MaxLength("a", 4) {
+		t.Error("allowed a value longer than the maximum")
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+		ok    bool
+	}{
+		{"+1 555-555-5555", "+15555555555", true},
+		{"+1 (555) 555.5555", "+15555555555", true},
+		{"555-555-5555", "", false},
+		{"+44 20 7946 0958", "+442079460958", true},
+		{"0044 20 7946 0958", "+442079460958", true},
+		{"+0 123 4567", "", false},
+		{"555-CALL-NOW", "", false},
+		{"12", "", false},
+		{"+1 555 555 5555 5555 5", "", false},
+		{"555+5555555", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NormalizePhone(tt.phone)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizePhone(%q): got %q, %v, wanted %q, %v", tt.phone, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	DefaultCallingCode = "44"
+	defer func() { DefaultCallingCode = "" }()
+
+	for phone, want := range map[string]string{"020 7946 0958": "+442079460958", "+1 555 555 5555": "+15555555555"} {
+		if got, ok := NormalizePhone(phone); got != want || !ok {
+			t.Errorf("NormalizePhone(%q) with a default calling code: got %q, %v, wanted %q", phone, got, ok, want)
+		}
+	}
+}
+
+func TestForm_ValidPhone(t *testing.T) {
+	form := New(url.Values{"phone": {"+1 555 555 5555"}})
+	if !form.ValidPhone("phone") || form.Get("phone") != "+15555555555" {
+		t.Errorf("phone not normalized: %q", form.Get("phone"))
+	}
+
+	form = New(url.Values{"phone": {"not a phone"}})
+	if form.ValidPhone("phone", "Call us instead") {
+		t.Error("accepted an invalid phone number")
+	}
+	if got := form.Errors.Get("phone"); got != "Call us instead" {
+		t.Errorf("custom message not used: got %q", got)
+	}
+
+	form = New(url.Values{})
+	if !form.ValidPhone("phone") {
+		t.Error("empty optional phone rejected")
+	}
+}
+
+func TestForm_DateRange(t *testing.T) {
+	layout := "2006-01-02"
+
+	form := New(url.Values{"start": {"2026-01-01"}, "end": {"2026-01-03"}})
+	if !form.DateRange("start", "end", layout) {
+		t.Error("valid range rejected")
+	}
+
+	form = New(url.Values{"start": {"2026-01-03"}, "end": {"2026-01-03"}})
+	if form.DateRange("start", "end", layout) || form.Errors.Get("end") == "" {
+		t.Error("range ending on its start accepted")
+	}
+
+	form = New(url.Values{"start": {"01-01-2026"}, "end": {"2026-01-03"}})
+	if form.DateRange("start", "end", layout) || form.Errors.Get("start") == "" {
+		t.Error("badly formatted date accepted")
+	}
+}
+
+func TestForm_Ranges(t *testing.T) {
+	form := New(url.Values{"guests": {"3"}, "rooms": {"x"}, "rate": {"99.5"}, "discount": {"1.5"}})
+
+	if !form.IntRange("guests", 1, 4) {
+		t.Error("integer in range rejected")
+	}
+	if form.IntRange("rooms", 1, 4) {
+		t.Error("non-integer accepted")
+	}
+	if !form.DecimalRange("rate", 0, 100) {
+		t.Error("decimal in range rejected")
+	}
+	if form.DecimalRange("discount", 0, 1) {
+		t.Error("decimal above the maximum accepted")
+	}
+	if got := form.Errors.Get("rooms"); got != "Enter a whole number from 1 to 4" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestForm_MatchesPatternIn(t *testing.T) {
+	form := New(url.Values{
+		"password":         {"secret"},
+		"password_confirm": {"secrets"},
+		"code":             {"AB12"},
+		"room":             {"suite"},
+	})
+
+	if form.Matches("password_confirm", "password") {
+		t.Error("different values matched")
+	}
+	if !form.Pattern("code", regexp.MustCompile(`^[A-Z]{2}[0-9]{2}$`)) {
+		t.Error("value matching the pattern rejected")
+	}
+	if form.In("room", []string{"generals-quarter", "majors-suite"}) {
+		t.Error("value not in the list accepted")
+	}
+}
+
+func TestForm_When(t *testing.T) {
+	form := New(url.Values{"contact_by": {"email"}})
+
+	form.When(form.Get("contact_by") == "phone", func(f *Form) {
+		f.Required("phone")
+	})
+	if !form.Valid() {
+		t.Error("rule applied although its condition did not hold")
+	}
+
+	form.Set("contact_by", "phone")
+	form.When(form.Get("contact_by") == "phone", func(f *Form) {
+		f.Required("phone")
+	})
+	if form.Valid() {
+		t.Error("rule not applied when its condition held")
+	}
+}
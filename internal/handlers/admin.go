@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/prashant9154/Booking_System/internal/audit"
+	"github.com/prashant9154/Booking_System/internal/forms"
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/render"
+	"github.com/prashant9154/Booking_System/internal/webhooks"
+)
+
+// AdminWebhooks shows the webhook subscriptions and the delivery log
+func (m *Repository) AdminWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := m.DB.AllWebhookSubscriptions(r.Context())
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	deliveries, err := m.DB.RecentWebhookDeliveries(r.Context(), 100)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["subscriptions"] = subscriptions
+	data["deliveries"] = deliveries
+
+	render.Templates(w, r, "admin-webhooks.page.hbs", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// AdminNewWebhook shows the form for adding a webhook subscription
+func (m *Repository) AdminNewWebhook(w http.ResponseWriter, r *http.Request) {
+	data := make(map[string]interface{})
+	data["events"] = webhooks.Events
+
+	render.Templates(w, r, "admin-webhook-new.page.hbs", &models.TemplateData{
+		Form: forms.New(nil),
+		Data: data,
+	})
+}
+
+// AdminPostNewWebhook adds a webhook subscription
+func (m *Repository) AdminPostNewWebhook(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	form := forms.New(r.PostForm).Localize(i18n.FromContext(r.Context()))
+	form.Required("url")
+
+	u, err := url.ParseRequestURI(r.Form.Get("url"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		form.Errors.Add("url", form.Message("webhook.invalid_url"))
+	}
+
+	var events []string
+	for _, e := range webhooks.Events {
+		if r.Form.Get("event_"+e) != "" {
+			events = append(events, e)
+		}
+	}
+
+	if len(events) == 0 {
+		form.Errors.Add("events", form.Message("webhook.no_events"))
+	}
+
+	if !form.Valid() {
+		metrics.ValidationFailures.WithLabelValues("admin-webhook").Inc()
+
+		data := make(map[string]interface{})
+		data["events"] = webhooks.Events
+
+		render.Templates(w, r, "admin-webhook-new.page.hbs", &models.TemplateData{
+			Form: form,
+			Data: data,
+		})
+		return
+	}
+
+	secret := r.Form.Get("secret")
+	if secret == "" {
+		secret, err = webhooks.NewSecret()
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return
+		}
+	}
+
+	_, err = m.DB.InsertWebhookSubscription(r.Context(), models.WebhookSubscription{
+		URL:    u.String(),
+		Secret: secret,
+		Events: events,
+		Active: true,
+	})
+	if err != nil {
+		helpers.Error(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", i18n.FromContext(r.Context()).T("flash.webhook_added"))
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminDeleteWebhook deletes a webhook subscription
+func (m *Repository) AdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = m.DB.DeleteWebhookSubscription(r.Context(), id)
+	if err != nil {
+		helpers.Error(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", i18n.FromContext(r.Context()).T("flash.webhook_deleted"))
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminResendWebhookDelivery queues a webhook delivery to be sent again
+func (m *Repository) AdminResendWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = m.Webhooks.Resend(r.Context(), id)
+	if err != nil {
+		helpers.Error(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", i18n.FromContext(r.Context()).T("flash.delivery_resent"))
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// AdminRetention shows the retention policies and the latest runs of the retention job
+func (m *Repository) AdminRetention(w http.ResponseWriter, r *http.Request) {
+	runs, err := m.DB.RecentRetentionRuns(r.Context(), 50)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	render.Templates(w, r, "admin-retention.page.hbs", &models.TemplateData{
+		Data: map[string]interface{}{
+			"job":  m.Retention,
+			"runs": runs,
+		},
+	})
+}
+
+// AdminRetentionDryRun runs the retention job now without changing anything, to see what it would do
+func (m *Repository) AdminRetentionDryRun(w http.ResponseWriter, r *http.Request) {
+	run, err := m.Retention.Run(r.Context(), audit.User(m.App.Session.GetInt(r.Context(), "user_id")), true)
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.retention_failed"))
+		http.Redirect(w, r, "/admin/retention", http.StatusSeeOther)
+		return
+	}
+
+	rows := 0
+	for _, res := range run.Results {
+		rows += res.Rows
+	}
+	m.App.Session.Put(r.Context(), "flash", i18n.FromContext(r.Context()).T("flash.dry_run", rows))
+	http.Redirect(w, r, "/admin/retention", http.StatusSeeOther)
+}
@@ -1,22 +1,31 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
+	"html"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi"
+	"github.com/prashant9154/Booking_System/internal/clientip"
 	"github.com/prashant9154/Booking_System/internal/config"
 	"github.com/prashant9154/Booking_System/internal/driver"
+	"github.com/prashant9154/Booking_System/internal/events"
 	"github.com/prashant9154/Booking_System/internal/forms"
 	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/metrics"
 	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/privacy"
 	"github.com/prashant9154/Booking_System/internal/render"
 	"github.com/prashant9154/Booking_System/internal/repository"
 	"github.com/prashant9154/Booking_System/internal/repository/dbrepo"
+	"github.com/prashant9154/Booking_System/internal/retention"
+	"github.com/prashant9154/Booking_System/internal/webhooks"
 )
 
 // Repo is the repository used by handlers
@@ -24,18 +33,51 @@ var Repo *Repository
 
 // Repositiry is a Repository type
 type Repository struct {
-	App *config.AppConfig
-	DB  repository.DatabaseRepo
+	App       *config.AppConfig
+	DB        repository.DatabaseRepo
+	Webhooks  *webhooks.Dispatcher
+	Bots      *forms.Guard
+	Links     *privacy.Verifier
+	Retention *retention.Job
+
+	// draining is set once shutdown has begun
+	draining atomic.Bool
 }
 
 // NewRepo creates a new Repository
 func NewRepo(a *config.AppConfig, db *driver.DB) *Repository {
+	repo := dbrepo.NewInstrumentedRepo(dbrepo.NewPostgresRepo(db.SQL, a), a.Logger)
 	return &Repository{
-		App: a,
-		DB:  dbrepo.NewPostgresRepo(db.SQL, a),
+		App:       a,
+		DB:        repo,
+		Webhooks:  webhooks.New(repo, a.Logger),
+		Bots:      forms.NewGuard(a.Bot.Secret, a.Session, a.Bot.MinFillTime, a.Bot.MaxAge),
+		Links:     privacy.NewVerifier(a.Privacy.Secret, a.Privacy.LinkTTL),
+		Retention: newRetentionJob(a, repo),
 	}
 }
 
+// NewTestRepo creates a new Repository backed by the testing database repo
+func NewTestRepo(a *config.AppConfig) *Repository {
+	repo := dbrepo.NewTestingRepo(a)
+	return &Repository{
+		App:       a,
+		DB:        repo,
+		Webhooks:  webhooks.New(repo, a.Logger),
+		Bots:      forms.NewGuard("", a.Session, 0, time.Hour),
+		Links:     privacy.NewVerifier("", time.Hour),
+		Retention: newRetentionJob(a, repo),
+	}
+}
+
+// newRetentionJob creates the job applying the retention policies of a to the rows of db
+func newRetentionJob(a *config.AppConfig, db retention.Store) *retention.Job {
+	job := retention.New(db, a.Retention.Policies(), a.Logger)
+	job.Interval = a.Retention.Interval
+	job.DryRun = a.Retention.DryRun
+	return job
+}
+
 // NewHandlers sets repository for the handlers
 func NewHandlers(r *Repository) {
 	Repo = r
@@ -44,7 +86,7 @@ func NewHandlers(r *Repository) {
 // Home is a home page handler
 func (m *Repository) Home(w http.ResponseWriter, r *http.Request) {
 
-	remoteIP := r.RemoteAddr
+	remoteIP := clientip.FromRequest(r)
 	m.App.Session.Put(r.Context(), "remote_ip", remoteIP)
 
 	render.Templates(w, r, "home.page.hbs", &models.TemplateData{})
@@ -68,7 +110,64 @@ func (m *Repository) About(w http.ResponseWriter, r *http.Request) {
 
 // Contact is a Contact page handler
 func (m *Repository) Contact(w http.ResponseWriter, r *http.Request) {
-	render.Templates(w, r, "contact.page.hbs", &models.TemplateData{})
+	render.Templates(w, r, "contact.page.hbs", &models.TemplateData{
+		Form: forms.New(nil),
+		Data: map[string]interface{}{"challenge": m.Bots.Challenge(r.Context(), "contact")},
+	})
+}
+
+// PostContact handles the posting of the contact form
+func (m *Repository) PostContact(w http.ResponseWriter, r *http.Request) {
+	data, err := forms.ParseRequest(r)
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	locale := i18n.FromContext(r.Context())
+	form := forms.New(data).Localize(locale)
+
+	if !form.NotBot(r.Context(), m.Bots, "contact") {
+		m.flagBot(r, "contact", form)
+	}
+
+	var msg models.Message
+	err = form.Bind(&msg)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		if form.Flagged == "" {
+			metrics.ValidationFailures.WithLabelValues("contact").Inc()
+		}
+
+		render.Templates(w, r, "contact.page.hbs", &models.TemplateData{
+			Form: form,
+			Data: map[string]interface{}{"challenge": m.Bots.Challenge(r.Context(), "contact")},
+		})
+		return
+	}
+
+	_, err = m.DB.InsertMessage(r.Context(), msg)
+	if err != nil {
+		helpers.Error(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", locale.T("contact.sent"))
+	http.Redirect(w, r, "/contact", http.StatusSeeOther)
+}
+
+// flagBot logs and counts a submission of form that NotBot took for a bot's
+func (m *Repository) flagBot(r *http.Request, form string, f *forms.Form) {
+	m.App.Logger.WarnContext(r.Context(), "form submission flagged as a bot",
+		"form", form,
+		"reason", f.Flagged,
+		"user_agent", r.UserAgent(),
+	)
+	metrics.BotSubmissions.WithLabelValues(form, f.Flagged).Inc()
 }
 
 // Generals is a Generals page handler
@@ -88,6 +187,12 @@ func (m *Repository) Availability(w http.ResponseWriter, r *http.Request) {
 
 // PostAvailability is a Search avaialability page handler
 func (m *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
 	start := r.Form.Get("start")
 	end := r.Form.Get("end")
 
@@ -95,27 +200,32 @@ func (m *Repository) PostAvailability(w http.ResponseWriter, r *http.Request) {
 
 	startDate, err := time.Parse(layout, start)
 	if err != nil {
-		helpers.ServerError(w, err)
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.invalid_start_date"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
 		return
 	}
 
 	// fmt.Println(startDate)
 	endDate, err := time.Parse(layout, end)
 	if err != nil {
-		helpers.ServerError(w, err)
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.invalid_end_date"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
 		return
 	}
 
-	rooms, err := m.DB.SearchAvailabilityForAllRooms(startDate, endDate)
+	metrics.Searches.Inc()
+
+	rooms, err := m.DB.SearchAvailabilityForAllRooms(r.Context(), startDate, endDate)
 
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
 	if len(rooms) == 0 {
 		// No Availability
-		m.App.Session.Put(r.Context(), "error", "No Availability")
+		metrics.NoAvailability.Inc()
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.no_availability"))
 		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
 		return
 	}
@@ -150,18 +260,17 @@ type jsonResponse struct {
 func (m *Repository) AvailabilityJSON(w http.ResponseWriter, r *http.Request) {
 	resp := jsonResponse{
 		OK:      true,
-		Message: "Available!",
+		Message: i18n.FromContext(r.Context()).T("rooms.available"),
 	}
 
 	out, err := json.MarshalIndent(resp, "", "    ")
 
 	if err != nil {
 		// log.Println(err)
-		helpers.ServerError(w, err)
+		helpers.ServerError(w, r, err)
 		return
 	}
 
-	log.Println(string(out))
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(out)
 }
@@ -172,14 +281,16 @@ func (m *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 	res, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 
 	if !ok {
-		helpers.ServerError(w, errors.New("cannot get reservation from session"))
+		m.App.Logger.WarnContext(r.Context(), "cannot get reservation from session")
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.no_reservation"))
+		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	room, err := m.DB.GetRoomByID(res.RoomID)
+	room, err := m.DB.GetRoomByID(r.Context(), res.RoomID)
 
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.Error(w, r, err)
 		return
 	}
 
@@ -187,20 +298,13 @@ func (m *Repository) Reservation(w http.ResponseWriter, r *http.Request) {
 
 	m.App.Session.Put(r.Context(), "reservation", res)
 
-	sd := res.StartDate.Format("2006-01-02")
-	ed := res.EndDate.Format("2006-01-02")
-
-	stringMap := make(map[string]string)
-	stringMap["start_date"] = sd
-	stringMap["end_date"] = ed
-
 	data := make(map[string]interface{})
 	data["reservation"] = res
+	data["challenge"] = m.Bots.Challenge(r.Context(), "make-reservation")
 
 	render.Templates(w, r, "make-reservation.page.hbs", &models.TemplateData{
-		Form:      forms.New(nil),
-		Data:      data,
-		StringMap: stringMap,
+		Form: forms.New(nil),
+		Data: data,
 	})
 }
 
@@ -209,15 +313,16 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 	reservation, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 
 	if !ok {
-		helpers.ServerError(w, errors.New("cannot get reservation from the sesssion"))
+		m.App.Logger.WarnContext(r.Context(), "cannot get reservation from session")
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.no_reservation"))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	err := r.ParseForm()
+	data, err := forms.ParseRequest(r)
 
 	if err != nil {
-		// log.Println(err)
-		helpers.ServerError(w, err)
+		helpers.ClientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -228,30 +333,27 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 
 	// startDate, err := time.Parse(layout, sd)
 	// if err != nil {
-	// 	helpers.ServerError(w, err)
+	// 	helpers.ServerError(w, r, err)
 	// 	return
 	// }
 
 	// // fmt.Println(startDate)
 	// endDate, err := time.Parse(layout, ed)
 	// if err != nil {
-	// 	helpers.ServerError(w, err)
+	// 	helpers.ServerError(w, r, err)
 	// 	return
 	// }
 
 	// // fmt.Println(endDate)
 	// roomID, err := strconv.Atoi(r.Form.Get("room_id"))
 	// if err != nil {
-	// 	helpers.ServerError(w, err)
+	// 	helpers.ServerError(w, r, err)
 	// 	return
 	// }
 
 	// fmt.Println(roomID)
 
-	reservation.FirstName = r.Form.Get("first_name")
-	reservation.LastName = r.Form.Get("last_name")
-	reservation.Email = r.Form.Get("email")
-	reservation.Phone = r.Form.Get("phone")
+	reservation.Locale = i18n.FromContext(r.Context()).Tag
 
 	// reservation := models.Reservation{
 	// 	FirstName: r.Form.Get("first_name"),
@@ -263,18 +365,28 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 	// 	RoomID:    roomID,
 	// }
 
-	form := forms.New(r.PostForm)
-
-	// form.Has("first_name")
-	form.Required("first_name", "last_name", "email", "phone")
+	form := forms.New(data).Localize(i18n.FromContext(r.Context()))
 
-	form.ValidEmail("email")
+	if !form.NotBot(r.Context(), m.Bots, "make-reservation") {
+		m.flagBot(r, "make-reservation", form)
+	}
 
-	form.MinLength("first_name", 3)
+	// the guest's details are validated by the rules in the tags of models.Reservation;
+	// the phone number is stored as E.164, so it can be dialled whatever way it was typed
+	err = form.Bind(&reservation)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
 
 	if !form.Valid() {
+		if form.Flagged == "" {
+			metrics.ValidationFailures.WithLabelValues("make-reservation").Inc()
+		}
+
 		data := make(map[string]interface{})
 		data["reservation"] = reservation
+		data["challenge"] = m.Bots.Challenge(r.Context(), "make-reservation")
 
 		render.Templates(w, r, "make-reservation.page.hbs", &models.TemplateData{
 			Form: form,
@@ -283,27 +395,17 @@ func (m *Repository) PostReservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newReservationID, err := m.DB.InsertReservation(reservation)
+	// the reservation, its room restriction and the ReservationCreated event
+	// are written together, so nothing is announced if the insert fails
+	newReservationID, err := m.DB.CreateReservation(r.Context(), reservation, 1)
 
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.Error(w, r, err)
 		return
 	}
 
-	restriction := models.RoomRestriction{
-		StartDate:     reservation.StartDate,
-		EndDate:       reservation.EndDate,
-		RoomID:        reservation.RoomID,
-		ReservationID: newReservationID,
-		RestrictionID: 1,
-	}
-
-	err = m.DB.InsertRoomRestriction(restriction)
-
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
+	reservation.ID = newReservationID
+	metrics.ReservationsCreated.Inc()
 
 	m.App.Session.Put(r.Context(), "reservation", reservation)
 
@@ -316,8 +418,8 @@ func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request)
 
 	if !ok {
 		// log.Println("cannot get items from session")
-		m.App.ErrorLog.Println("cannot get items from session")
-		m.App.Session.Put(r.Context(), "error", "can't get reservation from session")
+		m.App.Logger.WarnContext(r.Context(), "cannot get reservation from session")
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.no_reservation"))
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
@@ -327,16 +429,8 @@ func (m *Repository) ReservationSummary(w http.ResponseWriter, r *http.Request)
 	data := make(map[string]interface{})
 	data["reservation"] = reservation
 
-	sd := reservation.StartDate.Format("2006-01-02")
-	ed := reservation.EndDate.Format("2006-01-02")
-
-	stringMap := make(map[string]string)
-	stringMap["start_date"] = sd
-	stringMap["end_date"] = ed
-
 	render.Templates(w, r, "reservation-summary.page.hbs", &models.TemplateData{
-		Data:      data,
-		StringMap: stringMap,
+		Data: data,
 	})
 }
 
@@ -344,13 +438,15 @@ func (m *Repository) ChooseRoom(w http.ResponseWriter, r *http.Request) {
 	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
 
 	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ClientError(w, r, http.StatusBadRequest)
 		return
 	}
 	res, ok := m.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 
 	if !ok {
-		helpers.ServerError(w, err)
+		m.App.Logger.WarnContext(r.Context(), "cannot get reservation from session")
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.no_reservation"))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
@@ -360,3 +456,109 @@ func (m *Repository) ChooseRoom(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/make-reservation", http.StatusSeeOther)
 }
+
+// ShowLogin shows the login screen
+func (m *Repository) ShowLogin(w http.ResponseWriter, r *http.Request) {
+	render.Templates(w, r, "login.page.hbs", &models.TemplateData{
+		Form: forms.New(nil),
+	})
+}
+
+// PostShowLogin handles logging the user in
+func (m *Repository) PostShowLogin(w http.ResponseWriter, r *http.Request) {
+	_ = m.App.Session.RenewToken(r.Context())
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	email := r.Form.Get("email")
+	password := r.Form.Get("password")
+
+	locale := i18n.FromContext(r.Context())
+
+	form := forms.New(r.PostForm).Localize(locale)
+	form.Required("email", "password")
+	form.ValidEmail("email")
+
+	if !form.Valid() {
+		metrics.ValidationFailures.WithLabelValues("login").Inc()
+
+		render.Templates(w, r, "login.page.hbs", &models.TemplateData{
+			Form: form,
+		})
+		return
+	}
+
+	id, _, err := m.DB.Authenticate(r.Context(), email, password)
+	if err != nil {
+		m.App.Logger.InfoContext(r.Context(), "failed login")
+		m.App.Session.Put(r.Context(), "error", locale.T("flash.invalid_login"))
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "user_id", id)
+	m.App.Session.Put(r.Context(), "flash", locale.T("flash.logged_in"))
+	http.Redirect(w, r, "/admin/webhooks", http.StatusSeeOther)
+}
+
+// Logout logs a user out
+func (m *Repository) Logout(w http.ResponseWriter, r *http.Request) {
+	_ = m.App.Session.Destroy(r.Context())
+	_ = m.App.Session.RenewToken(r.Context())
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// SendReservationConfirmation is an outbox subscriber that emails the guest when a reservation
+// is created. The event only names the reservation, so the guest's details are loaded, and
+// decrypted, here; nothing is sent once they were erased or the reservation is gone.
+func (m *Repository) SendReservationConfirmation(e models.Event) error {
+	var res events.Reservation
+	err := json.Unmarshal([]byte(e.Payload), &res)
+	if err != nil {
+		return err
+	}
+
+	guest, err := m.DB.GetReservationByID(context.Background(), res.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if guest.Email == "" {
+		return nil
+	}
+
+	// the email is written in the language the guest booked in
+	locale, ok := i18n.Get(res.Locale)
+	if !ok {
+		locale = i18n.Default()
+	}
+
+	htmlMessage := locale.T("email.confirmation.body",
+		html.EscapeString(guest.FirstName),
+		localDate(locale, res.StartDate),
+		localDate(locale, res.EndDate),
+	)
+
+	return m.App.MailQueue.Send(models.MailData{
+		To:      guest.Email,
+		From:    m.App.Mail.From,
+		Subject: locale.T("email.confirmation.subject"),
+		Content: htmlMessage,
+	})
+}
+
+// localDate formats a 2006-01-02 date from an event payload for locale, leaving it as is if it does not parse
+func localDate(locale *i18n.Locale, date string) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	return locale.Date(t)
+}
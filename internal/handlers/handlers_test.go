@@ -1,10 +1,26 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/events"
+	"github.com/prashant9154/Booking_System/internal/forms"
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/repository"
 )
 
 type postData struct {
@@ -25,7 +41,17 @@ var theTests = []struct {
 	{"majors-suite", "/majors-suite", "GET", []postData{}, http.StatusOK},
 	{"search-availability", "/search-availability", "GET", []postData{}, http.StatusOK},
 	{"contact", "/contact", "GET", []postData{}, http.StatusOK},
+	{"privacy", "/privacy", "GET", []postData{}, http.StatusOK},
 	{"make-res", "/make-reservation", "GET", []postData{}, http.StatusOK},
+	{"openapi", "/api/openapi.json", "GET", []postData{}, http.StatusOK},
+	{"healthz", "/healthz", "GET", []postData{}, http.StatusOK},
+	{"readyz", "/readyz", "GET", []postData{}, http.StatusOK},
+	{"version", "/version", "GET", []postData{}, http.StatusOK},
+	{"login", "/user/login", "GET", []postData{}, http.StatusOK},
+	{"admin-webhooks", "/admin/webhooks", "GET", []postData{}, http.StatusOK},
+	{"admin-new-webhook", "/admin/webhooks/new", "GET", []postData{}, http.StatusOK},
+	{"admin-privacy", "/admin/privacy", "GET", []postData{}, http.StatusOK},
+	{"admin-retention", "/admin/retention", "GET", []postData{}, http.StatusOK},
 	{"post-search-availability", "/search-availability", "Post", []postData{
 		{key: "start", value: "01-01-2023"},
 		{key: "end", value: "02-01-2023"},
@@ -40,6 +66,23 @@ var theTests = []struct {
 		{key: "email", value: "me@here.com"},
 		{key: "phone", value: "555-555-5555"},
 	}, http.StatusOK},
+	{"post-login", "/user/login", "Post", []postData{
+		{key: "email", value: "me@here.ca"},
+		{key: "password", value: "password"},
+	}, http.StatusOK},
+	{"post-new-webhook", "/admin/webhooks/new", "Post", []postData{
+		{key: "url", value: "https://example.com/hook"},
+		{key: "event_reservation.created", value: "1"},
+	}, http.StatusOK},
+	{"resend-webhook-delivery", "/admin/webhooks/deliveries/1/resend", "Post", []postData{}, http.StatusOK},
+	{"admin-privacy-export", "/admin/privacy/export", "Post", []postData{
+		{key: "email", value: "guest@example.com"},
+	}, http.StatusOK},
+	{"admin-privacy-export-invalid", "/admin/privacy/export", "Post", []postData{
+		{key: "email", value: "not an address"},
+	}, http.StatusOK},
+	{"admin-retention-dry-run", "/admin/retention/dry-run", "Post", []postData{}, http.StatusOK},
+	{"resend-missing-webhook-delivery", "/admin/webhooks/deliveries/99/resend", "Post", []postData{}, http.StatusNotFound},
 }
 
 func TestHandlers(t *testing.T) {
@@ -77,3 +120,520 @@ func TestHandlers(t *testing.T) {
 		}
 	}
 }
+
+func TestRepository_RedirectsOnBadInput(t *testing.T) {
+	routes := getRoutes()
+
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		params   url.Values
+		status   int
+		location string
+	}{
+		{"bad start date", "POST", "/search-availability", url.Values{"start": {"01-01-2023"}, "end": {"2023-01-02"}}, http.StatusSeeOther, "/search-availability"},
+		{"bad end date", "POST", "/search-availability", url.Values{"start": {"2023-01-01"}, "end": {"tomorrow"}}, http.StatusSeeOther, "/search-availability"},
+		{"no reservation in session", "GET", "/make-reservation", nil, http.StatusTemporaryRedirect, "/"},
+		{"post without reservation in session", "POST", "/make-reservation", url.Values{"first_name": {"John"}}, http.StatusSeeOther, "/"},
+		{"room chosen without reservation in session", "GET", "/choose-room/1", nil, http.StatusSeeOther, "/"},
+	}
+
+	for _, e := range tests {
+		var resp *http.Response
+		var err error
+		if e.method == "GET" {
+			resp, err = client.Get(ts.URL + e.url)
+		} else {
+			resp, err = client.PostForm(ts.URL+e.url, e.params)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != e.status || resp.Header.Get("Location") != e.location {
+			t.Errorf("for %s expected %d to %s but got %d to %s", e.name, e.status, e.location, resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+}
+
+func TestRepository_Translated(t *testing.T) {
+	routes := getRoutes()
+
+	tests := []struct {
+		locale string
+		method string
+		url    string
+		params url.Values
+		want   string
+	}{
+		{"de", "GET", "/", nil, "Willkommen im Fort Smythe Bed and Breakfast"},
+		{"fr", "GET", "/about", nil, "À propos de nous"},
+		{"es", "GET", "/generals-quarter", nil, "Comprobar disponibilidad"},
+		{"de", "GET", "/majors-suite", nil, "Verfügbarkeit prüfen"},
+		{"fr", "POST", "/search-availability", url.Values{"start": {"tomorrow"}, "end": {"2023-01-02"}}, "Date d"},
+		{"de", "GET", "/make-reservation", nil, "Ihre Reservierung wurde nicht gefunden"},
+	}
+
+	for _, e := range tests {
+		locale, _ := i18n.Get(e.locale)
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			routes.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+		}))
+
+		client := ts.Client()
+		client.Jar, _ = cookiejar.New(nil)
+
+		var resp *http.Response
+		var err error
+		if e.method == "GET" {
+			resp, err = client.Get(ts.URL + e.url)
+		} else {
+			resp, err = client.PostForm(ts.URL+e.url, e.params)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		ts.Close()
+
+		if !strings.Contains(string(body), e.want) {
+			t.Errorf("%s %s in %s: expected %q in the page", e.method, e.url, e.locale, e.want)
+		}
+	}
+}
+
+func TestRepository_ReadyzDraining(t *testing.T) {
+	routes := getRoutes()
+
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	Repo.Drain()
+	defer Repo.draining.Store(false)
+
+	resp, err := ts.Client().Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected %d while draining but got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	resp, err = ts.Client().Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected liveness to stay %d while draining but got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+// unreachableDB is a repository whose database cannot be reached
+type unreachableDB struct {
+	repository.DatabaseRepo
+}
+
+func (unreachableDB) Ping(ctx context.Context) error {
+	return errors.New("dial tcp 10.0.0.5:5432: connect: connection refused")
+}
+
+func TestRepository_ReadyzHidesErrors(t *testing.T) {
+	routes := getRoutes()
+
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	db := Repo.DB
+	Repo.DB = unreachableDB{db}
+	defer func() { Repo.DB = db }()
+
+	resp, err := ts.Client().Get(ts.URL + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body healthResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusServiceUnavailable || body.Checks["database"] != "unavailable" {
+		t.Errorf("expected the database check to fail without details but got %d %+v", resp.StatusCode, body)
+	}
+}
+
+func TestRepository_ErrorResponses(t *testing.T) {
+	routes := getRoutes()
+
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("POST", ts.URL+"/admin/webhooks/deliveries/99/resend", nil)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var body helpers.ErrorResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("error body is not JSON: %v", err)
+	}
+	if resp.StatusCode != http.StatusNotFound || body.Status != http.StatusNotFound {
+		t.Errorf("expected %d but got %d with body %+v", http.StatusNotFound, resp.StatusCode, body)
+	}
+
+	req, _ = http.NewRequest("POST", ts.URL+"/admin/webhooks/deliveries/99/resend", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json;q=0.9")
+
+	resp, err = ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	page, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(page), "Not Found") {
+		t.Errorf("expected the HTML error page but got %q", page)
+	}
+}
+
+// withChallenges adds to routes a page answering with a bot check token for the form in
+// its query and the answer to it, kept in the visitor's session as the forms' own are
+func withChallenges(routes http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", routes)
+	mux.Handle("/test/challenge", session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := Repo.Bots.Challenge(r.Context(), r.URL.Query().Get("form"))
+		fmt.Fprintf(w, "%s %d", c.Token, c.A+c.B)
+	})))
+	return mux
+}
+
+// challenge returns a bot check token for form and its answer, from a server whose routes
+// were wrapped by withChallenges
+func challenge(t *testing.T, client *http.Client, serverURL, form string) (string, string) {
+	t.Helper()
+
+	resp, err := client.Get(serverURL + "/test/challenge?form=" + form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	token, answer, _ := strings.Cut(string(body), " ")
+	return token, answer
+}
+
+func TestRepository_PostContact(t *testing.T) {
+	routes := withChallenges(getRoutes())
+
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := ts.Client()
+	client.Jar = jar
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	token, answer := challenge(t, client, ts.URL, "contact")
+	values := url.Values{
+		"name":               {" John  Smith "},
+		"email":              {"john@here.com"},
+		"message":            {"Do you allow pets?"},
+		forms.TokenField:     {token},
+		forms.ChallengeField: {answer},
+	}
+
+	resp, err := client.PostForm(ts.URL+"/contact", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("valid message: expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+
+	resp, err = client.PostForm(ts.URL+"/contact", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("replayed message: expected the form again with %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	values.Set(forms.HoneypotField, "http://spam.example.com")
+
+	resp, err = client.PostForm(ts.URL+"/contact", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("bot message: expected the form again with %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+}
+
+func TestRepository_PostReservation(t *testing.T) {
+	// the reservation is put in the session by choosing a room; seed it directly instead
+	mux := http.NewServeMux()
+	mux.Handle("/", withChallenges(getRoutes()))
+	mux.Handle("/test/reservation", session.LoadAndSave(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Put(r.Context(), "reservation", models.Reservation{
+			RoomID:    1,
+			StartDate: time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, 1, 3, 0, 0, 0, 0, time.UTC),
+			Room:      models.Room{ID: 1, RoomName: "General's Quarters"},
+		})
+	})))
+
+	ts := httptest.NewTLSServer(mux)
+	defer ts.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := ts.Client()
+	client.Jar = jar
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	_, err := client.Get(ts.URL + "/test/reservation")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, answer := challenge(t, client, ts.URL, "make-reservation")
+	values := url.Values{
+		"first_name":         {" John "},
+		"last_name":          {"Smith"},
+		"email":              {"John@Here.com"},
+		"phone":              {"+1 (415) 555-0123"},
+		forms.TokenField:     {token},
+		forms.ChallengeField: {answer},
+	}
+
+	resp, err := client.PostForm(ts.URL+"/make-reservation", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+	if location := resp.Header.Get("Location"); location != "/reservation-summary" {
+		t.Errorf("expected a redirect to /reservation-summary but got %q", location)
+	}
+
+	resp, err = client.Get(ts.URL + "/reservation-summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	summary := html.UnescapeString(string(body))
+
+	if !strings.Contains(summary, "+14155550123") {
+		t.Error("expected the phone number to be stored in E.164")
+	}
+	if !strings.Contains(summary, "john@here.com") {
+		t.Error("expected the email address to be normalized")
+	}
+}
+
+func TestRepository_CSPReport(t *testing.T) {
+	routes := getRoutes()
+
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	report := `{"csp-report": {"document-uri": "https://example.com/", "violated-directive": "script-src-elem", "blocked-uri": "inline"}}`
+
+	resp, err := ts.Client().Post(ts.URL+"/csp-report", "application/csp-report", strings.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected %d for a report but got %d", http.StatusNoContent, resp.StatusCode)
+	}
+
+	resp, err = ts.Client().Post(ts.URL+"/csp-report", "application/csp-report", strings.NewReader("not a report"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected %d for a body that is not a report but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestRepository_Privacy(t *testing.T) {
+	routes := withChallenges(getRoutes())
+
+	ts := httptest.NewTLSServer(routes)
+	defer ts.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := ts.Client()
+	client.Jar = jar
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	token, answer := challenge(t, client, ts.URL, "privacy")
+	resp, err := client.PostForm(ts.URL+"/privacy", url.Values{
+		"email":              {" Guest@Example.com "},
+		forms.TokenField:     {token},
+		forms.ChallengeField: {answer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("request: expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+
+	msg := <-app.MailQueue.Messages()
+	if msg.To != "guest@example.com" {
+		t.Errorf("expected the link to be sent to the normalized address but got %q", msg.To)
+	}
+	start := strings.Index(msg.Content, app.BaseURL+"/privacy/verify?token=")
+	if start < 0 {
+		t.Fatalf("no link in %q", msg.Content)
+	}
+	link := msg.Content[start+len(app.BaseURL):]
+	link = link[:strings.Index(link, `"`)]
+
+	resp, err = client.Get(ts.URL + "/privacy/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("export before following the link: expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+
+	resp, err = client.Get(ts.URL + link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("verify: expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+
+	resp, err = client.Get(ts.URL + "/privacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `action="/privacy/erase"`) {
+		t.Errorf("expected the verified address to be offered its data but got %q", body)
+	}
+
+	resp, err = client.Get(ts.URL + "/privacy/export?format=zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/zip" {
+		t.Errorf("export: expected a ZIP archive but got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = client.Get(ts.URL + "/privacy/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"email": "guest@example.com"`) {
+		t.Errorf("export: expected the JSON document but got %q", body)
+	}
+
+	resp, err = client.PostForm(ts.URL+"/privacy/erase", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Get(ts.URL + "/privacy/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("erase without confirming: expected the address to stay verified but got %d", resp.StatusCode)
+	}
+
+	resp, err = client.PostForm(ts.URL+"/privacy/erase", url.Values{"confirm": {"yes"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("erase: expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+	resp, err = client.Get(ts.URL + "/privacy/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("export after erasing: expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+
+	resp, err = client.Get(ts.URL + "/privacy/verify?token=forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Get(ts.URL + "/privacy/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("export after a forged link: expected %d but got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+}
+
+func TestRepository_SendReservationConfirmation(t *testing.T) {
+	getRoutes()
+
+	e, err := events.New(events.ReservationCreated, events.Reservation{ID: 1, StartDate: "2026-11-01", EndDate: "2026-11-03"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(e.Payload, "@") {
+		t.Errorf("expected the event to leave out the guest's details but got %s", e.Payload)
+	}
+
+	err = Repo.SendReservationConfirmation(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-app.MailQueue.Messages():
+		if msg.To != "guest@example.com" || !strings.Contains(msg.Content, "John") {
+			t.Errorf("expected the confirmation to be sent to the stored guest but got %+v", msg)
+		}
+	default:
+		t.Fatal("no confirmation sent")
+	}
+
+	e, _ = events.New(events.ReservationCreated, events.Reservation{ID: 3})
+	err = Repo.SendReservationConfirmation(e)
+	if err != nil {
+		t.Errorf("missing reservation: expected nothing to be retried but got %v", err)
+	}
+	if app.MailQueue.Len() != 0 {
+		t.Error("missing reservation: confirmation sent")
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/prashant9154/Booking_System/internal/buildinfo"
+	"github.com/prashant9154/Booking_System/internal/render"
+)
+
+// mailQueueLimit is the fraction of the mail queue above which the instance reports not ready
+const mailQueueLimit = 0.9
+
+type healthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Drain makes the readiness probe fail, so load balancers stop sending traffic before shutdown
+func (m *Repository) Drain() {
+	m.draining.Store(true)
+}
+
+// Healthz reports that the process is alive
+func (m *Repository) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Readyz reports whether the instance can serve traffic
+func (m *Repository) Readyz(w http.ResponseWriter, r *http.Request) {
+	ready := true
+	checks := map[string]string{}
+
+	fail := func(name, reason string) {
+		ready = false
+		checks[name] = reason
+	}
+
+	if m.draining.Load() {
+		fail("shutdown", "shutting down")
+	} else {
+		checks["shutdown"] = "ok"
+	}
+
+	// the probe is not authenticated, so the details of a failure are only logged
+	if err := m.DB.Ping(r.Context()); err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "readiness check failed", "check", "database", "error", err)
+		fail("database", "unavailable")
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if tc, err := render.Cache(); err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "readiness check failed", "check", "templates", "error", err)
+		fail("templates", "unavailable")
+	} else if len(tc) == 0 {
+		fail("templates", "template cache is empty")
+	} else {
+		checks["templates"] = "ok"
+	}
+
+	queued, capacity := m.App.MailQueue.Len(), m.App.MailQueue.Cap()
+	if capacity > 0 && float64(queued) >= float64(capacity)*mailQueueLimit {
+		fail("mail_queue", fmt.Sprintf("%d/%d queued", queued, capacity))
+	} else {
+		checks["mail_queue"] = "ok"
+	}
+
+	resp := healthResponse{Status: "ok", Checks: checks}
+	status := http.StatusOK
+	if !ready {
+		resp.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, status, resp)
+}
+
+// Version reports the build metadata of the running binary
+func (m *Repository) Version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildinfo.Get())
+}
+
+// writeJSON writes v as an indented JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(out)
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/prashant9154/Booking_System/internal/buildinfo"
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/openapi"
+	"github.com/prashant9154/Booking_System/internal/privacy"
+)
+
+// OpenAPISpec builds the OpenAPI document describing the JSON endpoints of the application.
+// Every JSON route registered in routes() must have an entry here.
+func OpenAPISpec() *openapi.Document {
+	doc := openapi.New("Bookings API", "1.0.0")
+	doc.Info.Description = "JSON endpoints of the Booking System"
+
+	// errors are sent as JSON to clients that accept it, and as an HTML page otherwise
+	errorBody := openapi.JSON(doc.Component("Error", helpers.ErrorResponse{}))
+
+	errorResponse := openapi.Response{
+		Description: "Internal server error",
+		Content:     errorBody,
+	}
+
+	csrfResponse := openapi.Response{
+		Description: "Missing or invalid CSRF token",
+		Content:     errorBody,
+	}
+
+	rateLimitResponse := openapi.Response{
+		Description: "Too many requests; the Retry-After header says in how many seconds to try again",
+		Content:     errorBody,
+	}
+
+	availabilityRequest := openapi.Object("csrf_token", "start", "end")
+	availabilityRequest.Properties["start"].Format = "date"
+	availabilityRequest.Properties["end"].Format = "date"
+
+	doc.Add("/search-availability-json", http.MethodPost, &openapi.Operation{
+		Summary:     "Check room availability",
+		OperationID: "searchAvailabilityJSON",
+		Tags:        []string{"availability"},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content:  openapi.Form(availabilityRequest),
+		},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "Availability result",
+				Content:     openapi.JSON(doc.Component("AvailabilityResponse", jsonResponse{})),
+			},
+			"400": csrfResponse,
+			"429": rateLimitResponse,
+			"500": errorResponse,
+		},
+	})
+
+	doc.Add("/api/openapi.json", http.MethodGet, &openapi.Operation{
+		Summary:     "This document",
+		OperationID: "getOpenAPI",
+		Tags:        []string{"meta"},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "OpenAPI document",
+				Content:     openapi.JSON(&openapi.Schema{Type: "object"}),
+			},
+			"500": errorResponse,
+		},
+	})
+
+	doc.Add("/healthz", http.MethodGet, &openapi.Operation{
+		Summary:     "Liveness probe",
+		OperationID: "healthz",
+		Tags:        []string{"meta"},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "The process is alive",
+				Content:     openapi.JSON(doc.Component("Health", healthResponse{})),
+			},
+		},
+	})
+
+	doc.Add("/readyz", http.MethodGet, &openapi.Operation{
+		Summary:     "Readiness probe",
+		Description: "Checks the database, the template cache and the mail queue. Fails once shutdown has begun.",
+		OperationID: "readyz",
+		Tags:        []string{"meta"},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "Ready to serve traffic",
+				Content:     openapi.JSON(&openapi.Schema{Ref: "#/components/schemas/Health"}),
+			},
+			"503": {
+				Description: "Not ready; the failing checks are reported",
+				Content:     openapi.JSON(&openapi.Schema{Ref: "#/components/schemas/Health"}),
+			},
+		},
+	})
+
+	doc.Add("/version", http.MethodGet, &openapi.Operation{
+		Summary:     "Build metadata",
+		OperationID: "version",
+		Tags:        []string{"meta"},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "Build metadata of the running binary",
+				Content:     openapi.JSON(doc.Component("Version", buildinfo.Info{})),
+			},
+		},
+	})
+
+	report := &openapi.Schema{Type: "object"}
+	doc.Add("/csp-report", http.MethodPost, &openapi.Operation{
+		Summary:     "Report Content Security Policy violations",
+		Description: "Sent by browsers for the report-uri of the policy; not protected against CSRF.",
+		OperationID: "cspReport",
+		Tags:        []string{"meta"},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"application/csp-report":   {Schema: report},
+				"application/reports+json": {Schema: &openapi.Schema{Type: "array", Items: report}},
+			},
+		},
+		Responses: map[string]openapi.Response{
+			"204": {Description: "The violations were recorded"},
+			"400": {
+				Description: "The body is not a violation report",
+				Content:     errorBody,
+			},
+			"429": rateLimitResponse,
+		},
+	})
+
+	// exports are downloads of a guest's data, as a JSON document or a ZIP archive of it and CSV files
+	export := map[string]openapi.MediaType{
+		"application/json": {Schema: doc.Component("PrivacyExport", privacy.Bundle{})},
+		"application/zip":  {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+	}
+
+	doc.Add("/privacy/export", http.MethodGet, &openapi.Operation{
+		Summary:     "Download the guest's data",
+		Description: "Exports the data of the email address the session was verified for; format=zip for a ZIP archive. Redirects to /privacy without a verified address.",
+		OperationID: "privacyExport",
+		Tags:        []string{"privacy"},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "Everything stored about the address",
+				Content:     export,
+			},
+			"303": {Description: "The session has no verified address"},
+			"500": errorResponse,
+		},
+	})
+
+	adminExport := openapi.Object("csrf_token", "email")
+	adminExport.Properties["email"].Format = "email"
+	adminExport.Properties["format"] = &openapi.Schema{Type: "string", Description: "json (the default) or zip"}
+
+	doc.Add("/admin/privacy/export", http.MethodPost, &openapi.Operation{
+		Summary:     "Download a guest's data",
+		Description: "Exports the data of any email address; for logged in administrators.",
+		OperationID: "adminPrivacyExport",
+		Tags:        []string{"privacy"},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content:  openapi.Form(adminExport),
+		},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "Everything stored about the address",
+				Content:     export,
+			},
+			"400": csrfResponse,
+			"500": errorResponse,
+		},
+	})
+
+	doc.Add("/metrics", http.MethodGet, &openapi.Operation{
+		Summary:     "Prometheus metrics",
+		OperationID: "metrics",
+		Tags:        []string{"meta"},
+		Responses: map[string]openapi.Response{
+			"200": {
+				Description: "Metrics in the Prometheus text exposition format",
+				Content:     openapi.Text(),
+			},
+		},
+	})
+
+	return doc
+}
+
+// OpenAPI serves the OpenAPI document of the application
+func (m *Repository) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	out, err := json.MarshalIndent(OpenAPISpec(), "", "    ")
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
@@ -0,0 +1,317 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/prashant9154/Booking_System/internal/audit"
+	"github.com/prashant9154/Booking_System/internal/forms"
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/privacy"
+	"github.com/prashant9154/Booking_System/internal/render"
+)
+
+// privacyEmailKey is the session key of the email address a guest proved they own by
+// following the link sent to it
+const privacyEmailKey = "privacy_email"
+
+// privacyRequest is the form naming the email address whose data is asked for
+type privacyRequest struct {
+	Email string `form:"email" normalize:"email" validate:"required,email,max=255"`
+}
+
+// Privacy shows the form guests ask for a link to their data with or, once they followed
+// one, what is stored about them and what they can do with it
+func (m *Repository) Privacy(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"challenge": m.Bots.Challenge(r.Context(), "privacy")}
+
+	if email := m.App.Session.GetString(r.Context(), privacyEmailKey); email != "" {
+		bundle, err := privacy.Collect(r.Context(), m.DB, m.App.Keyring, email)
+		if err != nil {
+			helpers.Error(w, r, err)
+			return
+		}
+		data["bundle"] = bundle
+	}
+
+	render.Templates(w, r, "privacy.page.hbs", &models.TemplateData{
+		Form: forms.New(nil),
+		Data: data,
+	})
+}
+
+// PostPrivacy emails a link to their data to the address a guest gives. The same answer
+// is given whether anything is stored about the address or not.
+func (m *Repository) PostPrivacy(w http.ResponseWriter, r *http.Request) {
+	data, err := forms.ParseRequest(r)
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	locale := i18n.FromContext(r.Context())
+	form := forms.New(data).Localize(locale)
+
+	if !form.NotBot(r.Context(), m.Bots, "privacy") {
+		m.flagBot(r, "privacy", form)
+	}
+
+	var req privacyRequest
+	err = form.Bind(&req)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		if form.Flagged == "" {
+			metrics.ValidationFailures.WithLabelValues("privacy").Inc()
+		}
+
+		render.Templates(w, r, "privacy.page.hbs", &models.TemplateData{
+			Form: form,
+			Data: map[string]interface{}{"challenge": m.Bots.Challenge(r.Context(), "privacy")},
+		})
+		return
+	}
+
+	email := req.Email
+
+	err = m.recordPrivacy(r, privacy.ActionRequest, audit.Guest, email, nil)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	link := strings.TrimSuffix(m.App.BaseURL, "/") + "/privacy/verify?token=" + url.QueryEscape(m.Links.Token(email))
+	err = m.App.MailQueue.Send(models.MailData{
+		To:      email,
+		From:    m.App.Mail.From,
+		Subject: locale.T("email.privacy.subject"),
+		Content: locale.T("email.privacy.body", link, link, int(m.App.Privacy.LinkTTL.Minutes())),
+	})
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash", locale.T("privacy.sent"))
+	http.Redirect(w, r, "/privacy", http.StatusSeeOther)
+}
+
+// VerifyPrivacy checks the link sent by PostPrivacy and lets the guest who followed it see
+// and erase the data of its address for the rest of the session
+func (m *Repository) VerifyPrivacy(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
+
+	email, err := m.Links.Verify(r.URL.Query().Get("token"))
+	if err != nil {
+		m.App.Session.Put(r.Context(), "error", locale.T("privacy.bad_link"))
+		http.Redirect(w, r, "/privacy", http.StatusSeeOther)
+		return
+	}
+
+	_ = m.App.Session.RenewToken(r.Context())
+	m.App.Session.Put(r.Context(), privacyEmailKey, email)
+	http.Redirect(w, r, "/privacy", http.StatusSeeOther)
+}
+
+// PrivacyExport downloads everything stored about the guest's verified address, as JSON
+// or, with format=zip, as a ZIP archive
+func (m *Repository) PrivacyExport(w http.ResponseWriter, r *http.Request) {
+	email := m.App.Session.GetString(r.Context(), privacyEmailKey)
+	if email == "" {
+		http.Redirect(w, r, "/privacy", http.StatusSeeOther)
+		return
+	}
+
+	m.exportPrivacy(w, r, email, r.URL.Query().Get("format"), audit.Guest)
+}
+
+// PostPrivacyErase erases the personal details stored with the guest's verified address
+func (m *Repository) PostPrivacyErase(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
+
+	email := m.App.Session.GetString(r.Context(), privacyEmailKey)
+	if email == "" {
+		http.Redirect(w, r, "/privacy", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("confirm") == "" {
+		m.App.Session.Put(r.Context(), "error", locale.T("privacy.confirm_required"))
+		http.Redirect(w, r, "/privacy", http.StatusSeeOther)
+		return
+	}
+
+	_, err = m.erasePrivacy(r, email, audit.Guest)
+	if err != nil {
+		helpers.Error(w, r, err)
+		return
+	}
+
+	m.App.Session.Remove(r.Context(), privacyEmailKey)
+	m.App.Session.Put(r.Context(), "flash", locale.T("privacy.erased"))
+	http.Redirect(w, r, "/privacy", http.StatusSeeOther)
+}
+
+// AdminPrivacy shows the forms for exporting and erasing a guest's data and the latest privacy operations
+func (m *Repository) AdminPrivacy(w http.ResponseWriter, r *http.Request) {
+	entries, err := m.DB.RecentAuditEntries(r.Context(), "privacy.", 100)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	render.Templates(w, r, "admin-privacy.page.hbs", &models.TemplateData{
+		Form: forms.New(nil),
+		Data: map[string]interface{}{"entries": entries},
+	})
+}
+
+// AdminPrivacyExport downloads everything stored about the email address in the form
+func (m *Repository) AdminPrivacyExport(w http.ResponseWriter, r *http.Request) {
+	email, ok := m.adminPrivacyEmail(w, r)
+	if !ok {
+		return
+	}
+
+	m.exportPrivacy(w, r, email, r.PostForm.Get("format"), audit.User(m.App.Session.GetInt(r.Context(), "user_id")))
+}
+
+// AdminPrivacyErase erases the personal details stored with the email address in the form
+func (m *Repository) AdminPrivacyErase(w http.ResponseWriter, r *http.Request) {
+	email, ok := m.adminPrivacyEmail(w, r)
+	if !ok {
+		return
+	}
+	if r.PostForm.Get("confirm") == "" {
+		m.App.Session.Put(r.Context(), "error", i18n.FromContext(r.Context()).T("flash.confirm_erasure"))
+		http.Redirect(w, r, "/admin/privacy", http.StatusSeeOther)
+		return
+	}
+
+	erased, err := m.erasePrivacy(r, email, audit.User(m.App.Session.GetInt(r.Context(), "user_id")))
+	if err != nil {
+		helpers.Error(w, r, err)
+		return
+	}
+
+	m.App.Session.Put(r.Context(), "flash",
+		i18n.FromContext(r.Context()).T("flash.erased", erased.Reservations, erased.Messages))
+	http.Redirect(w, r, "/admin/privacy", http.StatusSeeOther)
+}
+
+// adminPrivacyEmail returns the email address posted to an admin privacy form, showing
+// the form again if it is not valid
+func (m *Repository) adminPrivacyEmail(w http.ResponseWriter, r *http.Request) (string, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return "", false
+	}
+
+	form := forms.New(r.PostForm).Localize(i18n.FromContext(r.Context()))
+
+	var req privacyRequest
+	err = form.Bind(&req)
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return "", false
+	}
+
+	if !form.Valid() {
+		metrics.ValidationFailures.WithLabelValues("admin-privacy").Inc()
+
+		entries, err := m.DB.RecentAuditEntries(r.Context(), "privacy.", 100)
+		if err != nil {
+			helpers.ServerError(w, r, err)
+			return "", false
+		}
+
+		render.Templates(w, r, "admin-privacy.page.hbs", &models.TemplateData{
+			Form: form,
+			Data: map[string]interface{}{"entries": entries},
+		})
+		return "", false
+	}
+
+	return req.Email, true
+}
+
+// exportPrivacy records and writes the export of the data of email by actor, as JSON or, for format zip, as a ZIP archive
+func (m *Repository) exportPrivacy(w http.ResponseWriter, r *http.Request, email, format, actor string) {
+	if format != "zip" {
+		format = "json"
+	}
+
+	bundle, err := privacy.Collect(r.Context(), m.DB, m.App.Keyring, email)
+	if err != nil {
+		helpers.Error(w, r, err)
+		return
+	}
+
+	err = m.recordPrivacy(r, privacy.ActionExport, actor, email, map[string]interface{}{
+		"format":       format,
+		"reservations": len(bundle.Reservations),
+		"messages":     len(bundle.Messages),
+		"events":       len(bundle.Events),
+	})
+	if err != nil {
+		helpers.ServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Disposition", `attachment; filename="data-export.`+format+`"`)
+	if format == "zip" {
+		w.Header().Set("Content-Type", "application/zip")
+		err = bundle.WriteZIP(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = bundle.WriteJSON(w)
+	}
+	if err != nil {
+		m.App.Logger.ErrorContext(r.Context(), "cannot write data export", "error", err)
+	}
+}
+
+// erasePrivacy anonymizes the data of email and records, in the same transaction, that it
+// was erased by actor
+func (m *Repository) erasePrivacy(r *http.Request, email, actor string) (models.Erasure, error) {
+	entry := audit.New(r.Context(), privacy.ActionErase, actor, audit.Subject(m.App.Keyring, email), nil)
+
+	erased, err := m.DB.EraseGuest(r.Context(), email, entry)
+	if err != nil {
+		return erased, err
+	}
+
+	m.countPrivacy(r, privacy.ActionErase, actor)
+	return erased, nil
+}
+
+// recordPrivacy adds a privacy operation to the audit log and counts it
+func (m *Repository) recordPrivacy(r *http.Request, action, actor, email string, details interface{}) error {
+	err := m.DB.InsertAuditEntry(r.Context(), audit.New(r.Context(), action, actor, audit.Subject(m.App.Keyring, email), details))
+	if err != nil {
+		return err
+	}
+
+	m.countPrivacy(r, action, actor)
+	return nil
+}
+
+// countPrivacy logs and counts a privacy operation once it is in the audit log
+func (m *Repository) countPrivacy(r *http.Request, action, actor string) {
+	m.App.Logger.InfoContext(r.Context(), "privacy operation", "action", action, "actor", actor)
+	metrics.PrivacyOperations.WithLabelValues(action).Inc()
+}
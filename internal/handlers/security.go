@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/security"
+)
+
+// maxReportSize is the largest violation report read
+const maxReportSize = 64 << 10
+
+// directives are the Content Security Policy directives violations are counted by;
+// browsers report the more specific -elem and -attr forms too
+var directives = map[string]bool{
+	"default-src": true, "script-src": true, "script-src-elem": true, "script-src-attr": true,
+	"style-src": true, "style-src-elem": true, "style-src-attr": true, "img-src": true,
+	"font-src": true, "connect-src": true, "object-src": true, "base-uri": true,
+	"form-action": true, "frame-ancestors": true, "frame-src": true, "media-src": true,
+	"worker-src": true, "manifest-src": true,
+}
+
+// CSPReport collects the Content Security Policy violations browsers report, logging
+// and counting them
+func (m *Repository) CSPReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReportSize))
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	violations, err := security.ParseReports(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		helpers.ClientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	for _, v := range violations {
+		m.App.Logger.WarnContext(r.Context(), "content security policy violation",
+			"directive", v.Directive,
+			"blocked_uri", v.BlockedURI,
+			"document_uri", v.DocumentURI,
+			"source_file", v.SourceFile,
+			"line", v.Line,
+			"disposition", v.Disposition,
+		)
+
+		// the directive comes from the client, so only known ones become label values
+		directive, _, _ := strings.Cut(v.Directive, " ")
+		if !directives[directive] {
+			directive = "other"
+		}
+		metrics.CSPViolations.WithLabelValues(directive).Inc()
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,12 +2,13 @@ package handler
 
 import (
 	"encoding/gob"
-	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
@@ -15,13 +16,16 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/justinas/nosurf"
 	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/helpers"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/mailer"
 	"github.com/prashant9154/Booking_System/internal/models"
 	"github.com/prashant9154/Booking_System/internal/render"
 )
 
 var app config.AppConfig
 var session *scs.SessionManager
-var functions = template.FuncMap{}
+var functions = render.Functions()
 
 var pathToTemplates = "./../../templates"
 
@@ -32,11 +36,7 @@ func getRoutes() http.Handler {
 	// change this to true in production
 	app.InProduction = false
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	app.InfoLog = infoLog
-
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	app.ErrorLog = errorLog
+	app.Logger = logging.New(os.Stdout, "text", slog.LevelInfo)
 
 	session = scs.New()
 	session.Lifetime = 100 * time.Hour
@@ -46,6 +46,10 @@ func getRoutes() http.Handler {
 
 	app.Session = session
 
+	app.MailQueue = mailer.NewQueue(100)
+	app.BaseURL = "https://bookings.example.com"
+	app.Privacy.LinkTTL = time.Hour
+
 	tc, err := CreateTestTemplateCache()
 
 	if err != nil {
@@ -55,11 +59,13 @@ func getRoutes() http.Handler {
 	app.TemplateCache = tc
 	app.UseCache = true
 
-	repo := NewRepo(&app)
+	repo := NewTestRepo(&app)
 
 	NewHandlers(repo)
 
-	render.NewTemplates(&app)
+	render.NewRenderer(&app)
+
+	helpers.NewHelpers(&app)
 
 	mux := chi.NewRouter()
 	mux.Use(middleware.Recoverer)
@@ -69,6 +75,13 @@ func getRoutes() http.Handler {
 	mux.Get("/", Repo.Home)
 	mux.Get("/about", Repo.About)
 	mux.Get("/contact", Repo.Contact)
+	mux.Post("/contact", Repo.PostContact)
+
+	mux.Get("/privacy", Repo.Privacy)
+	mux.Post("/privacy", Repo.PostPrivacy)
+	mux.Get("/privacy/verify", Repo.VerifyPrivacy)
+	mux.Get("/privacy/export", Repo.PrivacyExport)
+	mux.Post("/privacy/erase", Repo.PostPrivacyErase)
 
 	mux.Get("/generals-quarter", Repo.Generals)
 	mux.Get("/majors-suite", Repo.Majors)
@@ -76,12 +89,37 @@ func getRoutes() http.Handler {
 	mux.Get("/search-availability", Repo.Availability)
 	mux.Post("/search-availability", Repo.PostAvailability)
 	mux.Post("/search-availability-json", Repo.AvailabilityJSON)
+	mux.Get("/choose-room/{id}", Repo.ChooseRoom)
 
 	mux.Get("/make-reservation", Repo.Reservation)
 	mux.Post("/make-reservation", Repo.PostReservation)
 
 	mux.Get("/reservation-summary", Repo.ReservationSummary)
 
+	mux.Get("/api/openapi.json", Repo.OpenAPI)
+	mux.Post("/csp-report", Repo.CSPReport)
+
+	mux.Get("/healthz", Repo.Healthz)
+	mux.Get("/readyz", Repo.Readyz)
+	mux.Get("/version", Repo.Version)
+
+	mux.Get("/user/login", Repo.ShowLogin)
+	mux.Post("/user/login", Repo.PostShowLogin)
+	mux.Get("/user/logout", Repo.Logout)
+
+	mux.Get("/admin/webhooks", Repo.AdminWebhooks)
+	mux.Get("/admin/webhooks/new", Repo.AdminNewWebhook)
+	mux.Post("/admin/webhooks/new", Repo.AdminPostNewWebhook)
+	mux.Post("/admin/webhooks/{id}/delete", Repo.AdminDeleteWebhook)
+	mux.Post("/admin/webhooks/deliveries/{id}/resend", Repo.AdminResendWebhookDelivery)
+
+	mux.Get("/admin/privacy", Repo.AdminPrivacy)
+	mux.Post("/admin/privacy/export", Repo.AdminPrivacyExport)
+	mux.Post("/admin/privacy/erase", Repo.AdminPrivacyErase)
+
+	mux.Get("/admin/retention", Repo.AdminRetention)
+	mux.Post("/admin/retention/dry-run", Repo.AdminRetentionDryRun)
+
 	fileServer := http.FileServer(http.Dir("./static/"))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
@@ -109,28 +147,29 @@ func SessionLoad(next http.Handler) http.Handler {
 
 func CreateTestTemplateCache() (map[string]*template.Template, error) {
 	myCache := map[string]*template.Template{}
+	fsys := os.DirFS(pathToTemplates)
 
-	// get all of the files named *.page.tmpl from ./templates
-	pages, err := filepath.Glob(fmt.Sprintf("%s/*.page.hbs", pathToTemplates))
+	// get all of the files named *.page.hbs from the templates directory
+	pages, err := fs.Glob(fsys, "*.page.hbs")
 	if err != nil {
 		return myCache, err
 	}
 
-	// range through all files ending with *.page.tmpl
+	// range through all files ending with *.page.hbs
 	for _, page := range pages {
-		name := filepath.Base(page)
-		ts, err := template.New(name).ParseFiles(page)
+		name := path.Base(page)
+		ts, err := template.New(name).Funcs(functions).ParseFS(fsys, page)
 		if err != nil {
 			return myCache, err
 		}
 
-		matches, err := filepath.Glob(fmt.Sprintf("%s/*.layout.hbs", pathToTemplates))
+		matches, err := fs.Glob(fsys, "*.layout.hbs")
 		if err != nil {
 			return myCache, err
 		}
 
 		if len(matches) > 0 {
-			ts, err = ts.ParseGlob(fmt.Sprintf("%s/*.layout.hbs", pathToTemplates))
+			ts, err = ts.ParseFS(fsys, "*.layout.hbs")
 			if err != nil {
 				return myCache, err
 			}
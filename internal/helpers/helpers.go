@@ -1,27 +1,157 @@
 package helpers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"runtime/debug"
+	"runtime"
+	"strings"
 
 	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/render"
+	"github.com/prashant9154/Booking_System/internal/repository"
 )
 
 var app *config.AppConfig
 
+// ErrorResponse is the body of an error response sent to clients that accept JSON
+type ErrorResponse struct {
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
 // NewHelpers sets up app config for helpers
 func NewHelpers(a *config.AppConfig) {
 	app = a
 }
 
-func ClientError(w http.ResponseWriter, status int) {
-	app.InfoLog.Println("Client error with status of", status)
-	http.Error(w, http.StatusText(status), status)
+// ClientError logs and writes a response for a request that cannot be served because of the client
+func ClientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.Logger.InfoContext(r.Context(), "client error", "status", status, "method", r.Method, "path", r.URL.Path)
+	ErrorPage(w, r, status)
+}
+
+// ServerError logs err with the location it was reported from and writes a 500 response
+func ServerError(w http.ResponseWriter, r *http.Request, err error) {
+	serverError(w, r, err, 3)
+}
+
+// Error logs err and writes the response for it: 404, 409 or 422 when it wraps
+// repository.ErrNotFound, ErrConflict or ErrValidation, and a 500 otherwise
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	status := Status(err)
+	if status == http.StatusInternalServerError {
+		serverError(w, r, err, 3)
+		return
+	}
+
+	app.Logger.InfoContext(r.Context(), "client error",
+		"status", status,
+		"error", err,
+		"method", r.Method,
+		"path", r.URL.Path,
+	)
+	ErrorPage(w, r, status)
+}
+
+// Status returns the HTTP status for err, according to the repository error it wraps
+func Status(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, repository.ErrValidation):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ErrorPage writes the response for status without logging it: a JSON ErrorResponse for
+// clients that accept JSON, and the error page otherwise. Both carry the request id and
+// explain the error in the visitor's language.
+func ErrorPage(w http.ResponseWriter, r *http.Request, status int) {
+	locale := i18n.FromContext(r.Context())
+
+	resp := ErrorResponse{
+		Status:    status,
+		Error:     http.StatusText(status),
+		Message:   http.StatusText(status),
+		RequestID: logging.RequestID(r.Context()),
+	}
+	title := resp.Error
+	if msg, ok := locale.Lookup(fmt.Sprintf("error.%d", status)); ok {
+		resp.Message = msg
+	}
+	if msg, ok := locale.Lookup(fmt.Sprintf("error.%d.title", status)); ok {
+		title = msg
+	}
+
+	if acceptsJSON(r) {
+		out, err := json.MarshalIndent(resp, "", "    ")
+		if err != nil {
+			http.Error(w, resp.Error, status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(out)
+		return
+	}
+
+	err := render.Error(w, r, status, &models.TemplateData{
+		StringMap: map[string]string{"title": title, "message": resp.Message},
+		IntMap:    map[string]int{"status": status},
+	})
+	if err != nil {
+		app.Logger.ErrorContext(r.Context(), "cannot render error page", "status", status, "error", err)
+		http.Error(w, resp.Error, status)
+	}
+}
+
+// acceptsJSON reports whether the client asks for JSON rather than HTML, as API clients
+// and scripts do; browsers list text/html first
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+
+	j := strings.Index(accept, "application/json")
+	if j < 0 {
+		return false
+	}
+
+	h := strings.Index(accept, "text/html")
+	return h < 0 || j < h
+}
+
+// serverError logs err with the location skip frames up the stack and writes a 500 response
+func serverError(w http.ResponseWriter, r *http.Request, err error, skip int) {
+	app.Logger.ErrorContext(r.Context(), "server error",
+		"error", err,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"caller", caller(skip),
+	)
+	ErrorPage(w, r, http.StatusInternalServerError)
+}
+
+// caller returns the file and line skip frames up the stack
+func caller(skip int) string {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", file, line)
 }
 
-func ServerError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.ErrorLog.Println(trace)
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+// IsAuthenticated returns true if a user is logged in
+func IsAuthenticated(r *http.Request) bool {
+	return app.Session.Exists(r.Context(), "user_id")
 }
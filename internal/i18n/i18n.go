@@ -0,0 +1,226 @@
+// Package i18n holds the message catalogs of the locales the site is translated into,
+// and formats dates and amounts the way each of them expects.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieName is the cookie that remembers the locale a visitor chose
+const CookieName = "lang"
+
+// CurrencySymbol is the currency amounts are charged in; locales only change how it is written
+const CurrencySymbol = "$"
+
+//go:embed locales/*.json
+var files embed.FS
+
+// Locale is a message catalog together with the conventions for writing dates and amounts
+type Locale struct {
+	// Tag is the language tag, e.g. "fr", taken from the catalog's file name
+	Tag string `json:"-"`
+	// Name is the name of the language in itself, e.g. "Français"
+	Name string `json:"name"`
+	// DateLayout is the time.Format layout of dates shown to guests
+	DateLayout string `json:"date_layout"`
+	// Decimal and Thousands separate the cents and the groups of digits of amounts
+	Decimal   string `json:"decimal"`
+	Thousands string `json:"thousands"`
+	// SymbolAfter writes the currency symbol after the amount, separated by a space
+	SymbolAfter bool `json:"symbol_after"`
+	// Messages maps message keys to fmt formats
+	Messages map[string]string `json:"messages"`
+}
+
+type contextKey struct{}
+
+var locales = mustLoad(files)
+
+var defaultLocale = locales["en"]
+
+// mustLoad reads the catalogs embedded in the binary, which are known to be valid
+func mustLoad(fsys fs.FS) map[string]*Locale {
+	loaded, err := Load(fsys)
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+// Load reads every locales/<tag>.json catalog in fsys
+func Load(fsys fs.FS) (map[string]*Locale, error) {
+	names, err := fs.Glob(fsys, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := map[string]*Locale{}
+	for _, name := range names {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		var l Locale
+		err = json.Unmarshal(b, &l)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		l.Tag = strings.TrimSuffix(path.Base(name), ".json")
+		loaded[l.Tag] = &l
+	}
+
+	return loaded, nil
+}
+
+// Get returns the locale with tag
+func Get(tag string) (*Locale, bool) {
+	l, ok := locales[strings.ToLower(tag)]
+	return l, ok
+}
+
+// Tags returns the tags of the available locales, sorted
+func Tags() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Default returns the locale used when the visitor's language is not available
+func Default() *Locale {
+	return defaultLocale
+}
+
+// SetDefault sets the locale used when the visitor's language is not available
+func SetDefault(tag string) error {
+	l, ok := Get(tag)
+	if !ok {
+		return fmt.Errorf("no catalog for locale %q", tag)
+	}
+	defaultLocale = l
+	return nil
+}
+
+// Match returns the available locale the visitor prefers, according to an Accept-Language
+// header, falling back from a regional tag such as fr-CH to its language
+func Match(acceptLanguage string) *Locale {
+	type preference struct {
+		tag string
+		q   float64
+	}
+
+	var prefs []preference
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			prefs = append(prefs, preference{tag, q})
+		}
+	}
+
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, p := range prefs {
+		if l, ok := Get(p.tag); ok {
+			return l
+		}
+		language, _, _ := strings.Cut(p.tag, "-")
+		if l, ok := Get(language); ok {
+			return l
+		}
+	}
+
+	return Default()
+}
+
+// WithLocale returns a copy of ctx carrying l
+func WithLocale(ctx context.Context, l *Locale) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the locale of the request ctx belongs to, or the default locale
+func FromContext(ctx context.Context) *Locale {
+	if l, ok := ctx.Value(contextKey{}).(*Locale); ok {
+		return l
+	}
+	return Default()
+}
+
+// Lookup returns the message for key in l, or in the default locale if l has no translation
+func (l *Locale) Lookup(key string) (string, bool) {
+	if msg, ok := l.Messages[key]; ok {
+		return msg, true
+	}
+	msg, ok := Default().Messages[key]
+	return msg, ok
+}
+
+// T returns the message for key formatted with args, or the key itself if no catalog has it
+func (l *Locale) T(key string, args ...interface{}) string {
+	msg, ok := l.Lookup(key)
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Date formats t for display, or returns "" for the zero time
+func (l *Locale) Date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(l.DateLayout)
+}
+
+// Money formats amount in CurrencySymbol with two decimals, e.g. $1,234.50 or 1.234,50 $
+func (l *Locale) Money(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.Thousands)
+		}
+		b.WriteRune(digit)
+	}
+
+	number := fmt.Sprintf("%s%s%02d", b.String(), l.Decimal, cents%100)
+	if l.SymbolAfter {
+		return sign + number + " " + CurrencySymbol
+	}
+	return sign + CurrencySymbol + number
+}
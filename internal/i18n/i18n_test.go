@@ -0,0 +1,107 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCatalogsAreComplete(t *testing.T) {
+	for _, tag := range Tags() {
+		l, _ := Get(tag)
+		for key := range Default().Messages {
+			if _, ok := l.Messages[key]; !ok {
+				t.Errorf("%s catalog has no translation for %q", tag, key)
+			}
+		}
+		if l.DateLayout == "" || l.Decimal == "" {
+			t.Errorf("%s catalog has no date or number format", tag)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"fr", "fr"},
+		{"fr-CH, fr;q=0.9, en;q=0.8", "fr"},
+		{"ja, de;q=0.7, en;q=0.8", "en"},
+		{"en;q=0.1, es-MX", "es"},
+		{"DE-at", "de"},
+		{"ja, *;q=0.5", "en"},
+		{"fr;q=0, es;q=0.2", "es"},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.header).Tag; got != tt.want {
+			t.Errorf("Match(%q): got %s, wanted %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLocale_T(t *testing.T) {
+	fr, _ := Get("fr")
+
+	if got := fr.T("form.min_length", 3); got != "Ce champ doit contenir au moins 3 caractères" {
+		t.Errorf("got %q", got)
+	}
+
+	defer func(m map[string]string) { fr.Messages = m }(fr.Messages)
+	fr.Messages = map[string]string{}
+
+	if got := fr.T("form.email"); got != "Invalid email address" {
+		t.Errorf("missing translation did not fall back to the default locale: got %q", got)
+	}
+	if got := fr.T("no.such.key"); got != "no.such.key" {
+		t.Errorf("unknown key: got %q", got)
+	}
+}
+
+func TestLocale_DateAndMoney(t *testing.T) {
+	day := time.Date(2026, 7, 14, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		tag   string
+		date  string
+		money string
+	}{
+		{"en", "Jul 14, 2026", "$1,234.50"},
+		{"fr", "14/07/2026", "1 234,50 $"},
+		{"de", "14.07.2026", "1.234,50 $"},
+	}
+
+	for _, tt := range tests {
+		l, _ := Get(tt.tag)
+		if got := l.Date(day); got != tt.date {
+			t.Errorf("%s date: got %q, wanted %q", tt.tag, got, tt.date)
+		}
+		if got := l.Money(1234.5); got != tt.money {
+			t.Errorf("%s money: got %q, wanted %q", tt.tag, got, tt.money)
+		}
+	}
+
+	if got := Default().Date(time.Time{}); got != "" {
+		t.Errorf("zero time formatted as %q", got)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	defer SetDefault("en")
+
+	if err := SetDefault("xx"); err == nil {
+		t.Error("set a default locale with no catalog")
+	}
+
+	if err := SetDefault("de"); err != nil {
+		t.Fatal(err)
+	}
+	if got := FromContext(context.Background()).Tag; got != "de" {
+		t.Errorf("context without a locale got %s, wanted the default", got)
+	}
+	if got := Match("ja").Tag; got != "de" {
+		t.Errorf("unavailable language got %s, wanted the default", got)
+	}
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prashant9154/Booking_System/internal/clientip"
+)
+
+func TestNew_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "json", slog.LevelInfo)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	ctx = clientip.WithIP(ctx, "192.0.2.1")
+	logger.InfoContext(ctx, "hello", "room", 1)
+	logger.DebugContext(ctx, "not shown")
+
+	var got map[string]any
+	err := json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("expected a single JSON record but got %q: %s", buf.String(), err)
+	}
+
+	if got["msg"] != "hello" || got["request_id"] != "abc123" || got["client_ip"] != "192.0.2.1" || got["room"] != float64(1) {
+		t.Errorf("unexpected record %v", got)
+	}
+}
+
+func TestNew_Text(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "text", slog.LevelDebug).With("component", "test")
+
+	logger.DebugContext(WithRequestID(context.Background(), "abc123"), "hello")
+
+	out := buf.String()
+	for _, want := range []string{"level=DEBUG", "msg=hello", "component=test", "request_id=abc123"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	var tests = []struct {
+		id    string
+		valid bool
+	}{
+		{NewRequestID(), true},
+		{"req-1.2_3", true},
+		{"", false},
+		{"has space", false},
+		{"new\nline", false},
+		{strings.Repeat("a", 65), false},
+	}
+
+	for _, e := range tests {
+		if got := ValidRequestID(e.id); got != e.valid {
+			t.Errorf("ValidRequestID(%q) = %t, expected %t", e.id, got, e.valid)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+// Package mailer queues outgoing email for the mail listener, refusing new messages once
+// the queue is closed instead of panicking on a closed channel.
+package mailer
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// ErrClosed is returned when a message is sent after the queue was closed
+var ErrClosed = errors.New("mail queue is closed")
+
+// Queue is a buffered queue of messages, safe to send on from any goroutine while it is closed
+type Queue struct {
+	mu     sync.RWMutex
+	closed bool
+	ch     chan models.MailData
+}
+
+// NewQueue returns an open queue holding up to size messages before Send blocks
+func NewQueue(size int) *Queue {
+	return &Queue{ch: make(chan models.MailData, size)}
+}
+
+// Send queues msg, waiting while the queue is full, and fails with ErrClosed once it is closed
+func (q *Queue) Send(msg models.MailData) error {
+	// the read lock is held while waiting, so Close cannot close the channel under a sender
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	q.ch <- msg
+	return nil
+}
+
+// Close stops the queue taking messages; the ones already queued can still be received.
+// It waits for senders blocked on a full queue, so the queue must still be drained.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+}
+
+// Messages returns the channel the queued messages are received from, closed after Close
+func (q *Queue) Messages() <-chan models.MailData {
+	return q.ch
+}
+
+// Len returns the number of messages waiting to be sent
+func (q *Queue) Len() int {
+	return len(q.ch)
+}
+
+// Cap returns the number of messages the queue holds
+func (q *Queue) Cap() int {
+	return cap(q.ch)
+}
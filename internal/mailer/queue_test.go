@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+func TestQueue_SendAfterClose(t *testing.T) {
+	q := NewQueue(1)
+
+	err := q.Send(models.MailData{To: "guest@example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q.Close()
+	q.Close()
+
+	err = q.Send(models.MailData{To: "guest@example.com"})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed but got %v", err)
+	}
+
+	var received int
+	for range q.Messages() {
+		received++
+	}
+	if received != 1 {
+		t.Errorf("expected the queued message to be received after Close but got %d", received)
+	}
+}
+
+func TestQueue_CloseWhileSending(t *testing.T) {
+	q := NewQueue(1)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range q.Messages() {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := q.Send(models.MailData{To: "guest@example.com"})
+			if err != nil && !errors.Is(err, ErrClosed) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+
+	q.Close()
+	wg.Wait()
+	<-done
+}
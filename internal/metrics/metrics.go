@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "bookings"
+
+// Registry holds every metric of the application
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of DatabaseRepo calls by method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"method"})
+
+	dbErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Failed DatabaseRepo calls by method.",
+	}, []string{"method"})
+
+	// Searches counts availability searches
+	Searches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "searches_total",
+		Help:      "Availability searches.",
+	})
+
+	// NoAvailability counts availability searches that found no rooms
+	NoAvailability = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "search_no_availability_total",
+		Help:      "Availability searches that found no rooms.",
+	})
+
+	// ReservationsCreated counts reservations written to the database
+	ReservationsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reservations_created_total",
+		Help:      "Reservations created.",
+	})
+
+	// ValidationFailures counts form submissions rejected by validation, by form
+	ValidationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "form_validation_failures_total",
+		Help:      "Form submissions that failed validation, by form.",
+	}, []string{"form"})
+
+	// BotSubmissions counts form submissions taken for a bot's, by form and reason
+	BotSubmissions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "form_bot_submissions_total",
+		Help:      "Form submissions flagged as coming from a bot, by form and reason.",
+	}, []string{"form", "reason"})
+
+	// RateLimited counts requests refused for going over a rate limit, by route group
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests refused with a 429 for going over a rate limit, by route group.",
+	}, []string{"group"})
+
+	// CSPViolations counts the Content Security Policy violations reported by browsers, by directive
+	CSPViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "csp_violations_total",
+		Help:      "Content Security Policy violations reported by browsers, by directive.",
+	}, []string{"directive"})
+
+	// PrivacyOperations counts the privacy requests, exports and erasures of guest data, by action
+	PrivacyOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "privacy_operations_total",
+		Help:      "Privacy requests, exports and erasures of guest data, by action.",
+	}, []string{"action"})
+
+	// OutboxEventsAbandoned counts the outbox events left undispatched after failing too often, by type
+	OutboxEventsAbandoned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "outbox_events_abandoned_total",
+		Help:      "Outbox events no longer retried after failing their last attempt, by type.",
+	}, []string{"event"})
+
+	// RetentionRows counts the rows anonymized or deleted by the retention job, by table and action
+	RetentionRows = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_rows_total",
+		Help:      "Rows anonymized or deleted by the retention job, by table and action.",
+	}, []string{"table", "action"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		dbDuration,
+		dbErrors,
+		Searches,
+		NoAvailability,
+		ReservationsCreated,
+		ValidationFailures,
+		BotSubmissions,
+		RateLimited,
+		CSPViolations,
+		PrivacyOperations,
+		OutboxEventsAbandoned,
+		RetentionRows,
+	)
+}
+
+// RegisterDB exports the connection pool statistics of db
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, "bookings"))
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a served HTTP request
+func ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// ObserveQuery records a DatabaseRepo call
+func ObserveQuery(method string, elapsed time.Duration, err error) {
+	dbDuration.WithLabelValues(method).Observe(elapsed.Seconds())
+	if err != nil {
+		dbErrors.WithLabelValues(method).Inc()
+	}
+}
@@ -0,0 +1,130 @@
+// Package migrations applies the fizz migrations in the migrations directory, so a
+// deployed binary can migrate its database without the soda tool or the source tree.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/gobuffalo/fizz"
+	"github.com/gobuffalo/fizz/translators"
+)
+
+// Table records the applied migrations. It is the table soda uses, so a migration
+// applied by either tool is not applied again by the other.
+const Table = "schema_migration"
+
+// migrationFile matches e.g. 20261019090000_create_outbox_table.up.fizz
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.(fizz|sql)$`)
+
+// Migration is a single up migration
+type Migration struct {
+	Version string
+	Name    string
+	Path    string
+}
+
+// List returns the up migrations in fsys, oldest first
+func List(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Migration
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil || m[3] != "up" {
+			continue
+		}
+		list = append(list, Migration{Version: m[1], Name: m[2], Path: e.Name()})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// SQL returns the postgres statements of a migration, translating it if it is written in fizz
+func SQL(fsys fs.FS, m Migration) (string, error) {
+	b, err := fs.ReadFile(fsys, m.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if path.Ext(m.Path) == ".sql" {
+		return string(b), nil
+	}
+
+	stmts, err := fizz.AString(string(b), translators.NewPostgres())
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", m.Path, err)
+	}
+	return stmts, nil
+}
+
+// Up applies every migration in fsys that has not been applied yet, oldest first and
+// each in its own transaction, returning the versions it applied
+func Up(ctx context.Context, db *sql.DB, fsys fs.FS) ([]string, error) {
+	_, err := db.ExecContext(ctx, "create table if not exists "+Table+" (version varchar(14) not null primary key)")
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := List(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	for _, m := range list {
+		ok, err := apply(ctx, db, fsys, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s_%s: %w", m.Version, m.Name, err)
+		}
+		if ok {
+			applied = append(applied, m.Version)
+		}
+	}
+
+	return applied, nil
+}
+
+// apply runs m unless it has already been applied, reporting whether it ran
+func apply(ctx context.Context, db *sql.DB, fsys fs.FS, m Migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, "select exists(select 1 from "+Table+" where version = $1)", m.Version).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+
+	stmts, err := SQL(fsys, m)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, stmts)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, "insert into "+Table+" (version) values ($1)", m.Version)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
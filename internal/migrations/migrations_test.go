@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	bookings "github.com/prashant9154/Booking_System"
+)
+
+func TestList(t *testing.T) {
+	fsys := fstest.MapFS{
+		"20261019090100_second.up.fizz":   {},
+		"20261019090100_second.down.fizz": {},
+		"20261019090000_first.up.sql":     {},
+		"README.md":                       {},
+	}
+
+	list, err := List(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 2 || list[0].Name != "first" || list[1].Version != "20261019090100" {
+		t.Errorf("unexpected migrations %+v", list)
+	}
+}
+
+func TestSQL_Embedded(t *testing.T) {
+	fsys, err := fs.Sub(bookings.Assets(false, ""), "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := List(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for _, m := range list {
+		stmts, err := SQL(fsys, m)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if strings.TrimSpace(stmts) == "" {
+			t.Errorf("%s translated to nothing", m.Path)
+		}
+	}
+
+	sessions := Migration{Path: "20261019110000_create_sessions_table.up.fizz"}
+	stmts, err := SQL(fsys, sessions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stmts, `CREATE TABLE "sessions"`) {
+		t.Errorf("unexpected translation %s", stmts)
+	}
+}
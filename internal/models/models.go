@@ -38,13 +38,14 @@ type Restriction struct {
 	UpdatedAt       time.Time
 }
 
-// Reservation is the reservation model
+// Reservation is the reservation model; the tags clean up, bind and validate the guest's details
+// from the make-reservation form with forms.Bind
 type Reservation struct {
 	ID        int
-	FirstName string
-	LastName  string
-	Email     string
-	Phone     string
+	FirstName string `form:"first_name" normalize:"text" validate:"required,min=3,max=255"`
+	LastName  string `form:"last_name" normalize:"text" validate:"required,max=255"`
+	Email     string `form:"email" normalize:"email" validate:"required,email,max=255"`
+	Phone     string `form:"phone" validate:"required,phone"`
 	StartDate time.Time
 	EndDate   time.Time
 	RoomID    int
@@ -52,6 +53,8 @@ type Reservation struct {
 	UpdatedAt time.Time
 	Room      Room
 	Processed int
+	// Locale is the tag of the language the guest booked in, used for their emails
+	Locale string
 }
 
 // RoomRestriction is the room restriction model
@@ -68,3 +71,103 @@ type RoomRestriction struct {
 	Reservation   Reservation
 	Restriction   Restriction
 }
+
+// WebhookSubscription is the webhook subscription model
+type WebhookSubscription struct {
+	ID        int
+	URL       string
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookDelivery is a single queued delivery of an event to a webhook subscription
+type WebhookDelivery struct {
+	ID             int
+	SubscriptionID int
+	EventID        string
+	Event          string
+	Payload        string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string
+	ResponseCode   int
+	DeliveredAt    time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Subscription   WebhookSubscription
+}
+
+// Event is a domain event stored in the outbox
+type Event struct {
+	ID            string
+	Type          string
+	Payload       string
+	OccurredAt    time.Time
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	DispatchedAt  time.Time
+}
+
+// Message is a message sent with the contact form
+type Message struct {
+	ID        int
+	Name      string `form:"name" normalize:"text" validate:"required,max=255"`
+	Email     string `form:"email" normalize:"email" validate:"required,email,max=255"`
+	Body      string `form:"message" validate:"required,max=5000"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// AuditEntry records an operation on personal data: who did what, to whose data and from where.
+// Subject is a hash of the guest's email address, so the log itself holds no address.
+type AuditEntry struct {
+	ID        int
+	Action    string
+	Actor     string
+	Subject   string
+	ClientIP  string
+	RequestID string
+	Details   string
+	CreatedAt time.Time
+}
+
+// Erasure counts the rows anonymized, or deleted for events, by erasing a guest's personal details
+type Erasure struct {
+	Reservations      int `json:"reservations"`
+	Messages          int `json:"messages"`
+	Events            int `json:"events"`
+	WebhookDeliveries int `json:"webhook_deliveries"`
+}
+
+// RetentionRun is a run of the job that anonymizes and deletes data past its retention period
+type RetentionRun struct {
+	ID         int
+	Actor      string
+	DryRun     bool
+	Results    []RetentionResult
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// RetentionResult is the number of rows a retention policy changed, or would have in a dry run
+type RetentionResult struct {
+	Table  string `json:"table"`
+	Action string `json:"action"`
+	After  string `json:"after"`
+	Rows   int    `json:"rows"`
+	Error  string `json:"error,omitempty"`
+}
+
+// MailData holds an email message
+type MailData struct {
+	To      string
+	From    string
+	Subject string
+	Content string
+}
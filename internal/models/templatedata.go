@@ -13,4 +13,10 @@ type TemplateData struct {
 	Warning   string
 	Error     string
 	Form      *forms.Form
+	RequestID string
+	Locale    string
+	// CSPNonce lets the page's inline scripts and styles run under the Content Security Policy
+	CSPNonce string
+
+	IsAuthenticated int
 }
@@ -0,0 +1,55 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+type testStruct struct {
+	Name     string    `json:"name"`
+	Count    int       `json:"count,omitempty"`
+	When     time.Time `json:"when"`
+	Tags     []string  `json:"tags"`
+	Ignored  string    `json:"-"`
+	internal string
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := SchemaOf(testStruct{})
+
+	if s.Type != "object" {
+		t.Errorf("expected object but got %s", s.Type)
+	}
+
+	if len(s.Properties) != 4 {
+		t.Errorf("expected 4 properties but got %d", len(s.Properties))
+	}
+
+	if s.Properties["when"].Format != "date-time" {
+		t.Error("time.Time not described as date-time")
+	}
+
+	if s.Properties["tags"].Items == nil || s.Properties["tags"].Items.Type != "string" {
+		t.Error("slice items not described")
+	}
+
+	for _, r := range s.Required {
+		if r == "count" {
+			t.Error("omitempty field marked as required")
+		}
+	}
+}
+
+func TestDocument_Has(t *testing.T) {
+	doc := New("test", "1")
+	doc.Add("/x", http.MethodPost, &Operation{OperationID: "x"})
+
+	if !doc.Has("/x", "POST") {
+		t.Error("document does not have operation that was added")
+	}
+
+	if doc.Has("/x", http.MethodGet) {
+		t.Error("document has operation that was not added")
+	}
+}
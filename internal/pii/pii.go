@@ -0,0 +1,336 @@
+// Package pii encrypts the personal details of guests before they are stored, with
+// envelope encryption: every value is sealed with a data key of its own, which is in turn
+// wrapped by one of the master keys of a Keyring. Rotating the master key only rewraps
+// the data keys. A keyed hash stored next to each encrypted email address lets rows
+// still be looked up by it.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Columns are the columns that can be encrypted, as table.column
+var Columns = []string{"reservations.email", "reservations.phone", "messages.email"}
+
+// prefix starts every encrypted value, followed by the key id, the wrapped data key and
+// the sealed value, separated by colons
+const prefix = "enc:v1:"
+
+// keySize is the size of master and data keys, for AES-256
+const keySize = 32
+
+// validKeyID limits key ids to what can be kept in an encrypted value
+var validKeyID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// ErrUnknownKey is returned when a value was encrypted with a key that is not in the keyring
+var ErrUnknownKey = errors.New("pii: value encrypted with an unknown key")
+
+// Key is a master key, which wraps data keys, and the id it is known by
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Keyring encrypts and decrypts values with its master keys, encrypting new values with
+// the primary one. A nil Keyring, or one without keys, leaves values in plain text.
+type Keyring struct {
+	keys    map[string][]byte
+	primary string
+	hashKey []byte
+	columns map[string]bool
+}
+
+// New returns a Keyring encrypting columns with the key called primary, or the last of
+// keys if primary is empty, and hashing with hashKey. Without keys values are not encrypted,
+// and without a hash key they are not hashed.
+func New(keys []Key, primary string, hashKey []byte, columns []string) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}, columns: map[string]bool{}}
+
+	for _, key := range keys {
+		if !validKeyID.MatchString(key.ID) {
+			return nil, fmt.Errorf("pii: %q is not a valid key id", key.ID)
+		}
+		if len(key.Secret) != keySize {
+			return nil, fmt.Errorf("pii: key %s must be %d bytes, not %d", key.ID, keySize, len(key.Secret))
+		}
+		if _, ok := k.keys[key.ID]; ok {
+			return nil, fmt.Errorf("pii: key %s is listed twice", key.ID)
+		}
+		k.keys[key.ID] = key.Secret
+		k.primary = key.ID
+	}
+
+	if primary != "" {
+		if _, ok := k.keys[primary]; !ok {
+			return nil, fmt.Errorf("pii: primary key %s is not one of the keys", primary)
+		}
+		k.primary = primary
+	}
+
+	if len(keys) > 0 && len(hashKey) < keySize {
+		return nil, fmt.Errorf("pii: the hash key must be at least %d bytes", keySize)
+	}
+	k.hashKey = hashKey
+
+	for _, c := range columns {
+		if !Supported(c) {
+			return nil, fmt.Errorf("pii: %s cannot be encrypted", c)
+		}
+		k.columns[c] = true
+	}
+
+	return k, nil
+}
+
+// Supported reports whether column, as table.column, is one of the Columns that can be encrypted
+func Supported(column string) bool {
+	for _, c := range Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseKeys reads keys written as id=base64, separated by commas or lines. Blank lines
+// and lines starting with # are skipped.
+func ParseKeys(text string) ([]Key, error) {
+	var keys []Key
+
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errors.New("pii: keys must be written as id=base64")
+		}
+		b, err := DecodeKey(secret)
+		if err != nil {
+			return nil, fmt.Errorf("pii: key %s: %w", strings.TrimSpace(id), err)
+		}
+
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Secret: b})
+	}
+
+	return keys, nil
+}
+
+// DecodeKey reads a key in standard or URL base64, with or without padding
+func DecodeKey(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("not valid base64")
+	}
+	return b, nil
+}
+
+// Enabled reports whether k encrypts anything
+func (k *Keyring) Enabled() bool {
+	return k != nil && len(k.keys) > 0
+}
+
+// Encrypts reports whether column, as table.column, is encrypted
+func (k *Keyring) Encrypts(column string) bool {
+	return k.Enabled() && k.columns[column]
+}
+
+// Seal returns value encrypted if column is encrypted, and value itself otherwise.
+// Empty values are left empty.
+func (k *Keyring) Seal(column, value string) (string, error) {
+	if value == "" || !k.Encrypts(column) {
+		return value, nil
+	}
+	return k.encrypt(value)
+}
+
+// Open returns the plain text of value, which is returned as it is if it was not encrypted
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if !k.Enabled() {
+		return "", ErrUnknownKey
+	}
+
+	id, wrapped, sealed, err := split(value)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", errors.New("pii: malformed encrypted value")
+	}
+	plain, err := open(dataKey, b, nil)
+	if err != nil {
+		return "", fmt.Errorf("pii: cannot decrypt value: %w", err)
+	}
+	return string(plain), nil
+}
+
+// Rotate returns value as it should be stored in column now: encrypted with the primary
+// key if column is encrypted, rewrapping its data key if it was encrypted with another,
+// and in plain text if it is not. It reports whether the value changed.
+func (k *Keyring) Rotate(column, value string) (string, bool, error) {
+	if !IsEncrypted(value) {
+		if value == "" || !k.Encrypts(column) {
+			return value, false, nil
+		}
+		sealed, err := k.encrypt(value)
+		return sealed, err == nil, err
+	}
+
+	if !k.Encrypts(column) {
+		plain, err := k.Open(value)
+		return plain, err == nil, err
+	}
+
+	id, wrapped, sealed, err := split(value)
+	if err != nil {
+		return "", false, err
+	}
+	if id == k.primary {
+		return value, false, nil
+	}
+
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+	rewrapped, err := k.wrap(dataKey)
+	if err != nil {
+		return "", false, err
+	}
+
+	return prefix + k.primary + ":" + rewrapped + ":" + sealed, true, nil
+}
+
+// Hash returns a keyed hash of value, ignoring case and surrounding spaces, to look up
+// encrypted values by; it is "" when k has no hash key
+func (k *Keyring) Hash(value string) string {
+	if k == nil || len(k.hashKey) == 0 {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, k.hashKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted reports whether value was encrypted by a Keyring
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// encrypt seals value with a new data key, wrapped by the primary key
+func (k *Keyring) encrypt(value string) (string, error) {
+	dataKey := make([]byte, keySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(dataKey, []byte(value), nil)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := k.wrap(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.primary + ":" + wrapped + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// wrap encrypts a data key with the primary key, bound to its id
+func (k *Keyring) wrap(dataKey []byte) (string, error) {
+	wrapped, err := seal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(wrapped), nil
+}
+
+// unwrap decrypts a data key wrapped by the key called id
+func (k *Keyring) unwrap(id, wrapped string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, errors.New("pii: malformed encrypted value")
+	}
+	dataKey, err := open(key, b, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("pii: cannot unwrap the data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// split returns the key id, wrapped data key and sealed value of an encrypted value
+func split(value string) (id, wrapped, sealed string, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", "", "", errors.New("pii: malformed encrypted value")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// seal encrypts plain with AES-GCM under key, returning the nonce followed by the ciphertext
+func seal(key, plain, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, data), nil
+}
+
+// open decrypts what seal returned
+func open(key, sealed, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], data)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,192 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var hashKey = bytes.Repeat([]byte{'h'}, keySize)
+
+func key(id string, b byte) Key {
+	return Key{ID: id, Secret: bytes.Repeat([]byte{b}, keySize)}
+}
+
+func keyring(t *testing.T, primary string, keys ...Key) *Keyring {
+	t.Helper()
+
+	k, err := New(keys, primary, hashKey, Columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyring_SealAndOpen(t *testing.T) {
+	k := keyring(t, "", key("one", 1))
+
+	sealed, err := k.Seal("reservations.email", "guest@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "guest") {
+		t.Fatalf("value not encrypted: %q", sealed)
+	}
+
+	again, _ := k.Seal("reservations.email", "guest@example.com")
+	if again == sealed {
+		t.Error("the same value was encrypted the same way twice")
+	}
+
+	plain, err := k.Open(sealed)
+	if err != nil || plain != "guest@example.com" {
+		t.Errorf("got %q, %v", plain, err)
+	}
+
+	if plain, _ := k.Open("legacy@example.com"); plain != "legacy@example.com" {
+		t.Errorf("plain text value changed by Open: %q", plain)
+	}
+	if sealed, _ := k.Seal("reservations.email", ""); sealed != "" {
+		t.Errorf("empty value encrypted as %q", sealed)
+	}
+}
+
+func TestKeyring_Disabled(t *testing.T) {
+	var k *Keyring
+
+	sealed, err := k.Seal("reservations.email", "guest@example.com")
+	if err != nil || sealed != "guest@example.com" {
+		t.Errorf("nil keyring got %q, %v", sealed, err)
+	}
+	if k.Hash("guest@example.com") != "" {
+		t.Error("nil keyring hashed a value")
+	}
+
+	only, _ := New([]Key{key("one", 1)}, "", hashKey, []string{"reservations.phone"})
+	if sealed, _ := only.Seal("reservations.email", "guest@example.com"); sealed != "guest@example.com" {
+		t.Errorf("column that is not configured was encrypted: %q", sealed)
+	}
+
+	encrypted, _ := keyring(t, "", key("one", 1)).Seal("reservations.email", "guest@example.com")
+	if _, err := k.Open(encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey without keys but got %v", err)
+	}
+}
+
+func TestKeyring_Tampered(t *testing.T) {
+	k := keyring(t, "", key("one", 1))
+	sealed, _ := k.Seal("reservations.phone", "+15555555555")
+
+	parts := strings.Split(sealed, ":")
+	b, _ := base64.RawURLEncoding.DecodeString(parts[4])
+	b[len(b)-1] ^= 1
+	parts[4] = base64.RawURLEncoding.EncodeToString(b)
+
+	if _, err := k.Open(strings.Join(parts, ":")); err == nil {
+		t.Error("tampered value was decrypted")
+	}
+	if _, err := k.Open("enc:v1:one:nonsense"); err == nil {
+		t.Error("malformed value was decrypted")
+	}
+
+	other := keyring(t, "", key("two", 2))
+	if _, err := other.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey but got %v", err)
+	}
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	old := keyring(t, "", key("one", 1))
+	sealed, _ := old.Seal("reservations.email", "guest@example.com")
+
+	both := keyring(t, "two", key("one", 1), key("two", 2))
+
+	rotated, changed, err := both.Rotate("reservations.email", sealed)
+	if err != nil || !changed {
+		t.Fatalf("got %v, %v", changed, err)
+	}
+	if !strings.HasPrefix(rotated, prefix+"two:") {
+		t.Errorf("not rewrapped with the primary key: %q", rotated)
+	}
+	if _, changed, _ := both.Rotate("reservations.email", rotated); changed {
+		t.Error("value already under the primary key was rotated again")
+	}
+
+	newOnly := keyring(t, "", key("two", 2))
+	if plain, err := newOnly.Open(rotated); err != nil || plain != "guest@example.com" {
+		t.Errorf("rotated value cannot be read without the old key: %q, %v", plain, err)
+	}
+
+	encrypted, changed, _ := both.Rotate("reservations.email", "legacy@example.com")
+	if !changed || !IsEncrypted(encrypted) {
+		t.Errorf("plain text value not encrypted: %q", encrypted)
+	}
+
+	phoneOnly, _ := New([]Key{key("two", 2)}, "", hashKey, []string{"reservations.phone"})
+	plain, changed, err := phoneOnly.Rotate("reservations.email", rotated)
+	if err != nil || !changed || plain != "guest@example.com" {
+		t.Errorf("value of a column no longer encrypted not decrypted: %q, %v, %v", plain, changed, err)
+	}
+}
+
+func TestKeyring_Hash(t *testing.T) {
+	k := keyring(t, "", key("one", 1))
+
+	h := k.Hash("guest@example.com")
+	if h == "" || h != k.Hash(" Guest@Example.com") {
+		t.Errorf("hash is not the same for the same address: %q", h)
+	}
+	if h == k.Hash("other@example.com") {
+		t.Error("different addresses got the same hash")
+	}
+
+	other, _ := New(nil, "", bytes.Repeat([]byte{'x'}, keySize), nil)
+	if other.Hash("guest@example.com") == h {
+		t.Error("hash does not depend on the key")
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []Key
+		primary string
+		hash    []byte
+		columns []string
+	}{
+		{"short key", []Key{{ID: "one", Secret: []byte("short")}}, "", hashKey, nil},
+		{"bad id", []Key{key("o:ne", 1)}, "", hashKey, nil},
+		{"listed twice", []Key{key("one", 1), key("one", 2)}, "", hashKey, nil},
+		{"unknown primary", []Key{key("one", 1)}, "two", hashKey, nil},
+		{"no hash key", []Key{key("one", 1)}, "", nil, nil},
+		{"unknown column", []Key{key("one", 1)}, "", hashKey, []string{"users.password"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := New(tt.keys, tt.primary, tt.hash, tt.columns); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := bytes.Repeat([]byte{7}, keySize)
+	std := base64.StdEncoding.EncodeToString(secret)
+	url := base64.RawURLEncoding.EncodeToString(secret)
+
+	keys, err := ParseKeys("# rotated 2026-10\none=" + std + "\n\n two = " + url + " ,three=" + url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || keys[1].ID != "two" || !bytes.Equal(keys[2].Secret, secret) {
+		t.Errorf("unexpected keys %+v", keys)
+	}
+
+	for _, bad := range []string{"one", "one=***"} {
+		if _, err := ParseKeys(bad); err == nil {
+			t.Errorf("ParseKeys(%q) did not fail", bad)
+		}
+	}
+}
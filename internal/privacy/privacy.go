@@ -0,0 +1,254 @@
+// Package privacy gathers everything stored about a guest, for them to take away, and
+// checks the links that prove a guest owns the email address they ask about.
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/audit"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/pii"
+	"github.com/prashant9154/Booking_System/internal/repository"
+)
+
+// The actions of the audit log entries of privacy operations
+const (
+	ActionRequest = "privacy.request"
+	ActionExport  = "privacy.export"
+	ActionErase   = "privacy.erase"
+)
+
+// Bundle is everything stored about the guest with an email address
+type Bundle struct {
+	Email        string        `json:"email"`
+	GeneratedAt  time.Time     `json:"generated_at"`
+	Reservations []Reservation `json:"reservations"`
+	Messages     []Message     `json:"messages"`
+	Events       []Event       `json:"events"`
+	Audit        []AuditEntry  `json:"audit"`
+}
+
+// Reservation is a reservation of the guest
+type Reservation struct {
+	ID        int       `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Room      string    `json:"room"`
+	StartDate string    `json:"start_date"`
+	EndDate   string    `json:"end_date"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Message is a message the guest sent with the contact form
+type Message struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Email  string    `json:"email"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// The sources of the events of a Bundle
+const (
+	SourceOutbox  = "outbox"
+	SourceWebhook = "webhook"
+)
+
+// Event is a copy of a reservation of the guest kept to tell other systems about it: an
+// outbox event, or its delivery to a webhook, with the address it is sent to
+type Event struct {
+	Source    string          `json:"source"`
+	Event     string          `json:"event"`
+	Recipient string          `json:"recipient,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	At        time.Time       `json:"at"`
+}
+
+// AuditEntry is an operation made on the guest's data. The address it was made from is
+// only included for the guest's own operations.
+type AuditEntry struct {
+	Action   string    `json:"action"`
+	Actor    string    `json:"actor"`
+	ClientIP string    `json:"client_ip,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Collect gathers the reservations, messages, events and audit log entries of email
+func Collect(ctx context.Context, db repository.DatabaseRepo, keys *pii.Keyring, email string) (Bundle, error) {
+	b := Bundle{
+		Email:        email,
+		GeneratedAt:  time.Now().UTC(),
+		Reservations: []Reservation{},
+		Messages:     []Message{},
+		Events:       []Event{},
+		Audit:        []AuditEntry{},
+	}
+
+	reservations, err := db.ReservationsByEmail(ctx, email)
+	if err != nil {
+		return Bundle{}, err
+	}
+	for _, res := range reservations {
+		b.Reservations = append(b.Reservations, Reservation{
+			ID:        res.ID,
+			FirstName: res.FirstName,
+			LastName:  res.LastName,
+			Email:     res.Email,
+			Phone:     res.Phone,
+			Room:      res.Room.RoomName,
+			StartDate: res.StartDate.Format("2006-01-02"),
+			EndDate:   res.EndDate.Format("2006-01-02"),
+			CreatedAt: res.CreatedAt,
+		})
+	}
+
+	messages, err := db.MessagesByEmail(ctx, email)
+	if err != nil {
+		return Bundle{}, err
+	}
+	for _, msg := range messages {
+		b.Messages = append(b.Messages, Message{
+			ID:     msg.ID,
+			Name:   msg.Name,
+			Email:  msg.Email,
+			Body:   msg.Body,
+			SentAt: msg.CreatedAt,
+		})
+	}
+
+	stored, err := db.OutboxEventsByEmail(ctx, email)
+	if err != nil {
+		return Bundle{}, err
+	}
+	for _, e := range stored {
+		b.Events = append(b.Events, Event{Source: SourceOutbox, Event: e.Type, Payload: rawJSON(e.Payload), At: e.OccurredAt})
+	}
+
+	deliveries, err := db.WebhookDeliveriesByEmail(ctx, email)
+	if err != nil {
+		return Bundle{}, err
+	}
+	for _, d := range deliveries {
+		b.Events = append(b.Events, Event{
+			Source:    SourceWebhook,
+			Event:     d.Event,
+			Recipient: d.Subscription.URL,
+			Payload:   rawJSON(d.Payload),
+			At:        d.CreatedAt,
+		})
+	}
+
+	entries, err := db.AuditEntriesBySubject(ctx, audit.Subject(keys, email))
+	if err != nil {
+		return Bundle{}, err
+	}
+	for _, e := range entries {
+		b.Audit = append(b.Audit, newAuditEntry(e))
+	}
+
+	return b, nil
+}
+
+// rawJSON returns payload as JSON to embed, or as a JSON string if it is not JSON itself
+func rawJSON(payload string) json.RawMessage {
+	if json.Valid([]byte(payload)) {
+		return json.RawMessage(payload)
+	}
+	b, _ := json.Marshal(payload)
+	return b
+}
+
+func newAuditEntry(e models.AuditEntry) AuditEntry {
+	entry := AuditEntry{Action: e.Action, Actor: e.Actor, At: e.CreatedAt}
+	if e.Actor == audit.Guest {
+		entry.ClientIP = e.ClientIP
+	}
+	return entry
+}
+
+// WriteJSON writes the bundle as an indented JSON document
+func (b Bundle) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(b)
+}
+
+// csvCell returns s as a CSV cell that spreadsheets show as text: a value a guest typed
+// starting with =, +, - or @ would otherwise be run as a formula when the file is opened
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// WriteZIP writes the bundle as a ZIP archive of the JSON document and a CSV file of each
+// of its parts, for spreadsheets
+func (b Bundle) WriteZIP(w io.Writer) error {
+	z := zip.NewWriter(w)
+
+	f, err := z.Create("export.json")
+	if err != nil {
+		return err
+	}
+	err = b.WriteJSON(f)
+	if err != nil {
+		return err
+	}
+
+	reservations := [][]string{{"id", "first_name", "last_name", "email", "phone", "room", "start_date", "end_date", "created_at"}}
+	for _, r := range b.Reservations {
+		reservations = append(reservations, []string{strconv.Itoa(r.ID), r.FirstName, r.LastName, r.Email, r.Phone,
+			r.Room, r.StartDate, r.EndDate, r.CreatedAt.Format(time.RFC3339)})
+	}
+
+	messages := [][]string{{"id", "name", "email", "body", "sent_at"}}
+	for _, m := range b.Messages {
+		messages = append(messages, []string{strconv.Itoa(m.ID), m.Name, m.Email, m.Body, m.SentAt.Format(time.RFC3339)})
+	}
+
+	events := [][]string{{"source", "event", "recipient", "payload", "at"}}
+	for _, e := range b.Events {
+		events = append(events, []string{e.Source, e.Event, e.Recipient, string(e.Payload), e.At.Format(time.RFC3339)})
+	}
+
+	entries := [][]string{{"action", "actor", "client_ip", "at"}}
+	for _, e := range b.Audit {
+		entries = append(entries, []string{e.Action, e.Actor, e.ClientIP, e.At.Format(time.RFC3339)})
+	}
+
+	for _, file := range []struct {
+		name string
+		rows [][]string
+	}{
+		{"reservations.csv", reservations},
+		{"messages.csv", messages},
+		{"events.csv", events},
+		{"audit.csv", entries},
+	} {
+		f, err := z.Create(file.name)
+		if err != nil {
+			return err
+		}
+		for _, row := range file.rows {
+			for i := range row {
+				row[i] = csvCell(row[i])
+			}
+		}
+		err = csv.NewWriter(f).WriteAll(file.rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
@@ -0,0 +1,128 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/audit"
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/repository/dbrepo"
+)
+
+func TestVerifier(t *testing.T) {
+	v := NewVerifier("secret", time.Hour)
+	now := time.Now()
+	v.now = func() time.Time { return now }
+
+	token := v.Token("guest@example.com")
+	email, err := v.Verify(token)
+	if err != nil || email != "guest@example.com" {
+		t.Fatalf("got %q, %v", email, err)
+	}
+
+	if _, err := NewVerifier("other", time.Hour).Verify(token); err != ErrBadLink {
+		t.Errorf("token signed with another secret: got %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := strings.Join([]string{"b3RoZXJAZXhhbXBsZS5jb20", parts[1], parts[2]}, ".")
+	if _, err := v.Verify(forged); err != ErrBadLink {
+		t.Errorf("token for another address: got %v", err)
+	}
+
+	v.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, err := v.Verify(token); err != ErrBadLink {
+		t.Errorf("expired token: got %v", err)
+	}
+
+	if _, err := v.Verify("nonsense"); err != ErrBadLink {
+		t.Errorf("malformed token: got %v", err)
+	}
+}
+
+func TestCollect_Empty(t *testing.T) {
+	db := dbrepo.NewTestingRepo(&config.AppConfig{})
+
+	b, err := Collect(context.Background(), db, nil, "guest@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = b.WriteJSON(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"reservations": []`, `"messages": []`, `"events": []`, `"audit": []`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %s in %s", want, out.String())
+		}
+	}
+}
+
+func TestBundle_WriteZIP(t *testing.T) {
+	b := Bundle{
+		Email:        "guest@example.com",
+		Reservations: []Reservation{{ID: 1, FirstName: "Ada", Email: "guest@example.com", StartDate: "2026-07-14", EndDate: "2026-07-16"}},
+		Messages: []Message{
+			{ID: 2, Name: "Ada", Body: "Hello, \"world\""},
+			{ID: 3, Name: "@Ada", Body: "=HYPERLINK(\"https://evil.example.com\")"},
+		},
+		Events: []Event{
+			{Source: SourceWebhook, Event: "reservation.created", Recipient: "https://hooks.example.com/in", Payload: rawJSON(`{"id": 1}`)},
+			{Source: SourceOutbox, Event: "reservation.created", Payload: rawJSON("not json")},
+		},
+		Audit: []AuditEntry{
+			newAuditEntry(models.AuditEntry{Action: ActionExport, Actor: audit.Guest, ClientIP: "192.0.2.1"}),
+			newAuditEntry(models.AuditEntry{Action: ActionExport, Actor: audit.User(1), ClientIP: "198.51.100.7"}),
+		},
+	}
+
+	var out bytes.Buffer
+	err := b.WriteZIP(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+	for _, f := range z.File {
+		r, _ := f.Open()
+		body, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(body)
+	}
+
+	var got Bundle
+	err = json.Unmarshal([]byte(files["export.json"]), &got)
+	if err != nil || got.Email != b.Email || len(got.Reservations) != 1 {
+		t.Errorf("unexpected export.json %q: %v", files["export.json"], err)
+	}
+	if !strings.Contains(files["reservations.csv"], "1,Ada,,guest@example.com,,,2026-07-14,2026-07-16,") {
+		t.Errorf("unexpected reservations.csv %q", files["reservations.csv"])
+	}
+	if !strings.Contains(files["messages.csv"], `"Hello, ""world"""`) {
+		t.Errorf("unexpected messages.csv %q", files["messages.csv"])
+	}
+	if !strings.Contains(files["messages.csv"], `3,'@Ada,,"'=HYPERLINK(""https://evil.example.com"")",`) {
+		t.Errorf("expected cells that start a formula to be escaped in messages.csv %q", files["messages.csv"])
+	}
+	if !strings.Contains(files["events.csv"], `webhook,reservation.created,https://hooks.example.com/in,"{""id"": 1}",`) ||
+		!strings.Contains(files["events.csv"], `outbox,reservation.created,,"""not json""",`) {
+		t.Errorf("unexpected events.csv %q", files["events.csv"])
+	}
+	if !strings.Contains(files["audit.csv"], "192.0.2.1") || strings.Contains(files["audit.csv"], "198.51.100.7") {
+		t.Errorf("expected only the guest's own addresses in audit.csv but got %q", files["audit.csv"])
+	}
+}
@@ -0,0 +1,78 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrBadLink is returned for a link that was not made by the Verifier or has expired
+var ErrBadLink = errors.New("privacy: invalid or expired link")
+
+// Verifier makes and checks the signed links guests are sent to prove they own an email
+// address, so only they can see or erase what is stored about it
+type Verifier struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewVerifier returns a Verifier signing with secret whose links expire after ttl. Without
+// a secret a random one is used, so links sent before a restart, or by another instance,
+// are rejected.
+func NewVerifier(secret string, ttl time.Duration) *Verifier {
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+
+	return &Verifier{
+		secret: key,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Token returns the token of a link proving the guest owns email
+func (v *Verifier) Token(email string) string {
+	address := base64.RawURLEncoding.EncodeToString([]byte(email))
+	expires := strconv.FormatInt(v.now().Add(v.ttl).Unix(), 10)
+
+	return address + "." + expires + "." + v.sign(address, expires)
+}
+
+// Verify returns the email address token proves the guest owns
+func (v *Verifier) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || !hmac.Equal([]byte(parts[2]), []byte(v.sign(parts[0], parts[1]))) {
+		return "", ErrBadLink
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || v.now().After(time.Unix(expires, 0)) {
+		return "", ErrBadLink
+	}
+
+	email, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrBadLink
+	}
+	return string(email), nil
+}
+
+// sign returns the HMAC of the parts of a token
+func (v *Verifier) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte("privacy"))
+	for _, p := range parts {
+		mac.Write([]byte{0})
+		mac.Write([]byte(p))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
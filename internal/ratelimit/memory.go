@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/config"
+)
+
+// sweepInterval is how often buckets that have filled up again are forgotten
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	bucket
+	full time.Time
+}
+
+// memoryStore keeps buckets in memory, so each instance counts its own requests
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	swept   time.Time
+
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+}
+
+// NewMemoryStore returns a store that keeps buckets in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: map[string]*memoryBucket{},
+		now:     time.Now,
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit config.RateLimit) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.swept) > sweepInterval {
+		s.sweep(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: bucket{tokens: float64(limit.Requests), updated: now}}
+		s.buckets[key] = b
+	}
+
+	wait := b.take(limit, now)
+	// a bucket refills completely within a period, and is then the same as a new one
+	b.full = now.Add(limit.Per)
+	return wait, nil
+}
+
+// sweep forgets the buckets that are full again
+func (s *memoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	s.swept = now
+}
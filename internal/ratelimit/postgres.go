@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/config"
+)
+
+// postgresStore keeps buckets in the rate_limits table, so every instance of the
+// application shares the same counts
+type postgresStore struct {
+	DB *sql.DB
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+// NewPostgresStore returns a store backed by the rate_limits table
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{DB: db}
+}
+
+func (s *postgresStore) Take(ctx context.Context, key string, limit config.RateLimit) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	now := time.Now()
+	s.sweep(ctx, now)
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// a new bucket starts full; the row is locked so concurrent requests take turns
+	_, err = tx.ExecContext(ctx, `insert into rate_limits (key, tokens, updated_at, expires_at)
+			values ($1, $2, $3, $4) on conflict (key) do nothing`,
+		key, float64(limit.Requests), now, now.Add(limit.Per))
+	if err != nil {
+		return 0, err
+	}
+
+	var b bucket
+	err = tx.QueryRowContext(ctx, "select tokens, updated_at from rate_limits where key = $1 for update", key).Scan(&b.tokens, &b.updated)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := b.take(limit, now)
+
+	_, err = tx.ExecContext(ctx, "update rate_limits set tokens = $2, updated_at = $3, expires_at = $4 where key = $1",
+		key, b.tokens, b.updated, now.Add(limit.Per))
+	if err != nil {
+		return 0, err
+	}
+
+	return wait, tx.Commit()
+}
+
+// sweep deletes the buckets that are full again, at most once every sweepInterval
+func (s *postgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	due := now.Sub(s.swept) > sweepInterval
+	if due {
+		s.swept = now
+	}
+	s.mu.Unlock()
+
+	if due {
+		// a failed sweep is tried again next time; the buckets it leaves are only kept longer
+		s.DB.ExecContext(ctx, "delete from rate_limits where expires_at <= $1", now)
+	}
+}
@@ -0,0 +1,81 @@
+// Package ratelimit limits how often a client can make requests, with token buckets
+// kept in memory or, to share them between instances, in Postgres.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"net/http"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/clientip"
+	"github.com/prashant9154/Booking_System/internal/config"
+)
+
+// Store keeps a token bucket for every key
+type Store interface {
+	// Take takes a token from the bucket called key, which holds up to limit.Requests
+	// tokens and is refilled with as many every limit.Per. It returns 0 if a token was
+	// taken, and otherwise how long until one can be.
+	Take(ctx context.Context, key string, limit config.RateLimit) (time.Duration, error)
+}
+
+// NewStore returns the store selected by cfg.Store; db is only used by the postgres store
+func NewStore(cfg config.RateLimitConfig, db *sql.DB) (Store, error) {
+	switch cfg.Store {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		return NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+}
+
+// bucket is the state of a token bucket: the tokens it held when it was last updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b for the time since it was last updated and takes a token from it,
+// returning how long until one is available if there is none
+func (b *bucket) take(limit config.RateLimit, now time.Time) time.Duration {
+	rate := float64(limit.Requests) / limit.Per.Seconds()
+
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Requests), b.tokens+elapsed*rate)
+	}
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// Key returns who r is rate limited as: its session, when keying by session, or the
+// client's IP address, as resolved by clientip. session must be the token of a session
+// the server loaded from its store, never a cookie value taken from the request, which
+// a client could change on every request to get a fresh bucket; without one the IP
+// address is used. Sessions are hashed, so they are not kept in the store.
+func Key(r *http.Request, by, session string) string {
+	if by == "session" && session != "" {
+		return "session:" + hash(session)
+	}
+
+	return "ip:" + clientip.FromRequest(r)
+}
+
+// hash returns a short hash of a secret, enough to tell clients apart
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/clientip"
+	"github.com/prashant9154/Booking_System/internal/config"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.(*memoryStore).now = func() time.Time { return now }
+
+	ctx := context.Background()
+	limit := config.RateLimit{Requests: 3, Per: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		if wait, _ := store.Take(ctx, "a", limit); wait != 0 {
+			t.Fatalf("request %d of the burst refused", i+1)
+		}
+	}
+
+	wait, _ := store.Take(ctx, "a", limit)
+	if wait != 20*time.Second {
+		t.Errorf("expected to wait 20s for the next token but got %s", wait)
+	}
+
+	if wait, _ := store.Take(ctx, "b", limit); wait != 0 {
+		t.Error("another key shares the bucket")
+	}
+
+	now = now.Add(20 * time.Second)
+	if wait, _ := store.Take(ctx, "a", limit); wait != 0 {
+		t.Error("bucket not refilled")
+	}
+	if wait, _ := store.Take(ctx, "a", limit); wait == 0 {
+		t.Error("bucket refilled by more than the elapsed time")
+	}
+
+	now = now.Add(time.Hour)
+	store.Take(ctx, "c", limit)
+	if n := len(store.(*memoryStore).buckets); n != 1 {
+		t.Errorf("expected full buckets to be forgotten but %d are kept", n)
+	}
+}
+
+func TestKey(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+
+	if got := Key(r, "ip", ""); got != "ip:192.0.2.1" {
+		t.Errorf("got %q", got)
+	}
+	if got := Key(r.WithContext(clientip.WithIP(r.Context(), "198.51.100.7")), "ip", ""); got != "ip:198.51.100.7" {
+		t.Errorf("expected the resolved client address but got %q", got)
+	}
+
+	if got := Key(r, "session", "abc"); got != "session:"+hash("abc") {
+		t.Errorf("got %q", got)
+	}
+	if got := Key(r, "ip", "abc"); got != "ip:192.0.2.1" {
+		t.Errorf("session used when keying by ip: got %q", got)
+	}
+
+	// a cookie the server has no session for does not count
+	r.AddCookie(&http.Cookie{Name: "session", Value: "made-up"})
+	if got := Key(r, "session", ""); got != "ip:192.0.2.1" {
+		t.Errorf("request without a stored session: got %q", got)
+	}
+}
@@ -0,0 +1,182 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/i18n"
+)
+
+// shortDateLayout is the layout of dates in forms and URLs
+const shortDateLayout = "2006-01-02"
+
+// routes maps the route names templates link to with url to their patterns
+var routes = map[string]string{}
+
+var functions = template.FuncMap{
+	"shortDate":  shortDate,
+	"formatDate": formatDate,
+	"nights":     nights,
+	"pluralize":  pluralize,
+	"url":        routeURL,
+	"iterate":    iterate,
+
+	// replaced by localize with the request's locale when a page is rendered
+	"t":        func(key string, args ...interface{}) string { return i18n.Default().T(key, args...) },
+	"date":     func(t time.Time) string { return i18n.Default().Date(t) },
+	"currency": func(amount interface{}) (string, error) { return currency(i18n.Default(), amount) },
+}
+
+// localized holds the copies made by localize, by template and locale tag, so each page is
+// cloned once per locale rather than on every render
+var localized = struct {
+	sync.RWMutex
+	m map[*template.Template]map[string]*template.Template
+}{m: map[*template.Template]map[string]*template.Template{}}
+
+// localize returns a copy of t whose t, date and currency functions use locale l
+func localize(t *template.Template, l *i18n.Locale) (*template.Template, error) {
+	localized.RLock()
+	c, ok := localized.m[t][l.Tag]
+	localized.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	localized.Lock()
+	defer localized.Unlock()
+
+	if c, ok := localized.m[t][l.Tag]; ok {
+		return c, nil
+	}
+
+	c, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	c.Funcs(template.FuncMap{
+		"t":        l.T,
+		"date":     l.Date,
+		"currency": func(amount interface{}) (string, error) { return currency(l, amount) },
+	})
+
+	if localized.m[t] == nil {
+		localized.m[t] = map[string]*template.Template{}
+	}
+	localized.m[t][l.Tag] = c
+	return c, nil
+}
+
+// forgetLocalized drops the localized copies once the templates they were made from are replaced
+func forgetLocalized() {
+	localized.Lock()
+	defer localized.Unlock()
+	localized.m = map[*template.Template]map[string]*template.Template{}
+}
+
+// Functions returns the functions available to templates. Template caches built outside
+// this package, such as in tests, must add them too or pages using them fail to parse.
+func Functions() template.FuncMap {
+	return functions
+}
+
+// SetRoutes sets the route names, and their chi patterns, that templates build links to with url
+func SetRoutes(names map[string]string) {
+	routes = names
+}
+
+// shortDate formats t as 2006-01-02, or returns "" for the zero time
+func shortDate(t time.Time) string {
+	return formatDate(t, shortDateLayout)
+}
+
+// formatDate formats t with layout, or returns "" for the zero time
+func formatDate(t time.Time, layout string) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// nights returns the number of nights between arriving on start and leaving on end,
+// counting calendar days so the time of day does not matter
+func nights(start, end time.Time) int {
+	s := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	e := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+
+	n := int(e.Sub(s).Hours() / 24)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// currency formats an amount the way locale l writes it, e.g. $1,234.50 in English
+func currency(l *i18n.Locale, amount interface{}) (string, error) {
+	switch v := amount.(type) {
+	case int:
+		return l.Money(float64(v)), nil
+	case int64:
+		return l.Money(float64(v)), nil
+	case float32:
+		return l.Money(float64(v)), nil
+	case float64:
+		return l.Money(v), nil
+	default:
+		return "", fmt.Errorf("currency: cannot format %T", amount)
+	}
+}
+
+// pluralize returns n followed by singular when n is 1 and by plural otherwise, e.g. "3 nights"
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// routeURL builds the path of the named route, filling its {placeholders} in order with
+// the escaped params, so templates do not hard-code paths
+func routeURL(name string, params ...interface{}) (template.URL, error) {
+	pattern, ok := routes[name]
+	if !ok {
+		return "", fmt.Errorf("url: no route named %q", name)
+	}
+
+	segments := strings.Split(pattern, "/")
+	used := 0
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		if used == len(params) {
+			return "", fmt.Errorf("url: route %q needs more than %d params", name, len(params))
+		}
+		segments[i] = url.PathEscape(fmt.Sprint(params[used]))
+		used++
+	}
+
+	if used != len(params) {
+		return "", fmt.Errorf("url: route %q takes %d params but got %d", name, used, len(params))
+	}
+
+	return template.URL(strings.Join(segments, "/")), nil
+}
+
+// iterate returns the integers from 0 to count-1, for ranging over a fixed number of
+// cells such as the days of a calendar grid
+func iterate(count int) []int {
+	if count < 0 {
+		count = 0
+	}
+
+	items := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		items = append(items, i)
+	}
+	return items
+}
@@ -0,0 +1,144 @@
+package render
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/i18n"
+)
+
+func TestNights(t *testing.T) {
+	start := time.Date(2026, 3, 28, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		end  time.Time
+		want int
+	}{
+		{time.Date(2026, 3, 28, 11, 0, 0, 0, time.UTC), 0},
+		{time.Date(2026, 3, 29, 11, 0, 0, 0, time.UTC), 1},
+		{time.Date(2026, 4, 2, 11, 0, 0, 0, time.UTC), 5},
+		{time.Date(2026, 3, 20, 11, 0, 0, 0, time.UTC), 0},
+	}
+
+	for _, tt := range tests {
+		if got := nights(start, tt.end); got != tt.want {
+			t.Errorf("nights until %s: got %d, wanted %d", tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	tests := []struct {
+		amount interface{}
+		want   string
+	}{
+		{0, "$0.00"},
+		{89, "$89.00"},
+		{1234.5, "$1,234.50"},
+		{float32(0.1), "$0.10"},
+		{int64(1000000), "$1,000,000.00"},
+		{-42.125, "-$42.13"},
+	}
+
+	for _, tt := range tests {
+		got, err := currency(i18n.Default(), tt.amount)
+		if err != nil {
+			t.Errorf("currency(%v): %v", tt.amount, err)
+		}
+		if got != tt.want {
+			t.Errorf("currency(%v): got %q, wanted %q", tt.amount, got, tt.want)
+		}
+	}
+
+	if _, err := currency(i18n.Default(), "12"); err == nil {
+		t.Error("formatted a string as currency")
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	if got := pluralize(1, "night", "nights"); got != "1 night" {
+		t.Errorf("got %q", got)
+	}
+	if got := pluralize(0, "night", "nights"); got != "0 nights" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRouteURL(t *testing.T) {
+	defer SetRoutes(routes)
+	SetRoutes(map[string]string{
+		"home":        "/",
+		"choose-room": "/choose-room/{id}",
+	})
+
+	got, err := routeURL("choose-room", 2)
+	if err != nil || got != "/choose-room/2" {
+		t.Errorf("got %q, %v", got, err)
+	}
+
+	got, err = routeURL("choose-room", "a/b")
+	if err != nil || got != "/choose-room/a%2Fb" {
+		t.Errorf("params not escaped: got %q, %v", got, err)
+	}
+
+	if _, err = routeURL("choose-room"); err == nil {
+		t.Error("built a url with a missing param")
+	}
+	if _, err = routeURL("home", 1); err == nil {
+		t.Error("built a url with an extra param")
+	}
+	if _, err = routeURL("missing"); err == nil {
+		t.Error("built a url for a route that does not exist")
+	}
+}
+
+func TestIterate(t *testing.T) {
+	if got := iterate(3); len(got) != 3 || got[0] != 0 || got[2] != 2 {
+		t.Errorf("got %v", got)
+	}
+	if got := iterate(-1); len(got) != 0 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	base := template.Must(template.New("page").Funcs(functions).Parse(`{{ t "nav.home" }}`))
+
+	en, _ := i18n.Get("en")
+	de, _ := i18n.Get("de")
+
+	first, err := localize(base, de)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := localize(base, de)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("expected the localized copy to be reused instead of cloned on every render")
+	}
+
+	english, err := localize(base, en)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if english == first {
+		t.Error("expected a copy of its own for every locale")
+	}
+
+	var b strings.Builder
+	if err := first.Execute(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != de.T("nav.home") {
+		t.Errorf("expected %q but got %q", de.T("nav.home"), b.String())
+	}
+
+	forgetLocalized()
+	if again, _ := localize(base, de); again == first {
+		t.Error("expected the copies to be dropped once the templates are parsed again")
+	}
+}
@@ -0,0 +1,44 @@
+package render
+
+import (
+	"html/template"
+	"net/http"
+
+	"github.com/prashant9154/Booking_System/internal/security"
+)
+
+// overlay is shown in place of a page during development when its templates cannot be
+// parsed or executed, so the error is visible without reading the logs
+var overlay = template.Must(template.New("overlay").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Template error</title>
+<style nonce="{{.Nonce}}">
+  body { margin: 0; background: rgba(0, 0, 0, 0.85); color: #e8e8e8; font-family: Menlo, Consolas, monospace; }
+  .overlay { max-width: 960px; margin: 4rem auto; padding: 2rem; background: #181818; border-top: 6px solid #e5534b; box-shadow: 0 0 40px #000; }
+  h1 { margin-top: 0; color: #e5534b; font-size: 1.2rem; }
+  pre { white-space: pre-wrap; word-break: break-word; line-height: 1.5; }
+  p { color: #8b8b8b; }
+</style>
+</head>
+<body>
+<div class="overlay">
+  <h1>Template error{{with .Template}} in {{.}}{{end}}</h1>
+  <pre>{{.Error}}</pre>
+  <p>Fix the template and save it; the page reloads the templates on the next request.</p>
+</div>
+</body>
+</html>
+`))
+
+// writeErrorOverlay responds to r with a 500 showing err over the page
+func writeErrorOverlay(w http.ResponseWriter, r *http.Request, tmpl string, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = overlay.Execute(w, struct {
+		Template string
+		Error    string
+		Nonce    string
+	}{tmpl, err.Error(), security.Nonce(r.Context())})
+}
@@ -3,31 +3,46 @@ package render
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"html/template"
-	"log"
+	"io/fs"
 	"net/http"
-	"path/filepath"
+	"path"
 
 	"github.com/justinas/nosurf"
 	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/i18n"
+	"github.com/prashant9154/Booking_System/internal/logging"
 	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/security"
+	"github.com/prashant9154/Booking_System/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var app *config.AppConfig
-var pathToTemplates = "./templates"
-var functions = template.FuncMap{}
+var watcher *Watcher
 
 // NewRenderer set the config for templates package
 func NewRenderer(a *config.AppConfig) {
 	app = a
 }
 
+// SetWatcher makes Templates render from the templates kept up to date by w when app.UseCache is off
+func SetWatcher(w *Watcher) {
+	watcher = w
+}
+
 func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateData {
 	td.CSRFToken = nosurf.Token(r)
+	td.RequestID = logging.RequestID(r.Context())
+	td.Locale = i18n.FromContext(r.Context()).Tag
+	td.CSPNonce = security.Nonce(r.Context())
 	td.Flash = app.Session.PopString(r.Context(), "flash")
 	td.Error = app.Session.PopString(r.Context(), "error")
 	td.Warning = app.Session.PopString(r.Context(), "warning")
+	if app.Session.Exists(r.Context(), "user_id") {
+		td.IsAuthenticated = 1
+	}
 	return td
 }
 
@@ -96,21 +111,33 @@ func AddDefaultData(td *models.TemplateData, r *http.Request) *models.TemplateDa
 // 	return nil
 // }
 
+// Cache returns the templates to render with. With app.UseCache, as in production, it is
+// the cache built at startup, which is never changed. Otherwise it is the one kept up to
+// date by the watcher in the background, or built on every call when there is none.
+func Cache() (map[string]*template.Template, error) {
+	if app.UseCache {
+		return app.TemplateCache, nil
+	}
+	if watcher != nil {
+		return watcher.Templates()
+	}
+	return CreateTemplateCache(app.TemplateFS)
+}
+
 // rendering template with the ADVANCED caching of templates ->
 
-func Templates(w http.ResponseWriter, r *http.Request, tmpl string, td *models.TemplateData) error {
-	// create a template cache
-	var tc map[string]*template.Template
-	if app.UseCache {
-		// get the template cache from the config
-		tc = app.TemplateCache
-	} else {
-		tc, _ = CreateTemplateCache()
+func Templates(w http.ResponseWriter, r *http.Request, tmpl string, td *models.TemplateData) (err error) {
+	_, span := tracing.Tracer().Start(r.Context(), "render "+tmpl, trace.WithAttributes(attribute.String("template", tmpl)))
+	defer func() { tracing.End(span, err) }()
+
+	tc, err := Cache()
+	if err != nil {
+		app.Logger.ErrorContext(r.Context(), "cannot load templates", "error", err)
+		if !app.InProduction {
+			writeErrorOverlay(w, r, "", err)
+		}
+		return err
 	}
-	// tc, err := CreateTemplateCache()
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
 
 	// get requested template from cache
 	t, ok := tc[tmpl]
@@ -119,50 +146,96 @@ func Templates(w http.ResponseWriter, r *http.Request, tmpl string, td *models.T
 		return errors.New("could not get template from template cache")
 	}
 
+	t, err = localize(t, i18n.FromContext(r.Context()))
+	if err != nil {
+		return err
+	}
+
 	buf := new(bytes.Buffer)
 
 	td = AddDefaultData(td, r)
 
-	err := t.Execute(buf, td)
+	err = t.Execute(buf, td)
 	if err != nil {
-		log.Println(err)
+		app.Logger.ErrorContext(r.Context(), "cannot execute template", "template", tmpl, "error", err)
+		if !app.InProduction {
+			writeErrorOverlay(w, r, tmpl, err)
+		}
 		return err
 	}
 
 	// render the template
 	_, err = buf.WriteTo(w)
 	if err != nil {
-		fmt.Println("error writing template to browser", err)
+		app.Logger.ErrorContext(r.Context(), "cannot write template to browser", "template", tmpl, "error", err)
 		return err
 	}
 
 	return nil
 }
 
-func CreateTemplateCache() (map[string]*template.Template, error) {
+// Error renders error.page.hbs with status. Unlike Templates it does not read the session,
+// so it also works for requests that failed before the session was loaded.
+func Error(w http.ResponseWriter, r *http.Request, status int, td *models.TemplateData) error {
+	tc, err := Cache()
+	if err != nil {
+		return err
+	}
+
+	t, ok := tc["error.page.hbs"]
+	if !ok {
+		return errors.New("could not get error template from template cache")
+	}
+
+	locale := i18n.FromContext(r.Context())
+	t, err = localize(t, locale)
+	if err != nil {
+		return err
+	}
+
+	td.CSRFToken = nosurf.Token(r)
+	td.RequestID = logging.RequestID(r.Context())
+	td.Locale = locale.Tag
+	td.CSPNonce = security.Nonce(r.Context())
+
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, td)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// CreateTemplateCache parses every *.page.hbs in fsys together with the *.layout.hbs files,
+// keyed by the page's file name
+func CreateTemplateCache(fsys fs.FS) (map[string]*template.Template, error) {
 	myCache := map[string]*template.Template{}
 
-	// get all of the files named *.page.tmpl from ./templates
-	pages, err := filepath.Glob(fmt.Sprintf("%s/*.page.hbs", pathToTemplates))
+	// get all of the files named *.page.hbs
+	pages, err := fs.Glob(fsys, "*.page.hbs")
 	if err != nil {
 		return myCache, err
 	}
 
-	// range through all files ending with *.page.tmpl
+	// range through all files ending with *.page.hbs
 	for _, page := range pages {
-		name := filepath.Base(page)
-		ts, err := template.New(name).Funcs(functions).ParseFiles(page)
+		name := path.Base(page)
+		ts, err := template.New(name).Funcs(functions).ParseFS(fsys, page)
 		if err != nil {
 			return myCache, err
 		}
 
-		matches, err := filepath.Glob(fmt.Sprintf("%s/*.layout.hbs", pathToTemplates))
+		matches, err := fs.Glob(fsys, "*.layout.hbs")
 		if err != nil {
 			return myCache, err
 		}
 
 		if len(matches) > 0 {
-			ts, err = ts.ParseGlob(fmt.Sprintf("%s/*.layout.hbs", pathToTemplates))
+			ts, err = ts.ParseFS(fsys, "*.layout.hbs")
 			if err != nil {
 				return myCache, err
 			}
@@ -171,5 +244,8 @@ func CreateTemplateCache() (map[string]*template.Template, error) {
 		myCache[name] = ts
 	}
 
+	// a new cache replaces the previous one, whose localized copies are no longer used
+	forgetLocalized()
+
 	return myCache, nil
 }
@@ -1,9 +1,15 @@
 package render
 
 import (
+	"io/fs"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
+	bookings "github.com/prashant9154/Booking_System"
+	"github.com/prashant9154/Booking_System/internal/i18n"
 	"github.com/prashant9154/Booking_System/internal/models"
 )
 
@@ -25,8 +31,7 @@ func TestAddDefaultData(t *testing.T) {
 }
 
 func TestRenderTemplate(t *testing.T) {
-	pathToTemplates = "./../../templates"
-	tc, err := CreateTemplateCache()
+	tc, err := CreateTemplateCache(os.DirFS(pathToTemplates))
 	if err != nil {
 		t.Error(err)
 	}
@@ -40,12 +45,12 @@ func TestRenderTemplate(t *testing.T) {
 
 	var ww myWriter
 
-	err = RenderTemplates(&ww, r, "home.page.hbs", &models.TemplateData{})
+	err = Templates(&ww, r, "home.page.hbs", &models.TemplateData{})
 	if err != nil {
 		t.Error("error writing template to browser", err)
 	}
 
-	err = RenderTemplates(&ww, r, "non-existent.page.hbs", &models.TemplateData{})
+	err = Templates(&ww, r, "non-existent.page.hbs", &models.TemplateData{})
 	if err == nil {
 		t.Error("rendered template that does not exist")
 	}
@@ -65,14 +70,67 @@ func getSession() (*http.Request, error) {
 }
 
 func TestNewTemplates(t *testing.T) {
-	NewTemplates(app)
+	NewRenderer(app)
 }
 
 func TestCreateTemplateCache(t *testing.T) {
-	pathToTemplates = "./../../templates"
+	tc, err := CreateTemplateCache(os.DirFS(pathToTemplates))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := tc["home.page.hbs"]; !ok {
+		t.Error("home page not found in template cache")
+	}
+}
+
+func TestCreateTemplateCache_Embedded(t *testing.T) {
+	templates, err := fs.Sub(bookings.Assets(false, ""), "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	_, err := CreateTemplateCache()
+	tc, err := CreateTemplateCache(templates)
 	if err != nil {
 		t.Error(err)
 	}
+
+	if _, ok := tc["home.page.hbs"]; !ok {
+		t.Error("home page not found in template cache built from the embedded templates")
+	}
+}
+
+func TestRenderTemplate_Localized(t *testing.T) {
+	tc, err := CreateTemplateCache(os.DirFS(pathToTemplates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.TemplateCache = tc
+	app.UseCache = true
+	defer func() { app.UseCache = false }()
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr, _ := i18n.Get("fr")
+	r = r.WithContext(i18n.WithLocale(r.Context(), fr))
+
+	rr := httptest.NewRecorder()
+	err = Templates(rr, r, "home.page.hbs", &models.TemplateData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(rr.Body.String(), `lang="fr"`) || !strings.Contains(rr.Body.String(), "Accueil") {
+		t.Error("page not rendered in the request's locale")
+	}
+
+	// the cached templates keep the default locale
+	rr = httptest.NewRecorder()
+	r, _ = getSession()
+	_ = Templates(rr, r, "home.page.hbs", &models.TemplateData{})
+	if strings.Contains(rr.Body.String(), "Accueil") {
+		t.Error("locale of one request leaked into the next")
+	}
 }
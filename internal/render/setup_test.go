@@ -2,7 +2,7 @@ package render
 
 import (
 	"encoding/gob"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"testing"
@@ -10,11 +10,13 @@ import (
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/logging"
 	"github.com/prashant9154/Booking_System/internal/models"
 )
 
 var session *scs.SessionManager
 var testApp config.AppConfig
+var pathToTemplates = "./../../templates"
 
 func TestMain(m *testing.M) {
 
@@ -23,11 +25,8 @@ func TestMain(m *testing.M) {
 	// change this to true when in production
 	testApp.InProduction = false
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	testApp.InfoLog = infoLog
-
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
-	testApp.ErrorLog = errorLog
+	testApp.Logger = logging.New(os.Stdout, "text", slog.LevelInfo)
+	testApp.TemplateFS = os.DirFS(pathToTemplates)
 
 	// set up the session
 	session = scs.New()
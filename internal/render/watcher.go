@@ -0,0 +1,125 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watcher keeps a template cache up to date during development, rebuilding it only
+// when a .hbs file is added, removed or changed. If the templates fail to parse, the
+// last good cache is kept and the error is shown in the browser until it is fixed.
+type Watcher struct {
+	FS       fs.FS
+	Interval time.Duration
+	Logger   *slog.Logger
+
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+	err       error
+	signature string
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWatcher creates a watcher for the templates in fsys and builds the cache straight away
+func NewWatcher(fsys fs.FS, interval time.Duration, logger *slog.Logger) *Watcher {
+	w := &Watcher{
+		FS:       fsys,
+		Interval: interval,
+		Logger:   logger,
+	}
+	w.Check()
+	return w
+}
+
+// Templates returns the current cache, and the error from the last rebuild if it failed
+func (w *Watcher) Templates() (map[string]*template.Template, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.templates, w.err
+}
+
+// Check rebuilds the cache if a template changed since the last check, reporting whether it did
+func (w *Watcher) Check() bool {
+	sig, err := w.fingerprint()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err == nil && sig == w.signature {
+		return false
+	}
+	w.signature = sig
+
+	tc, err := CreateTemplateCache(w.FS)
+	if err != nil {
+		w.err = err
+		w.Logger.Error("cannot parse templates", "error", err)
+		return true
+	}
+
+	if w.templates != nil {
+		w.Logger.Info("templates reloaded", "count", len(tc))
+	}
+	w.templates = tc
+	w.err = nil
+	return true
+}
+
+// fingerprint summarises the name, size and modification time of every .hbs file
+func (w *Watcher) fingerprint() (string, error) {
+	names, err := fs.Glob(w.FS, "*.hbs")
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		info, err := fs.Stat(w.FS, name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// Start checks for changes in the background until Stop is called
+func (w *Watcher) Start() {
+	w.quit = make(chan struct{})
+	w.wg.Add(1)
+
+	go func() {
+		defer w.wg.Done()
+
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.quit:
+				return
+			case <-ticker.C:
+				w.Check()
+			}
+		}
+	}()
+}
+
+// Stop stops watching for changes
+func (w *Watcher) Stop() {
+	if w.quit == nil {
+		return
+	}
+	close(w.quit)
+	w.wg.Wait()
+	w.quit = nil
+}
@@ -0,0 +1,86 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+func TestWatcher_Check(t *testing.T) {
+	fsys := fstest.MapFS{
+		"home.page.hbs": {Data: []byte(`home`), ModTime: time.Unix(1, 0)},
+	}
+
+	w := NewWatcher(fsys, time.Second, logging.Discard())
+	tc, err := w.Templates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tc["home.page.hbs"]; !ok {
+		t.Fatal("home page not found in template cache")
+	}
+
+	if w.Check() {
+		t.Error("cache rebuilt without any change")
+	}
+
+	fsys["about.page.hbs"] = &fstest.MapFile{Data: []byte(`about`), ModTime: time.Unix(1, 0)}
+	if !w.Check() {
+		t.Error("cache not rebuilt after a template was added")
+	}
+	tc, _ = w.Templates()
+	if _, ok := tc["about.page.hbs"]; !ok {
+		t.Error("added page not found in template cache")
+	}
+
+	fsys["home.page.hbs"] = &fstest.MapFile{Data: []byte(`{{ .Broken`), ModTime: time.Unix(2, 0)}
+	if !w.Check() {
+		t.Error("cache not rebuilt after a template changed")
+	}
+	tc, err = w.Templates()
+	if err == nil {
+		t.Error("expected an error for a broken template")
+	}
+	if _, ok := tc["about.page.hbs"]; !ok {
+		t.Error("last good cache not kept after a parse error")
+	}
+
+	fsys["home.page.hbs"] = &fstest.MapFile{Data: []byte(`fixed`), ModTime: time.Unix(3, 0)}
+	w.Check()
+	if _, err = w.Templates(); err != nil {
+		t.Errorf("error not cleared after the template was fixed: %v", err)
+	}
+}
+
+func TestTemplates_ErrorOverlay(t *testing.T) {
+	fsys := fstest.MapFS{
+		"home.page.hbs": {Data: []byte(`{{ .Broken`)},
+	}
+
+	SetWatcher(NewWatcher(fsys, time.Second, logging.Discard()))
+	defer SetWatcher(nil)
+
+	r, err := getSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	err = Templates(rr, r, "home.page.hbs", &models.TemplateData{})
+	if err == nil {
+		t.Fatal("rendered a broken template")
+	}
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d, wanted %d", rr.Code, http.StatusInternalServerError)
+	}
+	if !strings.Contains(rr.Body.String(), "home.page.hbs") {
+		t.Error("error overlay does not name the broken template")
+	}
+}
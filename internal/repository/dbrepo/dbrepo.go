@@ -12,9 +12,21 @@ type postgressDBRepo struct {
 	DB  *sql.DB
 }
 
+type testDBRepo struct {
+	App *config.AppConfig
+	DB  *sql.DB
+}
+
 func NewPostgresRepo(conn *sql.DB, a *config.AppConfig) repository.DatabaseRepo {
 	return &postgressDBRepo{
 		App: a,
 		DB:  conn,
 	}
 }
+
+// NewTestingRepo returns a repository that does not touch the database, for use in tests
+func NewTestingRepo(a *config.AppConfig) repository.DatabaseRepo {
+	return &testDBRepo{
+		App: a,
+	}
+}
@@ -0,0 +1,40 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/prashant9154/Booking_System/internal/repository"
+)
+
+// sqlStates maps the Postgres error codes callers can act on to the repository errors they mean
+var sqlStates = map[string]error{
+	"23505": repository.ErrConflict,   // unique_violation
+	"23P01": repository.ErrConflict,   // exclusion_violation
+	"40001": repository.ErrConflict,   // serialization_failure
+	"23502": repository.ErrValidation, // not_null_violation
+	"23503": repository.ErrValidation, // foreign_key_violation
+	"23514": repository.ErrValidation, // check_violation
+	"22001": repository.ErrValidation, // string_data_right_truncation
+	"22007": repository.ErrValidation, // invalid_datetime_format
+	"22008": repository.ErrValidation, // datetime_field_overflow
+}
+
+// dbError wraps err in the repository error it corresponds to, keeping the original
+// for logging; errors that mean the database itself failed are returned unchanged
+func dbError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", repository.ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if typed, ok := sqlStates[pgErr.Code]; ok {
+			return fmt.Errorf("%w: %w", typed, err)
+		}
+	}
+
+	return err
+}
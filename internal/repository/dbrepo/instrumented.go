@@ -0,0 +1,320 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/repository"
+	"github.com/prashant9154/Booking_System/internal/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedRepo wraps a DatabaseRepo and records the duration and errors of every call
+type instrumentedRepo struct {
+	next   repository.DatabaseRepo
+	logger *slog.Logger
+}
+
+// NewInstrumentedRepo wraps repo so every call is recorded in the metrics and the log
+func NewInstrumentedRepo(repo repository.DatabaseRepo, logger *slog.Logger) repository.DatabaseRepo {
+	return &instrumentedRepo{next: repo, logger: logger}
+}
+
+// track starts timing a call to method in a new span; the returned function records its outcome.
+// Calls are logged with ctx so they carry the id of the request that made them.
+func (m *instrumentedRepo) track(ctx context.Context, method string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "DatabaseRepo."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperation(method),
+		),
+	)
+
+	return ctx, func(err error) {
+		elapsed := time.Since(start)
+
+		// a row that does not exist is an answer, not a failure of the database
+		failed := err
+		if errors.Is(err, repository.ErrNotFound) {
+			failed = nil
+		}
+
+		metrics.ObserveQuery(method, elapsed, failed)
+
+		if failed != nil {
+			m.logger.ErrorContext(ctx, "query failed", "method", method, "duration", elapsed, "error", err)
+		} else if err != nil {
+			m.logger.DebugContext(ctx, "query", "method", method, "duration", elapsed, "result", "not found")
+		} else {
+			m.logger.DebugContext(ctx, "query", "method", method, "duration", elapsed)
+		}
+
+		tracing.End(span, failed)
+	}
+}
+
+func (m *instrumentedRepo) Ping(ctx context.Context) error {
+	ctx, done := m.track(ctx, "Ping")
+	err := m.next.Ping(ctx)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) AllUsers(ctx context.Context) bool {
+	ctx, done := m.track(ctx, "AllUsers")
+	ok := m.next.AllUsers(ctx)
+	done(nil)
+	return ok
+}
+
+func (m *instrumentedRepo) InsertReservation(ctx context.Context, res models.Reservation) (int, error) {
+	ctx, done := m.track(ctx, "InsertReservation")
+	id, err := m.next.InsertReservation(ctx, res)
+	done(err)
+	return id, err
+}
+
+func (m *instrumentedRepo) InsertRoomRestriction(ctx context.Context, r models.RoomRestriction) error {
+	ctx, done := m.track(ctx, "InsertRoomRestriction")
+	err := m.next.InsertRoomRestriction(ctx, r)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) SearchAvailabilityByDatesByRoomID(ctx context.Context, start, end time.Time, roomID int) (bool, error) {
+	ctx, done := m.track(ctx, "SearchAvailabilityByDatesByRoomID")
+	ok, err := m.next.SearchAvailabilityByDatesByRoomID(ctx, start, end, roomID)
+	done(err)
+	return ok, err
+}
+
+func (m *instrumentedRepo) SearchAvailabilityForAllRooms(ctx context.Context, start, end time.Time) ([]models.Room, error) {
+	ctx, done := m.track(ctx, "SearchAvailabilityForAllRooms")
+	rows, err := m.next.SearchAvailabilityForAllRooms(ctx, start, end)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) GetRoomByID(ctx context.Context, id int) (models.Room, error) {
+	ctx, done := m.track(ctx, "GetRoomByID")
+	room, err := m.next.GetRoomByID(ctx, id)
+	done(err)
+	return room, err
+}
+
+func (m *instrumentedRepo) CreateReservation(ctx context.Context, res models.Reservation, restrictionID int) (int, error) {
+	ctx, done := m.track(ctx, "CreateReservation")
+	id, err := m.next.CreateReservation(ctx, res, restrictionID)
+	done(err)
+	return id, err
+}
+
+func (m *instrumentedRepo) GetReservationByID(ctx context.Context, id int) (models.Reservation, error) {
+	ctx, done := m.track(ctx, "GetReservationByID")
+	res, err := m.next.GetReservationByID(ctx, id)
+	done(err)
+	return res, err
+}
+
+func (m *instrumentedRepo) ReservationsByEmail(ctx context.Context, email string) ([]models.Reservation, error) {
+	ctx, done := m.track(ctx, "ReservationsByEmail")
+	rows, err := m.next.ReservationsByEmail(ctx, email)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) InsertMessage(ctx context.Context, msg models.Message) (int, error) {
+	ctx, done := m.track(ctx, "InsertMessage")
+	id, err := m.next.InsertMessage(ctx, msg)
+	done(err)
+	return id, err
+}
+
+func (m *instrumentedRepo) MessagesByEmail(ctx context.Context, email string) ([]models.Message, error) {
+	ctx, done := m.track(ctx, "MessagesByEmail")
+	rows, err := m.next.MessagesByEmail(ctx, email)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) OutboxEventsByEmail(ctx context.Context, email string) ([]models.Event, error) {
+	ctx, done := m.track(ctx, "OutboxEventsByEmail")
+	rows, err := m.next.OutboxEventsByEmail(ctx, email)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) WebhookDeliveriesByEmail(ctx context.Context, email string) ([]models.WebhookDelivery, error) {
+	ctx, done := m.track(ctx, "WebhookDeliveriesByEmail")
+	rows, err := m.next.WebhookDeliveriesByEmail(ctx, email)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) EraseGuest(ctx context.Context, email string, entry models.AuditEntry) (models.Erasure, error) {
+	ctx, done := m.track(ctx, "EraseGuest")
+	erased, err := m.next.EraseGuest(ctx, email, entry)
+	done(err)
+	return erased, err
+}
+
+func (m *instrumentedRepo) InsertAuditEntry(ctx context.Context, e models.AuditEntry) error {
+	ctx, done := m.track(ctx, "InsertAuditEntry")
+	err := m.next.InsertAuditEntry(ctx, e)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) AuditEntriesBySubject(ctx context.Context, subject string) ([]models.AuditEntry, error) {
+	ctx, done := m.track(ctx, "AuditEntriesBySubject")
+	rows, err := m.next.AuditEntriesBySubject(ctx, subject)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) RecentAuditEntries(ctx context.Context, prefix string, limit int) ([]models.AuditEntry, error) {
+	ctx, done := m.track(ctx, "RecentAuditEntries")
+	rows, err := m.next.RecentAuditEntries(ctx, prefix, limit)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) ApplyRetention(ctx context.Context, table, action string, before time.Time, dryRun bool) (int, error) {
+	ctx, done := m.track(ctx, "ApplyRetention")
+	n, err := m.next.ApplyRetention(ctx, table, action, before, dryRun)
+	done(err)
+	return n, err
+}
+
+func (m *instrumentedRepo) InsertRetentionRun(ctx context.Context, run models.RetentionRun) (int, error) {
+	ctx, done := m.track(ctx, "InsertRetentionRun")
+	id, err := m.next.InsertRetentionRun(ctx, run)
+	done(err)
+	return id, err
+}
+
+func (m *instrumentedRepo) RecentRetentionRuns(ctx context.Context, limit int) ([]models.RetentionRun, error) {
+	ctx, done := m.track(ctx, "RecentRetentionRuns")
+	rows, err := m.next.RecentRetentionRuns(ctx, limit)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) ClaimOutboxEvents(ctx context.Context, now, until time.Time, maxAttempts, limit int) ([]models.Event, error) {
+	ctx, done := m.track(ctx, "ClaimOutboxEvents")
+	rows, err := m.next.ClaimOutboxEvents(ctx, now, until, maxAttempts, limit)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) MarkOutboxEventDispatched(ctx context.Context, id string) error {
+	ctx, done := m.track(ctx, "MarkOutboxEventDispatched")
+	err := m.next.MarkOutboxEventDispatched(ctx, id)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) MarkOutboxEventFailed(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	ctx, done := m.track(ctx, "MarkOutboxEventFailed")
+	err := m.next.MarkOutboxEventFailed(ctx, id, lastError, nextAttemptAt)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) OutboxEventHandled(ctx context.Context, id, handler string) (bool, error) {
+	ctx, done := m.track(ctx, "OutboxEventHandled")
+	handled, err := m.next.OutboxEventHandled(ctx, id, handler)
+	done(err)
+	return handled, err
+}
+
+func (m *instrumentedRepo) MarkOutboxEventHandled(ctx context.Context, id, handler string) error {
+	ctx, done := m.track(ctx, "MarkOutboxEventHandled")
+	err := m.next.MarkOutboxEventHandled(ctx, id, handler)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) GetUserByID(ctx context.Context, id int) (models.User, error) {
+	ctx, done := m.track(ctx, "GetUserByID")
+	user, err := m.next.GetUserByID(ctx, id)
+	done(err)
+	return user, err
+}
+
+func (m *instrumentedRepo) Authenticate(ctx context.Context, email, testPassword string) (int, string, error) {
+	ctx, done := m.track(ctx, "Authenticate")
+	id, hash, err := m.next.Authenticate(ctx, email, testPassword)
+	done(err)
+	return id, hash, err
+}
+
+func (m *instrumentedRepo) AllWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	ctx, done := m.track(ctx, "AllWebhookSubscriptions")
+	rows, err := m.next.AllWebhookSubscriptions(ctx)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) GetWebhookSubscriptionByID(ctx context.Context, id int) (models.WebhookSubscription, error) {
+	ctx, done := m.track(ctx, "GetWebhookSubscriptionByID")
+	subscription, err := m.next.GetWebhookSubscriptionByID(ctx, id)
+	done(err)
+	return subscription, err
+}
+
+func (m *instrumentedRepo) InsertWebhookSubscription(ctx context.Context, s models.WebhookSubscription) (int, error) {
+	ctx, done := m.track(ctx, "InsertWebhookSubscription")
+	id, err := m.next.InsertWebhookSubscription(ctx, s)
+	done(err)
+	return id, err
+}
+
+func (m *instrumentedRepo) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	ctx, done := m.track(ctx, "DeleteWebhookSubscription")
+	err := m.next.DeleteWebhookSubscription(ctx, id)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) InsertWebhookDelivery(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	ctx, done := m.track(ctx, "InsertWebhookDelivery")
+	id, err := m.next.InsertWebhookDelivery(ctx, d)
+	done(err)
+	return id, err
+}
+
+func (m *instrumentedRepo) GetWebhookDeliveryByID(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	ctx, done := m.track(ctx, "GetWebhookDeliveryByID")
+	delivery, err := m.next.GetWebhookDeliveryByID(ctx, id)
+	done(err)
+	return delivery, err
+}
+
+func (m *instrumentedRepo) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	ctx, done := m.track(ctx, "UpdateWebhookDelivery")
+	err := m.next.UpdateWebhookDelivery(ctx, d)
+	done(err)
+	return err
+}
+
+func (m *instrumentedRepo) ClaimDueWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	ctx, done := m.track(ctx, "ClaimDueWebhookDeliveries")
+	rows, err := m.next.ClaimDueWebhookDeliveries(ctx, now, until, limit)
+	done(err)
+	return rows, err
+}
+
+func (m *instrumentedRepo) RecentWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	ctx, done := m.track(ctx, "RecentWebhookDeliveries")
+	rows, err := m.next.RecentWebhookDeliveries(ctx, limit)
+	done(err)
+	return rows, err
+}
@@ -0,0 +1,63 @@
+package dbrepo
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/metrics"
+)
+
+// queryErrors returns the failed calls of method counted in the metrics
+func queryErrors(t *testing.T, method string) float64 {
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range families {
+		if f.GetName() != "bookings_db_query_errors_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "method" && l.GetValue() == method {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestInstrumentedRepo_NotFound(t *testing.T) {
+	var log bytes.Buffer
+	repo := NewInstrumentedRepo(NewTestingRepo(&config.AppConfig{}), slog.New(slog.NewTextHandler(&log, nil)))
+
+	_, err := repo.GetRoomByID(context.Background(), 99)
+	if err == nil {
+		t.Fatal("expected the room not to be found")
+	}
+
+	if n := queryErrors(t, "GetRoomByID"); n != 0 {
+		t.Errorf("expected a missing row not to be counted as an error but got %v", n)
+	}
+	if strings.Contains(log.String(), "level=ERROR") {
+		t.Errorf("expected a missing row not to be logged as an error but got %q", log.String())
+	}
+
+	_, _, err = repo.Authenticate(context.Background(), "nobody@here.ca", "secret")
+	if err == nil {
+		t.Fatal("expected authentication to fail")
+	}
+
+	if n := queryErrors(t, "Authenticate"); n != 1 {
+		t.Errorf("expected the failure to be counted but got %v", n)
+	}
+	if !strings.Contains(log.String(), "level=ERROR") {
+		t.Errorf("expected the failure to be logged as an error but got %q", log.String())
+	}
+}
@@ -2,30 +2,165 @@ package dbrepo
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/prashant9154/Booking_System/internal/events"
 	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/pii"
+	"github.com/prashant9154/Booking_System/internal/retention"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/bcrypt"
 )
 
-func (m *postgressDBRepo) AllUsers() bool {
+// Ping checks that the database can be reached
+func (m *postgressDBRepo) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	return m.DB.PingContext(ctx)
+}
+
+func (m *postgressDBRepo) AllUsers(ctx context.Context) bool {
 	return true
 }
 
 // InsertReservation inserts a reservation into the database
-func (m *postgressDBRepo) InsertReservation(res models.Reservation) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgressDBRepo) InsertReservation(ctx context.Context, res models.Reservation) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_reservation")
+
+	return insertReservation(ctx, m.DB, m.App.Keyring, res)
+}
+
+// InsertRoomRestriction inserts a room restriction into the database
+func (m *postgressDBRepo) InsertRoomRestriction(ctx context.Context, r models.RoomRestriction) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_room_restriction")
+
+	return insertRoomRestriction(ctx, m.DB, r)
+}
+
+// CreateReservation inserts a reservation, its room restriction and a ReservationCreated
+// outbox event in a single transaction, returning the new reservation id
+func (m *postgressDBRepo) CreateReservation(ctx context.Context, res models.Reservation, restrictionID int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_reservation", "insert_room_restriction", "insert_outbox_event")
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	newID, err := insertReservation(ctx, tx, m.App.Keyring, res)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertRoomRestriction(ctx, tx, models.RoomRestriction{
+		StartDate:     res.StartDate,
+		EndDate:       res.EndDate,
+		RoomID:        res.RoomID,
+		ReservationID: newID,
+		RestrictionID: restrictionID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res.ID = newID
+
+	e, err := events.New(events.ReservationCreated, events.NewReservation(res))
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertOutboxEvent(ctx, tx, e)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, dbError(err)
+	}
+
+	return newID, nil
+}
+
+// InsertMessage inserts a message sent with the contact form, returning its id
+func (m *postgressDBRepo) InsertMessage(ctx context.Context, msg models.Message) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
+	statement(ctx, "insert_message")
 
 	var newID int
 
-	stmt := `insert into reservations (first_name, last_name, email, phone, start_date, end_date, room_id, created_at, updated_at)
-			values ($1,$2,$3,$4,$5,$6,$7,$8,$9) returning id`
+	email, err := m.App.Keyring.Seal("messages.email", msg.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `insert into messages (name, email, email_hash, body, created_at, updated_at)
+			values ($1,$2,$3,$4,$5,$6) returning id`
 
-	err := m.DB.QueryRowContext(ctx, stmt,
+	err = m.DB.QueryRowContext(ctx, stmt,
+		msg.Name,
+		email,
+		emailHash(m.App.Keyring, msg.Email),
+		msg.Body,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, dbError(err)
+	}
+	return newID, nil
+}
+
+// statement records the names of the SQL statements run by a call on its trace span
+func statement(ctx context.Context, names ...string) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.StringSlice("db.statement.name", names))
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertReservation inserts res, encrypting the guest's email and phone with keys when they are configured to be
+func insertReservation(ctx context.Context, db execer, keys *pii.Keyring, res models.Reservation) (int, error) {
+	var newID int
+
+	email, err := keys.Seal("reservations.email", res.Email)
+	if err != nil {
+		return 0, err
+	}
+	phone, err := keys.Seal("reservations.phone", res.Phone)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `insert into reservations (first_name, last_name, email, email_hash, phone, start_date, end_date, room_id, created_at, updated_at)
+			values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) returning id`
+
+	err = db.QueryRowContext(ctx, stmt,
 		res.FirstName,
 		res.LastName,
-		res.Email,
-		res.Phone,
+		email,
+		emailHash(keys, res.Email),
+		phone,
 		res.StartDate,
 		res.EndDate,
 		res.RoomID,
@@ -34,20 +169,22 @@ func (m *postgressDBRepo) InsertReservation(res models.Reservation) (int, error)
 	).Scan(&newID)
 
 	if err != nil {
-		return 0, err
+		return 0, dbError(err)
 	}
 	return newID, nil
 }
 
-// InsertRoomRestriction inserts a room restriction into the database
-func (m *postgressDBRepo) InsertRoomRestriction(r models.RoomRestriction) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// emailHash returns the hash email is looked up by, or null without a hash key
+func emailHash(keys *pii.Keyring, email string) sql.NullString {
+	h := keys.Hash(email)
+	return sql.NullString{String: h, Valid: h != ""}
+}
 
+func insertRoomRestriction(ctx context.Context, db execer, r models.RoomRestriction) error {
 	stmt := `insert into room_restrictions (start_date, end_date, room_id, reservation_id, created_at, updated_at, restriction_id)
 			values ($1,$2,$3,$4,$5,$6,$7)`
 
-	_, err := m.DB.ExecContext(ctx, stmt,
+	_, err := db.ExecContext(ctx, stmt,
 		r.StartDate,
 		r.EndDate,
 		r.RoomID,
@@ -57,6 +194,155 @@ func (m *postgressDBRepo) InsertRoomRestriction(r models.RoomRestriction) error
 		r.RestrictionID,
 	)
 
+	if err != nil {
+		return dbError(err)
+	}
+
+	return nil
+}
+
+func insertOutboxEvent(ctx context.Context, db execer, e models.Event) error {
+	stmt := `insert into outbox (id, event_type, payload, occurred_at, created_at, updated_at)
+			values ($1,$2,$3,$4,$5,$6)`
+
+	_, err := db.ExecContext(ctx, stmt,
+		e.ID,
+		e.Type,
+		e.Payload,
+		e.OccurredAt,
+		time.Now(),
+		time.Now(),
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimOutboxEvents claims the undispatched events that have failed fewer than maxAttempts
+// times, are due for their next attempt and are not claimed by another instance until until,
+// and returns them oldest first.
+// Rows another transaction is claiming are skipped rather than waited for.
+func (m *postgressDBRepo) ClaimOutboxEvents(ctx context.Context, now, until time.Time, maxAttempts, limit int) ([]models.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "claim_outbox_events")
+
+	var pending []models.Event
+
+	query := `
+		with claimed as (
+			update outbox set claimed_until = $2, updated_at = $1
+			where id in (
+				select id from outbox
+				where dispatched_at is null and attempts < $3
+					and (next_attempt_at is null or next_attempt_at <= $1)
+					and (claimed_until is null or claimed_until <= $1)
+				order by occurred_at
+				limit $4
+				for update skip locked)
+			returning id, event_type, payload, occurred_at, attempts, last_error
+		)
+		select
+			id, event_type, payload, occurred_at, attempts, last_error
+		from
+			claimed
+		order by occurred_at`
+
+	rows, err := m.DB.QueryContext(ctx, query, now, until, maxAttempts, limit)
+	if err != nil {
+		return pending, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.Event
+
+		err := rows.Scan(
+			&e.ID,
+			&e.Type,
+			&e.Payload,
+			&e.OccurredAt,
+			&e.Attempts,
+			&e.LastError,
+		)
+		if err != nil {
+			return pending, err
+		}
+		pending = append(pending, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return pending, err
+	}
+
+	return pending, nil
+}
+
+// MarkOutboxEventDispatched records that an event was delivered to all its subscribers
+func (m *postgressDBRepo) MarkOutboxEventDispatched(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "mark_outbox_event_dispatched")
+
+	stmt := `update outbox set dispatched_at = $1, updated_at = $1 where id = $2`
+
+	_, err := m.DB.ExecContext(ctx, stmt, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// MarkOutboxEventFailed records a failed dispatch of an event, to be retried at nextAttemptAt
+func (m *postgressDBRepo) MarkOutboxEventFailed(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "mark_outbox_event_failed")
+
+	// the claim is released, and the event is left alone until its next attempt is due
+	stmt := `update outbox set attempts = attempts + 1, last_error = $1, next_attempt_at = $2, claimed_until = null, updated_at = $3
+			where id = $4`
+
+	_, err := m.DB.ExecContext(ctx, stmt, lastError, nextAttemptAt, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// OutboxEventHandled reports whether handler has handled an event
+func (m *postgressDBRepo) OutboxEventHandled(ctx context.Context, id, handler string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_outbox_event_handled")
+
+	var handled bool
+
+	query := `select exists(select 1 from outbox_handled where event_id = $1 and handler = $2)`
+
+	err := m.DB.QueryRowContext(ctx, query, id, handler).Scan(&handled)
+	if err != nil {
+		return false, err
+	}
+
+	return handled, nil
+}
+
+// MarkOutboxEventHandled records that handler has handled an event
+func (m *postgressDBRepo) MarkOutboxEventHandled(ctx context.Context, id, handler string) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_outbox_event_handled")
+
+	stmt := `insert into outbox_handled (event_id, handler, created_at) values ($1, $2, $3)
+			on conflict (event_id, handler) do nothing`
+
+	_, err := m.DB.ExecContext(ctx, stmt, id, handler, time.Now())
 	if err != nil {
 		return err
 	}
@@ -65,9 +351,10 @@ func (m *postgressDBRepo) InsertRoomRestriction(r models.RoomRestriction) error
 }
 
 // SearchAvailabilityByDatesByRoomID return true if room with given room id is available in between start and end dates
-func (m *postgressDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgressDBRepo) SearchAvailabilityByDatesByRoomID(ctx context.Context, start, end time.Time, roomID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
+	statement(ctx, "count_room_restrictions_by_dates")
 
 	var numRows int
 
@@ -94,9 +381,10 @@ func (m *postgressDBRepo) SearchAvailabilityByDatesByRoomID(start, end time.Time
 }
 
 // SearchAvailabilityForAllRooms return all rooms which are available in between given duration
-func (m *postgressDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgressDBRepo) SearchAvailabilityForAllRooms(ctx context.Context, start, end time.Time) ([]models.Room, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
+	statement(ctx, "select_available_rooms")
 
 	var rooms []models.Room
 
@@ -135,10 +423,126 @@ func (m *postgressDBRepo) SearchAvailabilityForAllRooms(start, end time.Time) ([
 	return rooms, nil
 }
 
+// ReservationsByEmail returns the reservations made with email, with the guest's details
+// decrypted, by the hash of the address or, for rows stored before it was kept, by the
+// address itself
+func (m *postgressDBRepo) ReservationsByEmail(ctx context.Context, email string) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_reservations_by_email")
+
+	query := `
+		select
+			r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date,
+			r.room_id, r.created_at, r.updated_at, rm.room_name
+		from
+			reservations r
+			left join rooms rm on rm.id = r.room_id
+		where
+			r.email_hash = $1 or (r.email_hash is null and r.email <> '' and lower(r.email) = $2)
+		order by r.start_date, r.id`
+
+	rows, err := m.DB.QueryContext(ctx, query, m.guestArgs(email)...)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	var reservations []models.Reservation
+	for rows.Next() {
+		var res models.Reservation
+		var roomName sql.NullString
+
+		err = rows.Scan(
+			&res.ID,
+			&res.FirstName,
+			&res.LastName,
+			&res.Email,
+			&res.Phone,
+			&res.StartDate,
+			&res.EndDate,
+			&res.RoomID,
+			&res.CreatedAt,
+			&res.UpdatedAt,
+			&roomName,
+		)
+		if err != nil {
+			return nil, dbError(err)
+		}
+
+		res.Email, err = m.App.Keyring.Open(res.Email)
+		if err != nil {
+			return nil, fmt.Errorf("reservation %d: %w", res.ID, err)
+		}
+		res.Phone, err = m.App.Keyring.Open(res.Phone)
+		if err != nil {
+			return nil, fmt.Errorf("reservation %d: %w", res.ID, err)
+		}
+		res.Room = models.Room{ID: res.RoomID, RoomName: roomName.String}
+
+		reservations = append(reservations, res)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+	return reservations, nil
+}
+
+// GetReservationByID returns the reservation with the given id, with the guest's details decrypted
+func (m *postgressDBRepo) GetReservationByID(ctx context.Context, id int) (models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_reservation_by_id")
+
+	var res models.Reservation
+	var roomName sql.NullString
+
+	query := `
+		select
+			r.id, r.first_name, r.last_name, r.email, r.phone, r.start_date, r.end_date,
+			r.room_id, r.created_at, r.updated_at, rm.room_name
+		from
+			reservations r
+			left join rooms rm on rm.id = r.room_id
+		where
+			r.id = $1`
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&res.ID,
+		&res.FirstName,
+		&res.LastName,
+		&res.Email,
+		&res.Phone,
+		&res.StartDate,
+		&res.EndDate,
+		&res.RoomID,
+		&res.CreatedAt,
+		&res.UpdatedAt,
+		&roomName,
+	)
+	if err != nil {
+		return res, dbError(err)
+	}
+
+	res.Email, err = m.App.Keyring.Open(res.Email)
+	if err != nil {
+		return res, fmt.Errorf("reservation %d: %w", res.ID, err)
+	}
+	res.Phone, err = m.App.Keyring.Open(res.Phone)
+	if err != nil {
+		return res, fmt.Errorf("reservation %d: %w", res.ID, err)
+	}
+	res.Room = models.Room{ID: res.RoomID, RoomName: roomName.String}
+
+	return res, nil
+}
+
 // GetRoomByID return room name of given room id
-func (m *postgressDBRepo) GetRoomByID(id int) (models.Room, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+func (m *postgressDBRepo) GetRoomByID(ctx context.Context, id int) (models.Room, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
 	defer cancel()
+	statement(ctx, "select_room_by_id")
 
 	var room models.Room
 
@@ -159,8 +563,778 @@ func (m *postgressDBRepo) GetRoomByID(id int) (models.Room, error) {
 	)
 
 	if err != nil {
-		return room, err
+		return room, dbError(err)
 	}
 
 	return room, nil
 }
+
+// GetUserByID returns a user by id
+func (m *postgressDBRepo) GetUserByID(ctx context.Context, id int) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_user_by_id")
+
+	query := `
+		select
+			id, first_name, last_name, email, password, access_level, created_at, updated_at
+		from
+			users
+		where id = $1`
+
+	row := m.DB.QueryRowContext(ctx, query, id)
+
+	var u models.User
+	err := row.Scan(
+		&u.ID,
+		&u.FirstName,
+		&u.LastName,
+		&u.Email,
+		&u.Password,
+		&u.AccessLevel,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
+
+	if err != nil {
+		return u, dbError(err)
+	}
+
+	return u, nil
+}
+
+// Authenticate authenticates a user, returning their id and hashed password
+func (m *postgressDBRepo) Authenticate(ctx context.Context, email, testPassword string) (int, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_user_password_by_email")
+
+	var id int
+	var hashedPassword string
+
+	row := m.DB.QueryRowContext(ctx, "select id, password from users where email = $1", email)
+	err := row.Scan(&id, &hashedPassword)
+	if err != nil {
+		return id, "", err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(testPassword))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return 0, "", errors.New("incorrect password")
+	} else if err != nil {
+		return 0, "", err
+	}
+
+	return id, hashedPassword, nil
+}
+
+// AllWebhookSubscriptions returns all webhook subscriptions
+func (m *postgressDBRepo) AllWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_webhook_subscriptions")
+
+	var subscriptions []models.WebhookSubscription
+
+	query := `
+		select
+			id, url, secret, events, active, created_at, updated_at
+		from
+			webhook_subscriptions
+		order by id`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return subscriptions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.WebhookSubscription
+		var events string
+
+		err := rows.Scan(
+			&s.ID,
+			&s.URL,
+			&s.Secret,
+			&events,
+			&s.Active,
+			&s.CreatedAt,
+			&s.UpdatedAt,
+		)
+		if err != nil {
+			return subscriptions, err
+		}
+
+		s.Events = splitEvents(events)
+		subscriptions = append(subscriptions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return subscriptions, err
+	}
+
+	return subscriptions, nil
+}
+
+// GetWebhookSubscriptionByID returns a webhook subscription by id
+func (m *postgressDBRepo) GetWebhookSubscriptionByID(ctx context.Context, id int) (models.WebhookSubscription, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_webhook_subscription_by_id")
+
+	var s models.WebhookSubscription
+	var events string
+
+	query := `
+		select
+			id, url, secret, events, active, created_at, updated_at
+		from
+			webhook_subscriptions
+		where id = $1`
+
+	row := m.DB.QueryRowContext(ctx, query, id)
+	err := row.Scan(
+		&s.ID,
+		&s.URL,
+		&s.Secret,
+		&events,
+		&s.Active,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return s, dbError(err)
+	}
+
+	s.Events = splitEvents(events)
+
+	return s, nil
+}
+
+// InsertWebhookSubscription inserts a webhook subscription into the database
+func (m *postgressDBRepo) InsertWebhookSubscription(ctx context.Context, s models.WebhookSubscription) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_webhook_subscription")
+
+	var newID int
+
+	stmt := `insert into webhook_subscriptions (url, secret, events, active, created_at, updated_at)
+			values ($1,$2,$3,$4,$5,$6) returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt,
+		s.URL,
+		s.Secret,
+		strings.Join(s.Events, ","),
+		s.Active,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err != nil {
+		return 0, dbError(err)
+	}
+	return newID, nil
+}
+
+// DeleteWebhookSubscription deletes a webhook subscription and its deliveries
+func (m *postgressDBRepo) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "delete_webhook_subscription")
+
+	_, err := m.DB.ExecContext(ctx, "delete from webhook_subscriptions where id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InsertWebhookDelivery queues a webhook delivery; a delivery already queued for the same event
+// and subscription is left alone, and 0 is returned as the id
+func (m *postgressDBRepo) InsertWebhookDelivery(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_webhook_delivery")
+
+	var newID int
+
+	stmt := `insert into webhook_deliveries (subscription_id, event_id, event, payload, status, attempts, next_attempt_at, created_at, updated_at)
+			values ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+			on conflict (event_id, subscription_id) do nothing
+			returning id`
+
+	err := m.DB.QueryRowContext(ctx, stmt,
+		d.SubscriptionID,
+		d.EventID,
+		d.Event,
+		d.Payload,
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		time.Now(),
+		time.Now(),
+	).Scan(&newID)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return newID, nil
+}
+
+// GetWebhookDeliveryByID returns a webhook delivery, with its subscription, by id
+func (m *postgressDBRepo) GetWebhookDeliveryByID(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_webhook_delivery_by_id")
+
+	query := webhookDeliverySelect + ` where d.id = $1`
+
+	row := m.DB.QueryRowContext(ctx, query, id)
+
+	d, err := scanWebhookDelivery(row)
+	return d, dbError(err)
+}
+
+// UpdateWebhookDelivery stores the outcome of a delivery attempt
+func (m *postgressDBRepo) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "update_webhook_delivery")
+
+	var deliveredAt sql.NullTime
+	if !d.DeliveredAt.IsZero() {
+		deliveredAt = sql.NullTime{Time: d.DeliveredAt, Valid: true}
+	}
+
+	stmt := `update webhook_deliveries set status = $1, attempts = $2, next_attempt_at = $3, last_error = $4,
+			response_code = $5, delivered_at = $6, updated_at = $7
+			where id = $8`
+
+	_, err := m.DB.ExecContext(ctx, stmt,
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.LastError,
+		d.ResponseCode,
+		deliveredAt,
+		time.Now(),
+		d.ID,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimDueWebhookDeliveries claims the pending deliveries whose next attempt is due by moving
+// that attempt to until, so no other instance picks them up before then, and returns them.
+// Rows another transaction is claiming are skipped rather than waited for.
+func (m *postgressDBRepo) ClaimDueWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "claim_due_webhook_deliveries")
+
+	query := `
+		with claimed as (
+			update webhook_deliveries set next_attempt_at = $2, updated_at = $1
+			where id in (
+				select id from webhook_deliveries
+				where status = 'pending' and next_attempt_at <= $1
+				order by next_attempt_at
+				limit $3
+				for update skip locked)
+			returning id
+		)` + webhookDeliverySelect + `
+		where
+			d.id in (select id from claimed)
+		order by d.next_attempt_at`
+
+	return m.queryWebhookDeliveries(ctx, query, now, until, limit)
+}
+
+// RecentWebhookDeliveries returns the most recent deliveries, newest first
+func (m *postgressDBRepo) RecentWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_recent_webhook_deliveries")
+
+	query := webhookDeliverySelect + `
+		order by d.id desc
+		limit $1`
+
+	return m.queryWebhookDeliveries(ctx, query, limit)
+}
+
+const webhookDeliverySelect = `
+		select
+			d.id, d.subscription_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+			d.last_error, d.response_code, d.delivered_at, d.created_at, d.updated_at,
+			s.id, s.url, s.secret, s.events, s.active
+		from
+			webhook_deliveries d
+			left join webhook_subscriptions s on (s.id = d.subscription_id)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhookDelivery(row rowScanner) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var deliveredAt sql.NullTime
+	var events string
+
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastError,
+		&d.ResponseCode,
+		&deliveredAt,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+		&d.Subscription.ID,
+		&d.Subscription.URL,
+		&d.Subscription.Secret,
+		&events,
+		&d.Subscription.Active,
+	)
+	if err != nil {
+		return d, err
+	}
+
+	d.DeliveredAt = deliveredAt.Time
+	d.Subscription.Events = splitEvents(events)
+
+	return d, nil
+}
+
+func (m *postgressDBRepo) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return deliveries, err
+	}
+
+	return deliveries, nil
+}
+
+// splitEvents turns the comma separated events column into a slice
+func splitEvents(events string) []string {
+	if events == "" {
+		return nil
+	}
+	return strings.Split(events, ",")
+}
+
+// guestWhere matches the rows of a table with email, by its hash or, for rows stored
+// before the hash was kept, by the address itself
+const guestWhere = `email_hash = $1 or (email_hash is null and email <> '' and lower(email) = $2)`
+
+// guestArgs returns the arguments of guestWhere for email
+func (m *postgressDBRepo) guestArgs(email string) []any {
+	return []any{m.App.Keyring.Hash(email), strings.ToLower(strings.TrimSpace(email))}
+}
+
+// MessagesByEmail returns the contact form messages sent from email, oldest first
+func (m *postgressDBRepo) MessagesByEmail(ctx context.Context, email string) ([]models.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_messages_by_email")
+
+	query := `
+		select id, name, email, body, created_at, updated_at
+		from messages
+		where ` + guestWhere + `
+		order by created_at, id`
+
+	rows, err := m.DB.QueryContext(ctx, query, m.guestArgs(email)...)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	var messages []models.Message
+	for rows.Next() {
+		var msg models.Message
+		err = rows.Scan(&msg.ID, &msg.Name, &msg.Email, &msg.Body, &msg.CreatedAt, &msg.UpdatedAt)
+		if err != nil {
+			return nil, dbError(err)
+		}
+
+		msg.Email, err = m.App.Keyring.Open(msg.Email)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", msg.ID, err)
+		}
+
+		messages = append(messages, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+	return messages, nil
+}
+
+// guestEventsWhere and guestDeliveriesWhere match the outbox events and the webhook
+// deliveries of the reservations matched by guestWhere, which name them by id
+const (
+	guestEventsWhere = `event_type like 'reservation.%' and payload::jsonb ->> 'id' in (
+			select id::text from reservations where ` + guestWhere + `)`
+	guestDeliveriesWhere = `d.event like 'reservation.%' and d.payload::jsonb -> 'data' ->> 'id' in (
+			select id::text from reservations where ` + guestWhere + `)`
+)
+
+// OutboxEventsByEmail returns the outbox events of the reservations made with email, oldest first
+func (m *postgressDBRepo) OutboxEventsByEmail(ctx context.Context, email string) ([]models.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_outbox_events_by_email")
+
+	query := `
+		select id, event_type, payload, occurred_at, attempts, last_error, dispatched_at
+		from outbox
+		where ` + guestEventsWhere + `
+		order by occurred_at, id`
+
+	rows, err := m.DB.QueryContext(ctx, query, m.guestArgs(email)...)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	var stored []models.Event
+	for rows.Next() {
+		var e models.Event
+		var dispatchedAt sql.NullTime
+
+		err = rows.Scan(&e.ID, &e.Type, &e.Payload, &e.OccurredAt, &e.Attempts, &e.LastError, &dispatchedAt)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		e.DispatchedAt = dispatchedAt.Time
+
+		stored = append(stored, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+	return stored, nil
+}
+
+// WebhookDeliveriesByEmail returns the webhook deliveries of the reservations made with email, oldest first
+func (m *postgressDBRepo) WebhookDeliveriesByEmail(ctx context.Context, email string) ([]models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_webhook_deliveries_by_email")
+
+	query := webhookDeliverySelect + `
+		where ` + guestDeliveriesWhere + `
+		order by d.id`
+
+	deliveries, err := m.queryWebhookDeliveries(ctx, query, m.guestArgs(email)...)
+	return deliveries, dbError(err)
+}
+
+// anonymizedReservation and anonymizedMessage clear the personal details of a row, with %[1]s the time it happens
+const (
+	anonymizedReservation = `first_name = '', last_name = '', email = '', email_hash = null, phone = '',
+			anonymized_at = %[1]s, updated_at = %[1]s`
+	anonymizedMessage = `name = '', email = '', email_hash = null, body = '',
+			anonymized_at = %[1]s, updated_at = %[1]s`
+)
+
+// EraseGuest anonymizes the personal details of the reservations and messages of email in
+// a single transaction. Stay dates and rooms are kept for accounting; names, contact
+// details and message bodies are cleared, and the outbox events and webhook deliveries
+// telling of the reservations are deleted. entry is added to the audit log in the same
+// transaction, with what was erased as its details unless it has some, so an erasure is
+// never left unrecorded.
+func (m *postgressDBRepo) EraseGuest(ctx context.Context, email string, entry models.AuditEntry) (models.Erasure, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "delete_guest_outbox_events", "delete_guest_webhook_deliveries", "anonymize_reservations", "anonymize_messages", "insert_audit_entry")
+
+	var erased models.Erasure
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return erased, err
+	}
+	defer tx.Rollback()
+
+	// the events are found through the reservations, so they go before those are anonymized
+	result, err := tx.ExecContext(ctx, `delete from outbox where `+guestEventsWhere, m.guestArgs(email)...)
+	if err != nil {
+		return erased, dbError(err)
+	}
+	n, _ := result.RowsAffected()
+	erased.Events = int(n)
+
+	result, err = tx.ExecContext(ctx, `delete from webhook_deliveries d where `+guestDeliveriesWhere, m.guestArgs(email)...)
+	if err != nil {
+		return erased, dbError(err)
+	}
+	n, _ = result.RowsAffected()
+	erased.WebhookDeliveries = int(n)
+
+	now := time.Now()
+	args := append(m.guestArgs(email), now)
+
+	result, err = tx.ExecContext(ctx, `
+		update reservations
+		set `+fmt.Sprintf(anonymizedReservation, "$3")+`
+		where `+guestWhere, args...)
+	if err != nil {
+		return erased, dbError(err)
+	}
+	n, _ = result.RowsAffected()
+	erased.Reservations = int(n)
+
+	result, err = tx.ExecContext(ctx, `
+		update messages
+		set `+fmt.Sprintf(anonymizedMessage, "$3")+`
+		where `+guestWhere, args...)
+	if err != nil {
+		return erased, dbError(err)
+	}
+	n, _ = result.RowsAffected()
+	erased.Messages = int(n)
+
+	if entry.Details == "" {
+		b, err := json.Marshal(erased)
+		if err != nil {
+			return erased, err
+		}
+		entry.Details = string(b)
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = now
+	}
+
+	_, err = tx.ExecContext(ctx, insertAuditEntry,
+		entry.Action, entry.Actor, entry.Subject, entry.ClientIP, entry.RequestID, entry.Details, entry.CreatedAt,
+	)
+	if err != nil {
+		return erased, dbError(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return models.Erasure{}, dbError(err)
+	}
+	return erased, nil
+}
+
+// insertAuditEntry adds an entry to the audit log
+const insertAuditEntry = `
+		insert into audit_log (action, actor, subject, client_ip, request_id, details, created_at)
+		values ($1,$2,$3,$4,$5,$6,$7)`
+
+// InsertAuditEntry records an operation on personal data in the audit log
+func (m *postgressDBRepo) InsertAuditEntry(ctx context.Context, e models.AuditEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_audit_entry")
+
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	_, err := m.DB.ExecContext(ctx, insertAuditEntry,
+		e.Action, e.Actor, e.Subject, e.ClientIP, e.RequestID, e.Details, e.CreatedAt,
+	)
+	return dbError(err)
+}
+
+// AuditEntriesBySubject returns the audit log entries about subject, oldest first
+func (m *postgressDBRepo) AuditEntriesBySubject(ctx context.Context, subject string) ([]models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_audit_entries_by_subject")
+
+	return m.queryAuditEntries(ctx, `
+		select id, action, actor, subject, client_ip, request_id, details, created_at
+		from audit_log
+		where subject = $1
+		order by created_at, id`, subject)
+}
+
+// RecentAuditEntries returns the latest limit audit log entries whose action starts with prefix, newest first
+func (m *postgressDBRepo) RecentAuditEntries(ctx context.Context, prefix string, limit int) ([]models.AuditEntry, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_recent_audit_entries")
+
+	return m.queryAuditEntries(ctx, `
+		select id, action, actor, subject, client_ip, request_id, details, created_at
+		from audit_log
+		where starts_with(action, $1)
+		order by created_at desc, id desc
+		limit $2`, prefix, limit)
+}
+
+func (m *postgressDBRepo) queryAuditEntries(ctx context.Context, query string, args ...any) ([]models.AuditEntry, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		err = rows.Scan(&e.ID, &e.Action, &e.Actor, &e.Subject, &e.ClientIP, &e.RequestID, &e.Details, &e.CreatedAt)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+	return entries, nil
+}
+
+// retentionTimeout bounds a single retention statement, which may go through years of rows
+const retentionTimeout = time.Minute
+
+// retentionTargets holds, for each table with a retention policy, the condition of the rows
+// older than $1 and how they are anonymized, when they can be
+var retentionTargets = map[string]struct {
+	older     string
+	anonymize string
+}{
+	"reservations":       {older: "end_date < $1", anonymize: anonymizedReservation},
+	"messages":           {older: "created_at < $1", anonymize: anonymizedMessage},
+	"sessions":           {older: "expiry < $1"},
+	"outbox":             {older: "dispatched_at < $1"},
+	"webhook_deliveries": {older: "status <> 'pending' and updated_at < $1"},
+	"audit_log":          {older: "created_at < $1"},
+}
+
+// ApplyRetention anonymizes or deletes the rows of table older than before, or only counts
+// them in a dry run, returning how many there were. Rows already anonymized are left alone.
+func (m *postgressDBRepo) ApplyRetention(ctx context.Context, table, action string, before time.Time, dryRun bool) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, retentionTimeout)
+	defer cancel()
+	statement(ctx, "retention_"+action+"_"+table)
+
+	target, ok := retentionTargets[table]
+	if !ok || (action == retention.Anonymize && target.anonymize == "") || (action != retention.Anonymize && action != retention.Delete) {
+		return 0, fmt.Errorf("cannot %s the rows of %s", action, table)
+	}
+
+	where := target.older
+	if action == retention.Anonymize {
+		where += " and anonymized_at is null"
+	}
+
+	if dryRun {
+		var n int
+		err := m.DB.QueryRowContext(ctx, "select count(*) from "+table+" where "+where, before).Scan(&n)
+		return n, dbError(err)
+	}
+
+	var result sql.Result
+	var err error
+	if action == retention.Anonymize {
+		result, err = m.DB.ExecContext(ctx, "update "+table+" set "+fmt.Sprintf(target.anonymize, "$2")+" where "+where, before, time.Now())
+	} else {
+		result, err = m.DB.ExecContext(ctx, "delete from "+table+" where "+where, before)
+	}
+	if err != nil {
+		return 0, dbError(err)
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// InsertRetentionRun records a run of the retention job and returns its id
+func (m *postgressDBRepo) InsertRetentionRun(ctx context.Context, run models.RetentionRun) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "insert_retention_run")
+
+	results, err := json.Marshal(run.Results)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = m.DB.QueryRowContext(ctx, `
+		insert into retention_runs (actor, dry_run, results, error, started_at, finished_at)
+		values ($1, $2, $3, $4, $5, $6) returning id`,
+		run.Actor, run.DryRun, string(results), run.Error, run.StartedAt, run.FinishedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, dbError(err)
+	}
+	return id, nil
+}
+
+// RecentRetentionRuns returns the latest limit runs of the retention job, newest first
+func (m *postgressDBRepo) RecentRetentionRuns(ctx context.Context, limit int) ([]models.RetentionRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	statement(ctx, "select_recent_retention_runs")
+
+	rows, err := m.DB.QueryContext(ctx, `
+		select id, actor, dry_run, results, error, started_at, finished_at
+		from retention_runs
+		order by started_at desc, id desc
+		limit $1`, limit)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+
+	var runs []models.RetentionRun
+	for rows.Next() {
+		var run models.RetentionRun
+		var results string
+		err = rows.Scan(&run.ID, &run.Actor, &run.DryRun, &results, &run.Error, &run.StartedAt, &run.FinishedAt)
+		if err != nil {
+			return nil, dbError(err)
+		}
+		if results != "" {
+			err = json.Unmarshal([]byte(results), &run.Results)
+			if err != nil {
+				return nil, fmt.Errorf("retention run %d: %w", run.ID, err)
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, dbError(err)
+	}
+	return runs, nil
+}
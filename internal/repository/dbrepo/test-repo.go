@@ -0,0 +1,237 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/models"
+	"github.com/prashant9154/Booking_System/internal/repository"
+)
+
+// Ping checks that the database can be reached
+func (m *testDBRepo) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *testDBRepo) AllUsers(ctx context.Context) bool {
+	return true
+}
+
+// InsertReservation inserts a reservation into the database
+func (m *testDBRepo) InsertReservation(ctx context.Context, res models.Reservation) (int, error) {
+	// if the room id is 2, then fail; otherwise, pass
+	if res.RoomID == 2 {
+		return 0, errors.New("some error")
+	}
+	return 1, nil
+}
+
+// InsertRoomRestriction inserts a room restriction into the database
+func (m *testDBRepo) InsertRoomRestriction(ctx context.Context, r models.RoomRestriction) error {
+	if r.RoomID == 1000 {
+		return errors.New("some error")
+	}
+	return nil
+}
+
+// CreateReservation inserts a reservation, its room restriction and a ReservationCreated
+// outbox event in a single transaction, returning the new reservation id
+func (m *testDBRepo) CreateReservation(ctx context.Context, res models.Reservation, restrictionID int) (int, error) {
+	// if the room id is 2, then fail; otherwise, pass
+	if res.RoomID == 2 {
+		return 0, errors.New("some error")
+	}
+	return 1, nil
+}
+
+// ReservationsByEmail returns the reservations made with email
+func (m *testDBRepo) ReservationsByEmail(ctx context.Context, email string) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+	return reservations, nil
+}
+
+// InsertMessage inserts a message sent with the contact form, returning its id
+func (m *testDBRepo) InsertMessage(ctx context.Context, msg models.Message) (int, error) {
+	return 1, nil
+}
+
+// MessagesByEmail returns the contact form messages sent from email
+func (m *testDBRepo) MessagesByEmail(ctx context.Context, email string) ([]models.Message, error) {
+	var messages []models.Message
+	return messages, nil
+}
+
+// OutboxEventsByEmail returns the outbox events of the reservations made with email
+func (m *testDBRepo) OutboxEventsByEmail(ctx context.Context, email string) ([]models.Event, error) {
+	var stored []models.Event
+	return stored, nil
+}
+
+// WebhookDeliveriesByEmail returns the webhook deliveries of the reservations made with email
+func (m *testDBRepo) WebhookDeliveriesByEmail(ctx context.Context, email string) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	return deliveries, nil
+}
+
+// EraseGuest anonymizes the personal details of the reservations and messages of email
+// and records it with entry
+func (m *testDBRepo) EraseGuest(ctx context.Context, email string, entry models.AuditEntry) (models.Erasure, error) {
+	return models.Erasure{}, nil
+}
+
+// InsertAuditEntry records an operation on personal data in the audit log
+func (m *testDBRepo) InsertAuditEntry(ctx context.Context, e models.AuditEntry) error {
+	return nil
+}
+
+// AuditEntriesBySubject returns the audit log entries about subject
+func (m *testDBRepo) AuditEntriesBySubject(ctx context.Context, subject string) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	return entries, nil
+}
+
+// RecentAuditEntries returns the latest audit log entries whose action starts with prefix
+func (m *testDBRepo) RecentAuditEntries(ctx context.Context, prefix string, limit int) ([]models.AuditEntry, error) {
+	var entries []models.AuditEntry
+	return entries, nil
+}
+
+// ApplyRetention anonymizes or deletes the rows of table older than before, or counts them in a dry run
+func (m *testDBRepo) ApplyRetention(ctx context.Context, table, action string, before time.Time, dryRun bool) (int, error) {
+	return 0, nil
+}
+
+// InsertRetentionRun records a run of the retention job
+func (m *testDBRepo) InsertRetentionRun(ctx context.Context, run models.RetentionRun) (int, error) {
+	return 1, nil
+}
+
+// RecentRetentionRuns returns the latest runs of the retention job
+func (m *testDBRepo) RecentRetentionRuns(ctx context.Context, limit int) ([]models.RetentionRun, error) {
+	var runs []models.RetentionRun
+	return runs, nil
+}
+
+// ClaimOutboxEvents claims undispatched events that have failed fewer than maxAttempts times until until, oldest first
+func (m *testDBRepo) ClaimOutboxEvents(ctx context.Context, now, until time.Time, maxAttempts, limit int) ([]models.Event, error) {
+	var pending []models.Event
+	return pending, nil
+}
+
+// MarkOutboxEventDispatched records that an event was delivered to all its subscribers
+func (m *testDBRepo) MarkOutboxEventDispatched(ctx context.Context, id string) error {
+	return nil
+}
+
+// MarkOutboxEventFailed records a failed dispatch of an event, to be retried at nextAttemptAt
+func (m *testDBRepo) MarkOutboxEventFailed(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error {
+	return nil
+}
+
+// OutboxEventHandled reports whether handler has handled an event
+func (m *testDBRepo) OutboxEventHandled(ctx context.Context, id, handler string) (bool, error) {
+	return false, nil
+}
+
+// MarkOutboxEventHandled records that handler has handled an event
+func (m *testDBRepo) MarkOutboxEventHandled(ctx context.Context, id, handler string) error {
+	return nil
+}
+
+// SearchAvailabilityByDatesByRoomID return true if room with given room id is available in between start and end dates
+func (m *testDBRepo) SearchAvailabilityByDatesByRoomID(ctx context.Context, start, end time.Time, roomID int) (bool, error) {
+	return false, nil
+}
+
+// SearchAvailabilityForAllRooms return all rooms which are available in between given duration
+func (m *testDBRepo) SearchAvailabilityForAllRooms(ctx context.Context, start, end time.Time) ([]models.Room, error) {
+	var rooms []models.Room
+	return rooms, nil
+}
+
+// GetRoomByID return room name of given room id
+func (m *testDBRepo) GetRoomByID(ctx context.Context, id int) (models.Room, error) {
+	var room models.Room
+	if id > 2 {
+		return room, fmt.Errorf("room %d: %w", id, repository.ErrNotFound)
+	}
+	return room, nil
+}
+
+// GetReservationByID returns the reservation with the given id
+func (m *testDBRepo) GetReservationByID(ctx context.Context, id int) (models.Reservation, error) {
+	if id > 2 {
+		return models.Reservation{}, fmt.Errorf("reservation %d: %w", id, repository.ErrNotFound)
+	}
+	return models.Reservation{ID: id, FirstName: "John", Email: "guest@example.com"}, nil
+}
+
+// GetUserByID returns a user by id
+func (m *testDBRepo) GetUserByID(ctx context.Context, id int) (models.User, error) {
+	var u models.User
+	return u, nil
+}
+
+// Authenticate authenticates a user, returning their id and hashed password
+func (m *testDBRepo) Authenticate(ctx context.Context, email, testPassword string) (int, string, error) {
+	if email == "me@here.ca" {
+		return 1, "", nil
+	}
+	return 0, "", errors.New("some error")
+}
+
+// AllWebhookSubscriptions returns all webhook subscriptions
+func (m *testDBRepo) AllWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	return subscriptions, nil
+}
+
+// GetWebhookSubscriptionByID returns a webhook subscription by id
+func (m *testDBRepo) GetWebhookSubscriptionByID(ctx context.Context, id int) (models.WebhookSubscription, error) {
+	var s models.WebhookSubscription
+	return s, nil
+}
+
+// InsertWebhookSubscription inserts a webhook subscription into the database
+func (m *testDBRepo) InsertWebhookSubscription(ctx context.Context, s models.WebhookSubscription) (int, error) {
+	return 1, nil
+}
+
+// DeleteWebhookSubscription deletes a webhook subscription and its deliveries
+func (m *testDBRepo) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	return nil
+}
+
+// InsertWebhookDelivery queues a webhook delivery
+func (m *testDBRepo) InsertWebhookDelivery(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	return 1, nil
+}
+
+// GetWebhookDeliveryByID returns a webhook delivery, with its subscription, by id
+func (m *testDBRepo) GetWebhookDeliveryByID(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	if id > 1 {
+		return d, fmt.Errorf("webhook delivery %d: %w", id, repository.ErrNotFound)
+	}
+	d.ID = id
+	return d, nil
+}
+
+// UpdateWebhookDelivery stores the outcome of a delivery attempt
+func (m *testDBRepo) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	return nil
+}
+
+// ClaimDueWebhookDeliveries claims the pending deliveries whose next attempt is due until until
+func (m *testDBRepo) ClaimDueWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	return deliveries, nil
+}
+
+// RecentWebhookDeliveries returns the most recent deliveries, newest first
+func (m *testDBRepo) RecentWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	return deliveries, nil
+}
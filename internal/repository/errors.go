@@ -0,0 +1,14 @@
+package repository
+
+import "errors"
+
+// Errors wrapped by DatabaseRepo implementations, so callers can tell with errors.Is
+// a record that is missing or was rejected from a database that is failing
+var (
+	// ErrNotFound means the record asked for does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the write clashes with existing data, such as a duplicate or overlapping booking
+	ErrConflict = errors.New("conflict")
+	// ErrValidation means the database rejected the values written, such as a violated check constraint
+	ErrValidation = errors.New("invalid data")
+)
@@ -1,16 +1,53 @@
 package repository
 
 import (
+	"context"
 	"time"
 
 	"github.com/prashant9154/Booking_System/internal/models"
 )
 
 type DatabaseRepo interface {
-	AllUsers() bool
-	InsertReservation(res models.Reservation) (int, error)
-	InsertRoomRestriction(r models.RoomRestriction) error
-	SearchAvailabilityByDatesByRoomID(start, end time.Time, roomID int) (bool, error)
-	SearchAvailabilityForAllRooms(start, end time.Time) ([]models.Room, error)
-	GetRoomByID(id int) (models.Room, error)
+	Ping(ctx context.Context) error
+	AllUsers(ctx context.Context) bool
+	InsertReservation(ctx context.Context, res models.Reservation) (int, error)
+	InsertRoomRestriction(ctx context.Context, r models.RoomRestriction) error
+	SearchAvailabilityByDatesByRoomID(ctx context.Context, start, end time.Time, roomID int) (bool, error)
+	SearchAvailabilityForAllRooms(ctx context.Context, start, end time.Time) ([]models.Room, error)
+	GetRoomByID(ctx context.Context, id int) (models.Room, error)
+	GetReservationByID(ctx context.Context, id int) (models.Reservation, error)
+	ReservationsByEmail(ctx context.Context, email string) ([]models.Reservation, error)
+	CreateReservation(ctx context.Context, res models.Reservation, restrictionID int) (int, error)
+	InsertMessage(ctx context.Context, msg models.Message) (int, error)
+	MessagesByEmail(ctx context.Context, email string) ([]models.Message, error)
+	OutboxEventsByEmail(ctx context.Context, email string) ([]models.Event, error)
+	WebhookDeliveriesByEmail(ctx context.Context, email string) ([]models.WebhookDelivery, error)
+	EraseGuest(ctx context.Context, email string, entry models.AuditEntry) (models.Erasure, error)
+
+	InsertAuditEntry(ctx context.Context, e models.AuditEntry) error
+	AuditEntriesBySubject(ctx context.Context, subject string) ([]models.AuditEntry, error)
+	RecentAuditEntries(ctx context.Context, prefix string, limit int) ([]models.AuditEntry, error)
+
+	ApplyRetention(ctx context.Context, table, action string, before time.Time, dryRun bool) (int, error)
+	InsertRetentionRun(ctx context.Context, run models.RetentionRun) (int, error)
+	RecentRetentionRuns(ctx context.Context, limit int) ([]models.RetentionRun, error)
+
+	ClaimOutboxEvents(ctx context.Context, now, until time.Time, maxAttempts, limit int) ([]models.Event, error)
+	MarkOutboxEventDispatched(ctx context.Context, id string) error
+	MarkOutboxEventFailed(ctx context.Context, id string, lastError string, nextAttemptAt time.Time) error
+	OutboxEventHandled(ctx context.Context, id, handler string) (bool, error)
+	MarkOutboxEventHandled(ctx context.Context, id, handler string) error
+
+	GetUserByID(ctx context.Context, id int) (models.User, error)
+	Authenticate(ctx context.Context, email, testPassword string) (int, string, error)
+
+	AllWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetWebhookSubscriptionByID(ctx context.Context, id int) (models.WebhookSubscription, error)
+	InsertWebhookSubscription(ctx context.Context, s models.WebhookSubscription) (int, error)
+	DeleteWebhookSubscription(ctx context.Context, id int) error
+	InsertWebhookDelivery(ctx context.Context, d models.WebhookDelivery) (int, error)
+	GetWebhookDeliveryByID(ctx context.Context, id int) (models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error
+	ClaimDueWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error)
+	RecentWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error)
 }
@@ -0,0 +1,223 @@
+// Package retention runs the job that anonymizes or deletes the rows of each table once
+// they are older than the table's retention policy allows, and records every run.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/audit"
+	"github.com/prashant9154/Booking_System/internal/metrics"
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// The actions of a policy
+const (
+	// Anonymize clears the personal details of a row but keeps the row
+	Anonymize = "anonymize"
+	// Delete deletes the row
+	Delete = "delete"
+)
+
+// Day and Year are the units of ages besides those of time.ParseDuration
+const (
+	Day  = 24 * time.Hour
+	Year = 365 * Day
+)
+
+// Tables lists the tables policies can be set for, and the actions each supports, in the
+// order the job applies them. Reservations are only anonymized so stays stay in the accounts.
+// Rate limit buckets are not among them: the store that keeps them deletes them once expired.
+var Tables = []struct {
+	Name    string
+	Actions []string
+}{
+	{"reservations", []string{Anonymize}},
+	{"messages", []string{Anonymize, Delete}},
+	{"sessions", []string{Delete}},
+	{"outbox", []string{Delete}},
+	{"webhook_deliveries", []string{Delete}},
+	{"audit_log", []string{Delete}},
+}
+
+// Supports reports whether action can be applied to the rows of table
+func Supports(table, action string) bool {
+	for _, t := range Tables {
+		if t.Name == table {
+			for _, a := range t.Actions {
+				if a == action {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Policy anonymizes or deletes the rows of Table once they are older than After: for
+// reservations from the check out, for sessions and rate limits from their expiry, and
+// from when they were written, sent or dispatched otherwise
+type Policy struct {
+	Table  string
+	Action string
+	After  time.Duration
+}
+
+// String returns the policy as it is configured, e.g. anonymize reservations after 1095d
+func (p Policy) String() string {
+	return p.Action + " " + p.Table + " after " + FormatAge(p.After)
+}
+
+// ParseAge reads an age as a number of days, e.g. 30d, of years of 365 days, e.g. 3y, or as
+// a time.Duration, e.g. 12h
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	for suffix, unit := range map[string]time.Duration{"d": Day, "y": Year} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			i, err := strconv.Atoi(n)
+			if err != nil || i < 0 {
+				return 0, fmt.Errorf("%q is not an age such as 30d", s)
+			}
+			return time.Duration(i) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not an age such as 30d", s)
+	}
+	return d, nil
+}
+
+// FormatAge writes an age in days when it is a whole number of them, as a time.Duration otherwise
+func FormatAge(d time.Duration) string {
+	if d > 0 && d%Day == 0 {
+		return strconv.FormatInt(int64(d/Day), 10) + "d"
+	}
+	return d.String()
+}
+
+// Store is the persistence the job needs; it is satisfied by repository.DatabaseRepo
+type Store interface {
+	// ApplyRetention anonymizes or deletes the rows of table older than before, or only
+	// counts them in a dry run, returning how many there were
+	ApplyRetention(ctx context.Context, table, action string, before time.Time, dryRun bool) (int, error)
+	InsertRetentionRun(ctx context.Context, run models.RetentionRun) (int, error)
+}
+
+// Job applies the retention policies periodically and records each run
+type Job struct {
+	Store    Store
+	Logger   *slog.Logger
+	Policies []Policy
+
+	// Interval is how often the scheduled runs happen
+	Interval time.Duration
+	// DryRun makes the scheduled runs only count the rows they would change
+	DryRun bool
+
+	now func() time.Time
+
+	// mu keeps runs, scheduled or not, from overlapping
+	mu   sync.Mutex
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a job applying policies once a day
+func New(store Store, policies []Policy, logger *slog.Logger) *Job {
+	return &Job{
+		Store:    store,
+		Logger:   logger,
+		Policies: policies,
+		Interval: Day,
+		now:      time.Now,
+	}
+}
+
+// Start runs the job in the background straight away and then every Interval until Stop
+// is called, so a server restarted more often than Interval still applies the policies
+func (j *Job) Start() {
+	j.quit = make(chan struct{})
+	j.wg.Add(1)
+
+	go func() {
+		defer j.wg.Done()
+
+		_, _ = j.Run(context.Background(), audit.System, j.DryRun)
+
+		ticker := time.NewTicker(j.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-j.quit:
+				return
+			case <-ticker.C:
+				_, _ = j.Run(context.Background(), audit.System, j.DryRun)
+			}
+		}
+	}()
+}
+
+// Stop stops the job and waits for a running run to finish
+func (j *Job) Stop() {
+	if j.quit == nil {
+		return
+	}
+	close(j.quit)
+	j.wg.Wait()
+	j.quit = nil
+}
+
+// Run applies every policy now on behalf of actor, or only counts the rows they would
+// change in a dry run, and records the run. A policy that fails does not stop the others;
+// the returned error joins their errors.
+func (j *Job) Run(ctx context.Context, actor string, dryRun bool) (models.RetentionRun, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	run := models.RetentionRun{
+		Actor:     actor,
+		DryRun:    dryRun,
+		Results:   []models.RetentionResult{},
+		StartedAt: j.now(),
+	}
+
+	var errs []error
+	for _, p := range j.Policies {
+		rows, err := j.Store.ApplyRetention(ctx, p.Table, p.Action, run.StartedAt.Add(-p.After), dryRun)
+
+		result := models.RetentionResult{Table: p.Table, Action: p.Action, After: FormatAge(p.After), Rows: rows}
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", p, err))
+			j.Logger.ErrorContext(ctx, "cannot apply retention policy", "table", p.Table, "action", p.Action, "error", err)
+		} else if !dryRun {
+			metrics.RetentionRows.WithLabelValues(p.Table, p.Action).Add(float64(rows))
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	err := errors.Join(errs...)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	run.FinishedAt = j.now()
+
+	id, insertErr := j.Store.InsertRetentionRun(ctx, run)
+	if insertErr != nil {
+		j.Logger.ErrorContext(ctx, "cannot record retention run", "error", insertErr)
+		err = errors.Join(err, insertErr)
+	}
+	run.ID = id
+
+	j.Logger.InfoContext(ctx, "retention run", "actor", actor, "dry_run", dryRun, "policies", len(j.Policies), "failed", len(errs))
+	return run, err
+}
@@ -0,0 +1,120 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/audit"
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30d", 30 * Day},
+		{"3y", 3 * Year},
+		{" 12h ", 12 * time.Hour},
+		{"0d", 0},
+	}
+	for _, e := range tests {
+		got, err := ParseAge(e.in)
+		if err != nil || got != e.want {
+			t.Errorf("ParseAge(%q) = %s, %v; want %s", e.in, got, err, e.want)
+		}
+	}
+
+	for _, in := range []string{"", "d", "-1d", "1.5y", "soon", "-2h"} {
+		if _, err := ParseAge(in); err == nil {
+			t.Errorf("ParseAge(%q): expected an error", in)
+		}
+	}
+
+	if FormatAge(3*Year) != "1095d" || FormatAge(90*time.Minute) != "1h30m0s" {
+		t.Errorf("unexpected formatting %s, %s", FormatAge(3*Year), FormatAge(90*time.Minute))
+	}
+}
+
+type applied struct {
+	table, action string
+	before        time.Time
+	dryRun        bool
+}
+
+type fakeStore struct {
+	applied []applied
+	runs    []models.RetentionRun
+	fail    string
+}
+
+func (s *fakeStore) ApplyRetention(ctx context.Context, table, action string, before time.Time, dryRun bool) (int, error) {
+	s.applied = append(s.applied, applied{table, action, before, dryRun})
+	if table == s.fail {
+		return 0, errors.New("table is locked")
+	}
+	return 2, nil
+}
+
+func (s *fakeStore) InsertRetentionRun(ctx context.Context, run models.RetentionRun) (int, error) {
+	s.runs = append(s.runs, run)
+	return len(s.runs), nil
+}
+
+func TestJob_Run(t *testing.T) {
+	store := &fakeStore{fail: "sessions"}
+	job := New(store, []Policy{
+		{Table: "reservations", Action: Anonymize, After: 3 * Year},
+		{Table: "sessions", Action: Delete, After: 30 * Day},
+		{Table: "outbox", Action: Delete, After: 30 * Day},
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+
+	run, err := job.Run(context.Background(), "user:1", true)
+	if err == nil {
+		t.Error("expected the failed policy to be reported")
+	}
+
+	if len(store.applied) != 3 {
+		t.Fatalf("expected every policy to be applied despite the failure but got %+v", store.applied)
+	}
+	if a := store.applied[0]; a.before != now.Add(-3*Year) || !a.dryRun {
+		t.Errorf("unexpected application %+v", a)
+	}
+
+	if len(store.runs) != 1 || run.ID != 1 || run.Actor != "user:1" || !run.DryRun {
+		t.Fatalf("expected the run to be recorded but got %+v", store.runs)
+	}
+	want := []models.RetentionResult{
+		{Table: "reservations", Action: Anonymize, After: "1095d", Rows: 2},
+		{Table: "sessions", Action: Delete, After: "30d", Error: "table is locked"},
+		{Table: "outbox", Action: Delete, After: "30d", Rows: 2},
+	}
+	for i, r := range run.Results {
+		if r != want[i] {
+			t.Errorf("result %d: got %+v, want %+v", i, r, want[i])
+		}
+	}
+	if run.Error == "" {
+		t.Error("expected the run to record the error")
+	}
+}
+
+func TestJob_StartRunsStraightAway(t *testing.T) {
+	store := &fakeStore{}
+	job := New(store, []Policy{{Table: "sessions", Action: Delete, After: 30 * Day}}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	job.DryRun = true
+
+	job.Start()
+	job.Stop()
+
+	if len(store.runs) != 1 || store.runs[0].Actor != audit.System || !store.runs[0].DryRun {
+		t.Errorf("expected a scheduled run at start rather than after a day but got %+v", store.runs)
+	}
+}
@@ -0,0 +1,140 @@
+// Package security holds the Content Security Policy of the pages: the nonce that lets
+// their own inline scripts and styles run, and the violation reports browsers send back.
+package security
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+// NoncePlaceholder is replaced in a policy by the nonce of the request
+const NoncePlaceholder = "{nonce}"
+
+type contextKey struct{}
+
+// NewNonce returns a random nonce for a policy, different for every response
+func NewNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// WithNonce returns a copy of ctx carrying the nonce of the response's policy
+func WithNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, contextKey{}, nonce)
+}
+
+// Nonce returns the nonce carried by ctx, or "" if there is none
+func Nonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(contextKey{}).(string)
+	return nonce
+}
+
+// Policy returns policy with the nonce of the response in place of NoncePlaceholder
+func Policy(policy, nonce string) string {
+	return strings.ReplaceAll(policy, NoncePlaceholder, nonce)
+}
+
+// WithReportURI returns policy sending its violation reports to uri, unless it already
+// names where they go with a report-uri or report-to directive
+func WithReportURI(policy, uri string) string {
+	for _, directive := range strings.Split(policy, ";") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), " ")
+		name = strings.ToLower(name)
+		if name == "report-uri" || name == "report-to" {
+			return policy
+		}
+	}
+
+	policy = strings.TrimRight(strings.TrimSpace(policy), ";")
+	return policy + "; report-uri " + uri
+}
+
+// Violation is a Content Security Policy violation reported by a browser
+type Violation struct {
+	DocumentURI string `json:"document_uri"`
+	Directive   string `json:"directive"`
+	BlockedURI  string `json:"blocked_uri"`
+	SourceFile  string `json:"source_file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Disposition string `json:"disposition,omitempty"`
+}
+
+// cspReport is the body of a report sent to a report-uri
+type cspReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		Disposition        string `json:"disposition"`
+	} `json:"csp-report"`
+}
+
+// report is an entry of a Reporting API body, sent to a report-to endpoint
+type report struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// ParseReports reads the violations in a report body, as sent for report-uri with the
+// application/csp-report type or for report-to with application/reports+json
+func ParseReports(contentType string, body []byte) ([]Violation, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	if mediaType == "application/reports+json" {
+		var reports []report
+		err := json.Unmarshal(body, &reports)
+		if err != nil {
+			return nil, err
+		}
+
+		var violations []Violation
+		for _, r := range reports {
+			if r.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, Violation{
+				DocumentURI: r.Body.DocumentURL,
+				Directive:   r.Body.EffectiveDirective,
+				BlockedURI:  r.Body.BlockedURL,
+				SourceFile:  r.Body.SourceFile,
+				Line:        r.Body.LineNumber,
+				Disposition: r.Body.Disposition,
+			})
+		}
+		return violations, nil
+	}
+
+	var r cspReport
+	err := json.Unmarshal(body, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	directive := r.Report.EffectiveDirective
+	if directive == "" {
+		directive = r.Report.ViolatedDirective
+	}
+	return []Violation{{
+		DocumentURI: r.Report.DocumentURI,
+		Directive:   directive,
+		BlockedURI:  r.Report.BlockedURI,
+		SourceFile:  r.Report.SourceFile,
+		Line:        r.Report.LineNumber,
+		Disposition: r.Report.Disposition,
+	}}, nil
+}
@@ -0,0 +1,71 @@
+package security
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNonce(t *testing.T) {
+	a, b := NewNonce(), NewNonce()
+	if len(a) < 22 || a == b {
+		t.Errorf("nonces are not random: %q %q", a, b)
+	}
+
+	ctx := WithNonce(context.Background(), a)
+	if Nonce(ctx) != a || Nonce(context.Background()) != "" {
+		t.Error("nonce not carried by the context")
+	}
+
+	if got := Policy("script-src 'self' 'nonce-{nonce}'", "abc"); got != "script-src 'self' 'nonce-abc'" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWithReportURI(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{"default-src 'self'", "default-src 'self'; report-uri /csp-report"},
+		{"default-src 'self'; ", "default-src 'self'; report-uri /csp-report"},
+		{"default-src 'self'; report-uri https://reports.example.com", "default-src 'self'; report-uri https://reports.example.com"},
+		{"default-src 'self'; Report-To csp", "default-src 'self'; Report-To csp"},
+	}
+
+	for _, test := range tests {
+		if got := WithReportURI(test.policy, "/csp-report"); got != test.want {
+			t.Errorf("%q: expected %q but got %q", test.policy, test.want, got)
+		}
+	}
+}
+
+func TestParseReports(t *testing.T) {
+	v, err := ParseReports("application/csp-report", []byte(`{"csp-report": {
+		"document-uri": "https://example.com/about",
+		"violated-directive": "script-src-elem",
+		"blocked-uri": "inline",
+		"line-number": 12,
+		"disposition": "report"
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 1 || v[0].Directive != "script-src-elem" || v[0].BlockedURI != "inline" || v[0].Line != 12 {
+		t.Errorf("got %+v", v)
+	}
+
+	v, err = ParseReports("application/reports+json", []byte(`[
+		{"type": "csp-violation", "body": {"documentURL": "https://example.com/", "effectiveDirective": "style-src-elem", "blockedURL": "https://evil.example.com/x.css"}},
+		{"type": "deprecation", "body": {}}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 1 || v[0].Directive != "style-src-elem" || v[0].BlockedURI != "https://evil.example.com/x.css" {
+		t.Errorf("got %+v", v)
+	}
+
+	if _, err = ParseReports("application/csp-report", []byte("nonsense")); err == nil {
+		t.Error("read a report from a body that is not JSON")
+	}
+}
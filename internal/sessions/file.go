@@ -0,0 +1,155 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileRecord is what is written to a session file
+type fileRecord struct {
+	Data   []byte
+	Expiry time.Time
+}
+
+// fileStore keeps each session in its own file in a directory, so sessions survive
+// restarts without a database
+type fileStore struct {
+	dir string
+
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+}
+
+// NewFileStore returns a store writing sessions to dir, which is created if needed
+func NewFileStore(dir string) (Store, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir: dir, now: time.Now}, nil
+}
+
+// path returns the file for a token. Tokens come from cookies, so they are hashed
+// rather than used as file names directly.
+func (s *fileStore) path(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".session")
+}
+
+func (s *fileStore) read(path string) (fileRecord, error) {
+	var rec fileRecord
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return rec, err
+	}
+
+	err = gob.NewDecoder(bytes.NewReader(b)).Decode(&rec)
+	return rec, err
+}
+
+func (s *fileStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	rec, err := s.read(s.path(token))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !rec.Expiry.After(s.now()) {
+		return nil, false, nil
+	}
+	return rec.Data, true, nil
+}
+
+func (s *fileStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(fileRecord{Data: b, Expiry: expiry})
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file and rename it, so a concurrent Find never sees half a session
+	tmp, err := os.CreateTemp(s.dir, "commit-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(buf.Bytes())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(token))
+}
+
+func (s *fileStore) DeleteCtx(ctx context.Context, token string) error {
+	err := os.Remove(s.path(token))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *fileStore) DeleteExpired(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	now := s.now()
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".session") {
+			continue
+		}
+		if ctx.Err() != nil {
+			return n, ctx.Err()
+		}
+
+		path := filepath.Join(s.dir, e.Name())
+		rec, err := s.read(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		// unreadable files are removed along with the expired ones
+		if err == nil && rec.Expiry.After(now) {
+			continue
+		}
+
+		err = os.Remove(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+func (s *fileStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *fileStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *fileStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
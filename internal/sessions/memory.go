@@ -0,0 +1,83 @@
+package sessions
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryItem struct {
+	data   []byte
+	expiry time.Time
+}
+
+// memoryStore keeps sessions in memory; they are lost when the application restarts
+type memoryStore struct {
+	mu    sync.RWMutex
+	items map[string]memoryItem
+
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+}
+
+// NewMemoryStore returns a store that keeps sessions in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		items: map[string]memoryItem{},
+		now:   time.Now,
+	}
+}
+
+func (s *memoryStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, ok := s.items[token]
+	if !ok || !item.expiry.After(s.now()) {
+		return nil, false, nil
+	}
+	return item.data, true, nil
+}
+
+func (s *memoryStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[token] = memoryItem{data: b, expiry: expiry}
+	return nil
+}
+
+func (s *memoryStore) DeleteCtx(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, token)
+	return nil
+}
+
+func (s *memoryStore) DeleteExpired(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	now := s.now()
+	for token, item := range s.items {
+		if !item.expiry.After(now) {
+			delete(s.items, token)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *memoryStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *memoryStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
@@ -0,0 +1,92 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// postgresStore keeps sessions in the sessions table, so they survive restarts and
+// are shared by every instance of the application
+type postgresStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresStore returns a store backed by the sessions table
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{DB: db}
+}
+
+func (s *postgresStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	var b []byte
+
+	err := s.DB.QueryRowContext(ctx, "select data from sessions where token = $1 and expiry > $2", token, time.Now()).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return b, true, nil
+}
+
+func (s *postgresStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	stmt := `insert into sessions (token, data, expiry) values ($1, $2, $3)
+			on conflict (token) do update set data = excluded.data, expiry = excluded.expiry`
+
+	_, err := s.DB.ExecContext(ctx, stmt, token, b, expiry)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *postgresStore) DeleteCtx(ctx context.Context, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, "delete from sessions where token = $1", token)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *postgresStore) DeleteExpired(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := s.DB.ExecContext(ctx, "delete from sessions where expiry <= $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+func (s *postgresStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *postgresStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *postgresStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
@@ -0,0 +1,102 @@
+// Package sessions provides the session stores the session manager can be configured
+// with, and a job that periodically deletes expired sessions from them.
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/prashant9154/Booking_System/internal/config"
+)
+
+// Store is a session store whose expired sessions can be deleted in bulk
+type Store interface {
+	scs.CtxStore
+
+	// DeleteExpired deletes every expired session, returning how many were deleted
+	DeleteExpired(ctx context.Context) (int, error)
+}
+
+// NewStore returns the store selected by cfg.Store; db is only used by the postgres store
+func NewStore(cfg config.SessionConfig, db *sql.DB) (Store, error) {
+	switch cfg.Store {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "postgres":
+		return NewPostgresStore(db), nil
+	case "file":
+		return NewFileStore(cfg.Dir)
+	default:
+		return nil, fmt.Errorf("unknown session store %q", cfg.Store)
+	}
+}
+
+// Cleaner periodically deletes expired sessions from a store
+type Cleaner struct {
+	Store    Store
+	Logger   *slog.Logger
+	Interval time.Duration
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewCleaner creates a cleaner that runs every interval
+func NewCleaner(store Store, interval time.Duration, logger *slog.Logger) *Cleaner {
+	return &Cleaner{
+		Store:    store,
+		Logger:   logger,
+		Interval: interval,
+	}
+}
+
+// Start runs the cleaner in the background until Stop is called
+func (c *Cleaner) Start() {
+	c.quit = make(chan struct{})
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.quit:
+				return
+			case <-ticker.C:
+				c.Clean()
+			}
+		}
+	}()
+}
+
+// Stop stops the cleaner and waits for a running clean up to finish
+func (c *Cleaner) Stop() {
+	if c.quit == nil {
+		return
+	}
+	close(c.quit)
+	c.wg.Wait()
+	c.quit = nil
+}
+
+// Clean deletes the expired sessions now, returning how many were deleted
+func (c *Cleaner) Clean() int {
+	n, err := c.Store.DeleteExpired(context.Background())
+	if err != nil {
+		c.Logger.Error("cannot delete expired sessions", "error", err)
+		return 0
+	}
+
+	if n > 0 {
+		c.Logger.Info("deleted expired sessions", "count", n)
+	}
+	return n
+}
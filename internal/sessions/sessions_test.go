@@ -0,0 +1,131 @@
+package sessions
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/config"
+	"github.com/prashant9154/Booking_System/internal/logging"
+)
+
+// testStores returns a memory and a file store whose clocks read *now
+func testStores(t *testing.T, now *time.Time) map[string]Store {
+	clock := func() time.Time { return *now }
+
+	mem := NewMemoryStore()
+	mem.(*memoryStore).now = clock
+
+	file, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.(*fileStore).now = clock
+
+	return map[string]Store{"memory": mem, "file": file}
+}
+
+func TestStores(t *testing.T) {
+	now := time.Now()
+	ctx := context.Background()
+
+	for name, store := range testStores(t, &now) {
+		err := store.CommitCtx(ctx, "live", []byte("a"), now.Add(time.Hour))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		_ = store.CommitCtx(ctx, "expired", []byte("b"), now.Add(-time.Second))
+
+		b, found, err := store.FindCtx(ctx, "live")
+		if err != nil || !found || string(b) != "a" {
+			t.Errorf("%s: expected to find the live session but got %q %t %v", name, b, found, err)
+		}
+
+		_, found, _ = store.FindCtx(ctx, "expired")
+		if found {
+			t.Errorf("%s: found an expired session", name)
+		}
+
+		_, found, _ = store.FindCtx(ctx, "missing")
+		if found {
+			t.Errorf("%s: found a session that was never committed", name)
+		}
+
+		// committing again replaces the data and expiry
+		_ = store.CommitCtx(ctx, "live", []byte("c"), now.Add(2*time.Hour))
+		b, _, _ = store.FindCtx(ctx, "live")
+		if string(b) != "c" {
+			t.Errorf("%s: expected the session to be replaced but got %q", name, b)
+		}
+
+		n, err := store.DeleteExpired(ctx)
+		if err != nil || n != 1 {
+			t.Errorf("%s: expected 1 expired session deleted but got %d %v", name, n, err)
+		}
+
+		err = store.DeleteCtx(ctx, "live")
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+		_, found, _ = store.FindCtx(ctx, "live")
+		if found {
+			t.Errorf("%s: found a deleted session", name)
+		}
+
+		err = store.DeleteCtx(ctx, "live")
+		if err != nil {
+			t.Errorf("%s: deleting a missing session should not fail but got %s", name, err)
+		}
+	}
+}
+
+func TestFileStore_TokenIsNotAPath(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.CommitCtx(context.Background(), "../escaped", []byte("a"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected the session to be written inside the store directory, found %d files", len(entries))
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	for _, name := range []string{"memory", "postgres", "file"} {
+		_, err := NewStore(config.SessionConfig{Store: name, Dir: t.TempDir()}, nil)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+
+	_, err := NewStore(config.SessionConfig{Store: "redis"}, nil)
+	if err == nil {
+		t.Error("expected an error for an unknown store")
+	}
+}
+
+func TestCleaner(t *testing.T) {
+	now := time.Now()
+	store := testStores(t, &now)["memory"]
+
+	_ = store.CommitCtx(context.Background(), "a", nil, now.Add(time.Minute))
+	_ = store.CommitCtx(context.Background(), "b", nil, now.Add(time.Hour))
+
+	c := NewCleaner(store, time.Minute, logging.Discard())
+	if n := c.Clean(); n != 0 {
+		t.Errorf("expected nothing cleaned before expiry but got %d", n)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if n := c.Clean(); n != 1 {
+		t.Errorf("expected 1 session cleaned but got %d", n)
+	}
+}
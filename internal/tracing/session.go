@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// sessionStore wraps a session store so loading, saving and deleting a session each get a span
+type sessionStore struct {
+	next scs.Store
+}
+
+// NewSessionStore wraps next with spans named session.load, session.save and session.delete
+func NewSessionStore(next scs.Store) scs.CtxStore {
+	return &sessionStore{next: next}
+}
+
+func (s *sessionStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	ctx, span := Tracer().Start(ctx, "session.load")
+
+	var b []byte
+	var found bool
+	var err error
+	if cs, ok := s.next.(scs.CtxStore); ok {
+		b, found, err = cs.FindCtx(ctx, token)
+	} else {
+		b, found, err = s.next.Find(token)
+	}
+
+	End(span, err)
+	return b, found, err
+}
+
+func (s *sessionStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	ctx, span := Tracer().Start(ctx, "session.save")
+
+	var err error
+	if cs, ok := s.next.(scs.CtxStore); ok {
+		err = cs.CommitCtx(ctx, token, b, expiry)
+	} else {
+		err = s.next.Commit(token, b, expiry)
+	}
+
+	End(span, err)
+	return err
+}
+
+func (s *sessionStore) DeleteCtx(ctx context.Context, token string) error {
+	ctx, span := Tracer().Start(ctx, "session.delete")
+
+	var err error
+	if cs, ok := s.next.(scs.CtxStore); ok {
+		err = cs.DeleteCtx(ctx, token)
+	} else {
+		err = s.next.Delete(token)
+	}
+
+	End(span, err)
+	return err
+}
+
+func (s *sessionStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *sessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *sessionStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
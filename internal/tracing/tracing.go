@@ -0,0 +1,140 @@
+// Package tracing sets up OpenTelemetry tracing and provides the spans shared by
+// the handlers, the renderer, the repository and the session store.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/prashant9154/Booking_System/internal/buildinfo"
+	"github.com/prashant9154/Booking_System/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies the application in exported spans
+const ServiceName = "bookings"
+
+// ExporterFactory creates a span exporter from the tracing config
+type ExporterFactory func(cfg config.TracingConfig) (sdktrace.SpanExporter, error)
+
+var (
+	mu        sync.RWMutex
+	exporters = map[string]ExporterFactory{
+		"stdout": newStdoutExporter,
+		"file":   newFileExporter,
+	}
+)
+
+// RegisterExporter makes an exporter selectable by name with the tracing.exporter setting,
+// e.g. to send spans to an OTLP collector
+func RegisterExporter(name string, f ExporterFactory) {
+	mu.Lock()
+	defer mu.Unlock()
+	exporters[name] = f
+}
+
+// Tracer returns the application's tracer from the global tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/prashant9154/Booking_System")
+}
+
+// Setup installs the global tracer provider for the configured exporter and sample rate,
+// returning a function that flushes and stops it. With the none exporter spans are not recorded.
+func Setup(a *config.AppConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if a.Tracing.Exporter == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	mu.RLock()
+	factory, ok := exporters[a.Tracing.Exporter]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown tracing exporter %q, expected one of %v", a.Tracing.Exporter, exporterNames())
+	}
+
+	exporter, err := factory(a.Tracing)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create %s tracing exporter: %w", a.Tracing.Exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(a.Tracing.SampleRate))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(ServiceName),
+			semconv.ServiceVersion(buildinfo.Get().Version),
+			semconv.DeploymentEnvironment(a.Env),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// End records err, if any, on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func exporterNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := []string{"none"}
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// newStdoutExporter writes spans to stdout as indented JSON, for local use
+func newStdoutExporter(config.TracingConfig) (sdktrace.SpanExporter, error) {
+	return stdouttrace.New(stdouttrace.WithPrettyPrint())
+}
+
+// newFileExporter appends spans to the configured file, one JSON object per span
+func newFileExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &fileExporter{SpanExporter: exporter, f: f}, nil
+}
+
+// fileExporter closes its file when shut down
+type fileExporter struct {
+	sdktrace.SpanExporter
+	f *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if cerr := e.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2/memstore"
+	"github.com/prashant9154/Booking_System/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSetup_FileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	a := &config.AppConfig{
+		Env:     "test",
+		Tracing: config.TracingConfig{Exporter: "file", File: path, SampleRate: 1},
+	}
+
+	shutdown, err := Setup(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := Tracer().Start(context.Background(), "test span")
+	span.End()
+
+	err = shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Name":"test span"`) || !strings.Contains(string(b), ServiceName) {
+		t.Errorf("span not exported to file: %s", b)
+	}
+}
+
+func TestSetup_Sampling(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+	a := &config.AppConfig{
+		Tracing: config.TracingConfig{Exporter: "file", File: path, SampleRate: 0},
+	}
+
+	shutdown, err := Setup(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := Tracer().Start(context.Background(), "not sampled")
+	span.End()
+	_ = shutdown(context.Background())
+
+	b, _ := os.ReadFile(path)
+	if len(b) != 0 {
+		t.Errorf("expected no spans with a sample rate of 0 but got %s", b)
+	}
+}
+
+func TestSetup_Exporters(t *testing.T) {
+	a := &config.AppConfig{Tracing: config.TracingConfig{Exporter: "carrier-pigeon"}}
+	_, err := Setup(a)
+	if err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+
+	var used bool
+	RegisterExporter("carrier-pigeon", func(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+		used = true
+		return tracetest.NewInMemoryExporter(), nil
+	})
+
+	shutdown, err := Setup(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = shutdown(context.Background())
+
+	if !used {
+		t.Error("registered exporter was not used")
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	store := NewSessionStore(memstore.New())
+	ctx := context.Background()
+
+	err := store.CommitCtx(ctx, "token", []byte("data"), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.FindCtx(ctx, "token")
+	if err != nil || !found || string(b) != "data" {
+		t.Fatalf("session not found after commit: %q %t %v", b, found, err)
+	}
+
+	err = store.DeleteCtx(ctx, "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name())
+	}
+	if strings.Join(names, ",") != "session.save,session.load,session.delete" {
+		t.Errorf("unexpected spans %v", names)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, span := tp.Tracer("test").Start(context.Background(), "failing")
+	End(span, errors.New("boom"))
+
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Status().Code != codes.Error || ended[0].Status().Description != "boom" {
+		t.Errorf("expected an ended span with an error status but got %+v", ended)
+	}
+}
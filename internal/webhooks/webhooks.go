@@ -0,0 +1,311 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/events"
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// Events lists every event a subscription can choose from; only events that are written
+// to the outbox belong here, so nobody subscribes to something that is never sent. The
+// modified and cancelled events join once reservations can be changed.
+var Events = []string{events.ReservationCreated}
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Store is the persistence the dispatcher needs; it is satisfied by repository.DatabaseRepo
+type Store interface {
+	AllWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	InsertWebhookDelivery(ctx context.Context, d models.WebhookDelivery) (int, error)
+	GetWebhookDeliveryByID(ctx context.Context, id int) (models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error
+	ClaimDueWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error)
+}
+
+// Payload is the JSON body posted to subscribers
+type Payload struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher queues events for subscribers and delivers them with retries
+type Dispatcher struct {
+	Store  Store
+	Client *http.Client
+	Logger *slog.Logger
+
+	// PollInterval is how often the queue is checked for due deliveries
+	PollInterval time.Duration
+	// BaseBackoff is the delay before the first retry; it doubles on every attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps the delay between attempts
+	MaxBackoff time.Duration
+	// MaxAttempts is the number of attempts after which a delivery is marked failed
+	MaxAttempts int
+	// BatchSize is the maximum number of deliveries attempted per poll
+	BatchSize int
+	// Lease is how long the deliveries of a batch are claimed by this dispatcher; it must
+	// cover a whole batch of attempts, after which another instance may attempt them again
+	Lease time.Duration
+
+	// now returns the current time, and is replaced in tests
+	now func() time.Time
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a dispatcher with default retry settings
+func New(store Store, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		Store:        store,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		Logger:       logger,
+		PollInterval: 5 * time.Second,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   6 * time.Hour,
+		MaxAttempts:  10,
+		BatchSize:    20,
+		Lease:        5 * time.Minute,
+		now:          time.Now,
+	}
+}
+
+// HandleEvent stores a delivery of the event for every active subscription
+// listening to it. It is an outbox subscriber; deliveries are unique per event
+// id and subscription, so handling the same event twice queues nothing new.
+func (d *Dispatcher) HandleEvent(e models.Event) error {
+	ctx := context.Background()
+
+	subscriptions, err := d.Store.AllWebhookSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        e.ID,
+		Event:     e.Type,
+		CreatedAt: e.OccurredAt,
+		Data:      json.RawMessage(e.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range subscriptions {
+		if !s.Active || !subscribed(s, e.Type) {
+			continue
+		}
+
+		_, err := d.Store.InsertWebhookDelivery(ctx, models.WebhookDelivery{
+			SubscriptionID: s.ID,
+			EventID:        e.ID,
+			Event:          e.Type,
+			Payload:        string(body),
+			Status:         StatusPending,
+			NextAttemptAt:  d.now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Resend puts a delivery back in the queue to be attempted straight away
+func (d *Dispatcher) Resend(ctx context.Context, id int) error {
+	delivery, err := d.Store.GetWebhookDeliveryByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	delivery.Status = StatusPending
+	delivery.NextAttemptAt = d.now()
+
+	return d.Store.UpdateWebhookDelivery(ctx, delivery)
+}
+
+// Start runs the delivery worker in the background until Stop is called
+func (d *Dispatcher) Start() {
+	d.quit = make(chan struct{})
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.quit:
+				return
+			case <-ticker.C:
+				d.ProcessDue()
+			}
+		}
+	}()
+}
+
+// Stop stops the delivery worker and waits for the current batch to finish
+func (d *Dispatcher) Stop() {
+	if d.quit == nil {
+		return
+	}
+	close(d.quit)
+	d.wg.Wait()
+	d.quit = nil
+}
+
+// ProcessDue claims and attempts every delivery that is due, returning how many were
+// attempted. Deliveries claimed by another instance are not due until its lease expires.
+func (d *Dispatcher) ProcessDue() int {
+	ctx := context.Background()
+
+	now := d.now()
+	deliveries, err := d.Store.ClaimDueWebhookDeliveries(ctx, now, now.Add(d.Lease), d.BatchSize)
+	if err != nil {
+		d.Logger.Error("cannot load due webhook deliveries", "error", err)
+		return 0
+	}
+
+	for _, delivery := range deliveries {
+		d.attempt(ctx, delivery)
+	}
+
+	return len(deliveries)
+}
+
+// attempt posts a single delivery and records the outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	delivery.Attempts++
+
+	code, err := d.send(ctx, delivery)
+	delivery.ResponseCode = code
+
+	if err == nil {
+		delivery.Status = StatusDelivered
+		delivery.DeliveredAt = d.now()
+		delivery.LastError = ""
+		d.Logger.Info("webhook delivered", "delivery_id", delivery.ID, "url", delivery.Subscription.URL)
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.MaxAttempts {
+			delivery.Status = StatusFailed
+		} else {
+			delivery.NextAttemptAt = d.now().Add(d.Backoff(delivery.Attempts))
+		}
+		d.Logger.Warn("webhook delivery failed", "delivery_id", delivery.ID, "attempt", delivery.Attempts, "error", err)
+	}
+
+	err = d.Store.UpdateWebhookDelivery(ctx, delivery)
+	if err != nil {
+		d.Logger.Error("cannot update webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts the signed payload, returning the response status code
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Subscription.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(delivery.Subscription.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the delay before the next attempt after the given number of attempts
+func (d *Dispatcher) Backoff(attempts int) time.Duration {
+	delay := d.BaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.MaxBackoff {
+			return d.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// Sign returns the signature header value for a payload: the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the subscription secret
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value against the payload, for use by receivers
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// NewSecret generates a random secret for a new subscription
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// subscribed reports whether the subscription listens to the event
+func subscribed(s models.WebhookSubscription, event string) bool {
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
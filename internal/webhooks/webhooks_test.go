@@ -0,0 +1,269 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prashant9154/Booking_System/internal/events"
+	"github.com/prashant9154/Booking_System/internal/logging"
+	"github.com/prashant9154/Booking_System/internal/models"
+)
+
+// memStore is an in-memory Store for testing the dispatcher
+type memStore struct {
+	mu            sync.Mutex
+	subscriptions []models.WebhookSubscription
+	deliveries    map[int]models.WebhookDelivery
+	nextID        int
+}
+
+func newMemStore(subscriptions ...models.WebhookSubscription) *memStore {
+	return &memStore{
+		subscriptions: subscriptions,
+		deliveries:    map[int]models.WebhookDelivery{},
+	}
+}
+
+func (s *memStore) AllWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return s.subscriptions, nil
+}
+
+func (s *memStore) InsertWebhookDelivery(ctx context.Context, d models.WebhookDelivery) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.deliveries {
+		if existing.EventID == d.EventID && existing.SubscriptionID == d.SubscriptionID {
+			return 0, nil
+		}
+	}
+	s.nextID++
+	d.ID = s.nextID
+	for _, sub := range s.subscriptions {
+		if sub.ID == d.SubscriptionID {
+			d.Subscription = sub
+		}
+	}
+	s.deliveries[d.ID] = d
+	return d.ID, nil
+}
+
+func (s *memStore) GetWebhookDeliveryByID(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return d, errors.New("not found")
+	}
+	return d, nil
+}
+
+func (s *memStore) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+func (s *memStore) ClaimDueWebhookDeliveries(ctx context.Context, now, until time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []models.WebhookDelivery
+	for i := 1; i <= s.nextID; i++ {
+		d, ok := s.deliveries[i]
+		if ok && d.Status == StatusPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+			d.NextAttemptAt = until
+			s.deliveries[i] = d
+		}
+	}
+	return due, nil
+}
+
+func newTestDispatcher(store Store, now *time.Time) *Dispatcher {
+	d := New(store, logging.Discard())
+	d.now = func() time.Time { return *now }
+	return d
+}
+
+func TestDispatcher_Delivers(t *testing.T) {
+	var gotBody []byte
+	var gotHeaders http.Header
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeaders = r.Header
+	}))
+	defer receiver.Close()
+
+	store := newMemStore(
+		models.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: "s3cret", Events: []string{events.ReservationCreated}, Active: true},
+		models.WebhookSubscription{ID: 2, URL: receiver.URL, Secret: "other", Events: []string{events.ReservationCancelled}, Active: true},
+		models.WebhookSubscription{ID: 3, URL: receiver.URL, Secret: "other", Events: []string{events.ReservationCreated}, Active: false},
+	)
+
+	now := time.Now()
+	d := newTestDispatcher(store, &now)
+
+	e, err := events.New(events.ReservationCreated, map[string]int{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.HandleEvent(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same event handled again must not queue another delivery
+	err = d.HandleEvent(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.deliveries) != 1 {
+		t.Fatalf("expected 1 queued delivery but got %d", len(store.deliveries))
+	}
+
+	if n := d.ProcessDue(); n != 1 {
+		t.Fatalf("expected 1 attempted delivery but got %d", n)
+	}
+
+	if store.deliveries[1].Status != StatusDelivered {
+		t.Errorf("expected delivery to be %s but is %s", StatusDelivered, store.deliveries[1].Status)
+	}
+
+	if gotHeaders.Get(HeaderEvent) != events.ReservationCreated {
+		t.Errorf("wrong event header %q", gotHeaders.Get(HeaderEvent))
+	}
+
+	if !Verify("s3cret", gotHeaders.Get(HeaderTimestamp), gotBody, gotHeaders.Get(HeaderSignature)) {
+		t.Error("signature does not verify")
+	}
+
+	var p Payload
+	err = json.Unmarshal(gotBody, &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != e.ID {
+		t.Errorf("payload id %q does not match event id %q", p.ID, e.ID)
+	}
+
+	if Verify("wrong", gotHeaders.Get(HeaderTimestamp), gotBody, gotHeaders.Get(HeaderSignature)) {
+		t.Error("signature verifies with the wrong secret")
+	}
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	fail := true
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	store := newMemStore(
+		models.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: "s3cret", Events: []string{events.ReservationCreated}, Active: true},
+	)
+
+	now := time.Now()
+	d := newTestDispatcher(store, &now)
+	d.MaxAttempts = 3
+
+	e, _ := events.New(events.ReservationCreated, nil)
+	_ = d.HandleEvent(e)
+	d.ProcessDue()
+
+	got := store.deliveries[1]
+	if got.Status != StatusPending || got.Attempts != 1 || got.ResponseCode != http.StatusInternalServerError {
+		t.Fatalf("unexpected delivery after failed attempt: %+v", got)
+	}
+
+	if !got.NextAttemptAt.Equal(now.Add(d.BaseBackoff)) {
+		t.Errorf("expected next attempt after %s", d.BaseBackoff)
+	}
+
+	// not yet due
+	if n := d.ProcessDue(); n != 0 {
+		t.Errorf("expected no attempts before backoff elapsed but got %d", n)
+	}
+
+	now = now.Add(d.BaseBackoff)
+	d.ProcessDue()
+	if !store.deliveries[1].NextAttemptAt.Equal(now.Add(2 * d.BaseBackoff)) {
+		t.Error("backoff did not double")
+	}
+
+	now = now.Add(2 * d.BaseBackoff)
+	d.ProcessDue()
+	if store.deliveries[1].Status != StatusFailed {
+		t.Errorf("expected delivery to be %s after max attempts but is %s", StatusFailed, store.deliveries[1].Status)
+	}
+
+	fail = false
+	err := d.Resend(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.ProcessDue()
+	if store.deliveries[1].Status != StatusDelivered {
+		t.Errorf("expected resent delivery to be %s but is %s", StatusDelivered, store.deliveries[1].Status)
+	}
+}
+
+func TestDispatcher_ClaimsDeliveries(t *testing.T) {
+	sent := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+	}))
+	defer receiver.Close()
+
+	store := newMemStore(
+		models.WebhookSubscription{ID: 1, URL: receiver.URL, Secret: "s3cret", Events: []string{events.ReservationCreated}, Active: true},
+	)
+
+	now := time.Now()
+	d := newTestDispatcher(store, &now)
+
+	e, _ := events.New(events.ReservationCreated, nil)
+	_ = d.HandleEvent(e)
+
+	// another instance claims the delivery first, then stops before attempting it
+	claimed, _ := store.ClaimDueWebhookDeliveries(context.Background(), now, now.Add(d.Lease), d.BatchSize)
+	if len(claimed) != 1 {
+		t.Fatalf("expected 1 claimed delivery but got %d", len(claimed))
+	}
+
+	if n := d.ProcessDue(); n != 0 || sent != 0 {
+		t.Errorf("expected a claimed delivery to be left alone but %d were attempted", n)
+	}
+
+	now = now.Add(d.Lease)
+	if n := d.ProcessDue(); n != 1 || sent != 1 {
+		t.Errorf("expected the delivery to be attempted once its lease expired but %d were", n)
+	}
+	if store.deliveries[1].Status != StatusDelivered {
+		t.Errorf("expected delivery to be %s but is %s", StatusDelivered, store.deliveries[1].Status)
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := New(nil, nil)
+	d.BaseBackoff = time.Second
+	d.MaxBackoff = 5 * time.Second
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if got := d.Backoff(i + 1); got != e {
+			t.Errorf("attempt %d: expected %s but got %s", i+1, e, got)
+		}
+	}
+}
@@ -1,3 +1,6 @@
 #!/bin/bash
 
-go build -o bookings cmd/web/*.go && ./bookings
\ No newline at end of file
+pkg=github.com/prashant9154/Booking_System/internal/buildinfo
+ldflags="-X $pkg.Version=$(git describe --tags --always --dirty 2>/dev/null || echo dev) -X $pkg.Commit=$(git rev-parse HEAD 2>/dev/null) -X $pkg.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
+go build -ldflags "$ldflags" -o bookings cmd/web/*.go && ./bookings "$@"